@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/database/backup"
+	"github.com/seka/bbs-sample/database/migration"
+)
+
+func runBackup(args Arguments, cmdArgs []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	excludePersonal := fs.Bool("exclude-personal-data", false, "replace emails, password hashes and poster address hashes in the archive")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] backup [-exclude-personal-data] FILE\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(cmdArgs); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("backup needs the archive file")
+	}
+	path := fs.Arg(0)
+	return withMigrator(args, func(ctx context.Context, db database.Database, migrator *migration.Migrator) error {
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		// Written to a temporary file first so that a failed backup never
+		// replaces a good archive.
+		tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		checksums, err := backup.Write(ctx, db, tmp, backup.Options{
+			Driver:              args.Database.Driver,
+			SchemaVersion:       version,
+			ExcludePersonalData: *excludePersonal,
+		})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return err
+		}
+		for _, t := range backup.Tables() {
+			fmt.Printf("backed up %s: %d rows\n", t.Name, checksums[t.Name].Rows)
+		}
+		return nil
+	})
+}
+
+func runRestore(args Arguments, cmdArgs []string) error {
+	if len(cmdArgs) != 1 {
+		return fmt.Errorf("usage: %s [flags] restore FILE", os.Args[0])
+	}
+	f, err := os.Open(cmdArgs[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return withMigrator(args, func(ctx context.Context, db database.Database, migrator *migration.Migrator) error {
+		if _, err := migrator.Up(ctx); err != nil {
+			return err
+		}
+		manifest, checksums, err := backup.Restore(ctx, db, f, backup.RestoreOptions{
+			Driver:        args.Database.Driver,
+			SchemaVersion: migrator.Latest(),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("restored a %s backup created at %s\n", manifest.Driver, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+		for _, name := range manifest.Tables {
+			fmt.Printf("restored %s: %d rows\n", name, checksums[name].Rows)
+		}
+		return nil
+	})
+}
+
+// withMigrator connects to the database and runs fn.
+func withMigrator(args Arguments, fn func(context.Context, database.Database, *migration.Migrator) error) error {
+	db, err := database.New(args.Database)
+	if err != nil {
+		return err
+	}
+	if err := db.Connect(); err != nil {
+		return err
+	}
+	defer db.Disconnect()
+	migrator, err := migration.New(db, args.Database.Driver)
+	if err != nil {
+		return err
+	}
+	return fn(context.Background(), db, migrator)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+	"github.com/seka/bbs-sample/internal/tripcode"
+	"github.com/seka/bbs-sample/mailer"
+	"github.com/seka/bbs-sample/model"
+	"github.com/seka/bbs-sample/server"
+)
+
+// demoPassword is the password of every demo user.
+const demoPassword = "password"
+
+var demoBoards = []*model.Board{
+	{Slug: "news", Title: "News", Description: "Announcements from the admins.", SortOrder: -1, PostPolicy: model.PostPolicyAdmins, MaxMessageLength: 1000, MaxPostsPerThread: 1000},
+	{Slug: "random", Title: "Random", Description: "Short chit-chat.", SortOrder: 1, PostPolicy: model.PostPolicyMembers, MaxMessageLength: 140, MaxPostsPerThread: 5},
+	{Slug: "anon", Title: "Anonymous", Description: "No names, guests welcome.", SortOrder: 2, PostPolicy: model.PostPolicyMembers, MaxMessageLength: 1000, MaxPostsPerThread: 1000, Anonymous: true, AllowGuests: true, DefaultName: "Nanashi"},
+}
+
+var demoUsers = []struct {
+	name  string
+	email string
+	role  string
+}{
+	{"alice", "alice@example.com", model.RoleAdmin},
+	{"bob", "bob@example.com", model.RoleMember},
+	{"carol", "carol@example.com", model.RoleMember},
+}
+
+type demoPost struct {
+	user string
+	// name is the name field on anonymous boards.
+	name string
+	text string
+	sage bool
+}
+
+var demoThreads = []struct {
+	board   string
+	subject string
+	posts   []demoPost
+}{
+	{"news", "Welcome to bbs-sample", []demoPost{
+		{user: "alice", text: "The demo runs fully in memory, nothing is saved."},
+	}},
+	{"general", "Introduce yourself", []demoPost{
+		{user: "alice", text: "Hello, I'm alice."},
+		{user: "bob", text: "Hello, I'm bob."},
+		{user: "carol", text: ">>1-2 Hello, I'm carol."},
+	}},
+	{"random", "Anyone around?", []demoPost{
+		{user: "bob", text: "Anyone around?"},
+		{user: "carol", text: ">>1 Just lurking.", sage: true},
+	}},
+	{"anon", "Tripcode test", []demoPost{
+		{user: "bob", name: "bob#secret", text: "Is my tripcode the same every time?"},
+		{user: "carol", text: ">>1 Post again with the same secret and see."},
+		{user: "bob", name: "bob#secret", text: ">>2 It is."},
+	}},
+}
+
+// newDemoMain returns a Main which serves an in-memory store without a database.
+func newDemoMain(args Arguments, passwords cryptoutil.PasswordHasher, mail mailer.Mailer) (*Main, error) {
+	logger := log15.New("module", "main")
+	store := model.NewMemoryStore()
+	if err := seedDemo(context.Background(), store, passwords); err != nil {
+		return nil, err
+	}
+	for _, u := range demoUsers {
+		logger.Info("Demo user", "email", u.email, "password", demoPassword, "role", u.role)
+	}
+	sessions := newSessionStore(args, store)
+	throttle := model.NewLoginThrottle(store, args.Throttle)
+	return &Main{
+		appSecret: args.AppSecret,
+		archiver:  model.NewArchiver(store, args.Archiver),
+		sessions:  sessions,
+		throttle:  throttle,
+		logger:    logger,
+		server: server.New(server.Options{
+			Addr:        net.JoinHostPort("", args.Port),
+			DebugAddr:   args.DebugAddr,
+			CookieStore: sessions,
+			Store:       store,
+			Passwords:   passwords,
+			Throttle:    throttle,
+
+			Mailer:               mail,
+			BaseURL:              args.BaseURL,
+			TokenSecret:          appKey(args, "token"),
+			RequireVerifiedEmail: args.RequireVerifiedEmail,
+			TwoFactorRoles:       args.TwoFactorRoles,
+
+			PosterSecret:      appKey(args, "poster"),
+			TrustForwardedFor: args.TrustForwardedFor,
+		}),
+	}, nil
+}
+
+// seedDemo saves the demo boards, users and threads into store.
+func seedDemo(ctx context.Context, store model.Store, passwords cryptoutil.PasswordHasher) error {
+	return store.WithTx(ctx, func(store model.Store) error {
+		for _, b := range demoBoards {
+			board := *b
+			if err := store.Boards().Save(ctx, &board); err != nil {
+				return fmt.Errorf("seed board %s: %v", b.Slug, err)
+			}
+		}
+		userIDs := map[string]int{}
+		for _, u := range demoUsers {
+			hash, err := passwords.Hash(demoPassword)
+			if err != nil {
+				return err
+			}
+			user := &model.User{
+				Name:            u.name,
+				Email:           u.email,
+				Password:        hash,
+				Role:            u.role,
+				EmailVerifiedAt: time.Now().Format(model.TimeFormat),
+			}
+			if err := store.Users().Save(ctx, user); err != nil {
+				return fmt.Errorf("seed user %s: %v", u.name, err)
+			}
+			userIDs[u.name] = user.ID
+		}
+		createdAt := time.Now().Add(-time.Hour)
+		for _, t := range demoThreads {
+			board, err := store.Boards().FindBySlug(ctx, t.board)
+			if err != nil {
+				return fmt.Errorf("seed board %s: %v", t.board, err)
+			}
+			thread := &model.Thread{BoardID: board.ID, Subject: t.subject}
+			for i, p := range t.posts {
+				createdAt = createdAt.Add(time.Minute)
+				msg := &model.Message{
+					UserID:    userIDs[p.user],
+					Message:   p.text,
+					CreatedAt: createdAt.Format(model.TimeFormat),
+				}
+				if board.Anonymous {
+					msg.Name, msg.Tripcode = tripcode.Parse(p.name)
+					if msg.Name == "" {
+						msg.Name = board.DefaultName
+					}
+				}
+				if i == 0 {
+					err = model.StartThread(ctx, store, thread, msg)
+				} else {
+					msg.ThreadID = thread.ID
+					err = model.Reply(ctx, store, msg, p.sage)
+				}
+				if err != nil {
+					return fmt.Errorf("seed thread %s: %v", t.subject, err)
+				}
+			}
+		}
+		return nil
+	})
+}
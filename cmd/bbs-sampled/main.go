@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net"
 	"os"
 	"os/signal"
@@ -10,12 +11,21 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/gorilla/sessions"
 	"github.com/inconshreveable/log15"
 
 	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/database/migration"
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+	"github.com/seka/bbs-sample/internal/flagutil"
 	"github.com/seka/bbs-sample/internal/logutil"
+	"github.com/seka/bbs-sample/mailer"
+	"github.com/seka/bbs-sample/model"
 	"github.com/seka/bbs-sample/server"
+	"github.com/seka/bbs-sample/server/session"
+)
+
+const (
+	envPrefix = "BBS"
 )
 
 var (
@@ -25,49 +35,200 @@ var (
 func init() {
 	flag.StringVar(&args.LogLevel, "log-level", "info", "spcify the application log-level")
 	flag.StringVar(&args.Port, "port", "8080", "specify the application listening port")
+	flag.StringVar(&args.DebugAddr, "debug-addr", "", "specify the address to serve /debug/vars metrics on (disabled when empty)")
 	flag.StringVar(&args.AppSecret, "app-secret", "", "specify the authentication key provided should be 32 bytes long")
-	flag.StringVar(&args.Database.Addr, "database-addr", "localhost:3306", "specify the address of a database")
-	flag.StringVar(&args.Database.Name, "database-name", "bbs-sample", "specify the name of a database")
-	flag.StringVar(&args.Database.User, "database-user", "bbs-sample-user", "specify the username to connect for database")
-	flag.StringVar(&args.Database.Password, "database-password", "bbs-sample-password", "specify the password to connect for database")
+	flag.BoolVar(&args.AutoMigrate, "auto-migrate", false, "apply pending schema migrations on start (always enabled for sqlite)")
+	flag.BoolVar(&args.Demo, "demo", false, "run with an in-memory store seeded with sample users and posts instead of a database")
+	flag.BoolVar(&args.RequireSchema, "require-schema", false, "exit when the schema does not match the migrations")
+	flag.DurationVar(&args.SlowQueryThreshold, "database-slow-query-threshold", 200*time.Millisecond, "log the database queries slower than this (0 disables)")
+	flag.DurationVar(&args.Supervisor.CheckInterval, "database-check-interval", 10*time.Second, "specify the interval of the database health checks")
+	flag.DurationVar(&args.Supervisor.MinBackoff, "database-reconnect-min-backoff", 500*time.Millisecond, "specify the initial delay between database reconnect attempts")
+	flag.DurationVar(&args.Supervisor.MaxBackoff, "database-reconnect-max-backoff", 30*time.Second, "specify the maximum delay between database reconnect attempts")
+	flag.BoolVar(&args.Trace, "database-trace", false, "log a trace span for every database operation at debug level")
+	flag.BoolVar(&args.TrustForwardedFor, "trust-x-forwarded-for", false, "take the client address of posters from X-Forwarded-For (only behind a proxy which sets it)")
+	flag.DurationVar(&args.Archiver.After, "archive-after", 30*24*time.Hour, "archive the threads without posts for this long (0 disables archival)")
+	flag.DurationVar(&args.Archiver.Interval, "archive-interval", 10*time.Minute, "specify the interval between archival runs")
+	flag.DurationVar(&args.Sessions.MaxAge, "session-max-age", 30*24*time.Hour, "specify how long a sign in lasts")
+	flag.IntVar(&args.Throttle.MaxFailures, "login-max-failures", 5, "lock an account after this many failed sign ins (0 disables the lockout)")
+	flag.IntVar(&args.Throttle.MaxIPFailures, "login-max-ip-failures", 50, "lock an address after this many failed sign ins (0 disables the lockout)")
+	flag.DurationVar(&args.Throttle.Lockout, "login-lockout", 15*time.Minute, "specify how long a locked account or address cannot sign in")
+	flag.DurationVar(&args.Throttle.Window, "login-failure-window", 15*time.Minute, "forget the failed sign ins after this long without another")
+	flag.DurationVar(&args.Throttle.Delay, "login-delay", time.Second, "specify the wait after a failed sign in, doubled on every further failure up to a minute")
+	flag.StringVar(&args.BaseURL, "base-url", "", "specify the URL the links sent by email point at (http://localhost:PORT when empty)")
+	flag.BoolVar(&args.RequireVerifiedEmail, "require-verified-email", false, "refuse to sign in users until they verify their email")
+	flag.Var(&args.TwoFactorRoles, "require-2fa-roles", "specify the comma separated roles which must sign in with a TOTP code (e.g. admin)")
+	flag.DurationVar(&args.Sessions.GCInterval, "session-gc-interval", time.Hour, "specify the interval between deletions of expired sessions (0 disables them)")
+	args.Database.RegisterFlags(flag.CommandLine)
+	args.Passwords.RegisterFlags(flag.CommandLine)
+	args.Mail.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [migrate up|down|status|redo | backup FILE | restore FILE]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Every flag can also be set with an environment variable, e.g. %s.\n", flagutil.EnvName(envPrefix, "database-addr"))
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
 	flag.Parse()
+	if err := flagutil.SetFromEnv(flag.CommandLine, envPrefix); err != nil {
+		log15.Error("Environment variable error", "err", err)
+		os.Exit(1)
+	}
 	logutil.SeetupRootLogger(args.LogLevel)
-	if err := newMain(args).Run(); err != nil {
+	switch flag.Arg(0) {
+	case "migrate":
+		if err := runMigrate(args, flag.Arg(1)); err != nil {
+			log15.Error("Migrate error", "err", err)
+			os.Exit(1)
+		}
+		return
+	case "backup":
+		if err := runBackup(args, flag.Args()[1:]); err != nil {
+			log15.Error("Backup error", "err", err)
+			os.Exit(1)
+		}
+		return
+	case "restore":
+		if err := runRestore(args, flag.Args()[1:]); err != nil {
+			log15.Error("Restore error", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	m, err := newMain(args)
+	if err != nil {
+		log15.Error("Initialize error", "err", err)
+		os.Exit(1)
+	}
+	if err := m.Run(); err != nil {
 		os.Exit(1)
 	}
 }
 
 // Arguments ...
 type Arguments struct {
-	Port      string
-	LogLevel  string
-	AppSecret string
-	Database  database.Options
+	Port          string
+	DebugAddr     string
+	LogLevel      string
+	AppSecret     string
+	AutoMigrate   bool
+	RequireSchema bool
+	Demo          bool
+	Database      database.Options
+
+	// TrustForwardedFor takes the poster addresses from X-Forwarded-For.
+	TrustForwardedFor bool
+
+	// BaseURL is the URL of the server in the links sent by email.
+	BaseURL              string
+	RequireVerifiedEmail bool
+	// TwoFactorRoles must sign in with a second factor.
+	TwoFactorRoles model.TwoFactorRoles
+
+	SlowQueryThreshold time.Duration
+	Trace              bool
+	Supervisor         database.SupervisorOptions
+	Archiver           model.ArchiverOptions
+	Sessions           session.Options
+	Throttle           model.ThrottleOptions
+	Passwords          cryptoutil.PasswordOptions
+	Mail               mailer.Options
 }
 
 // Main ...
 type Main struct {
-	appSecret string
-	db        database.Database
-	logger    log15.Logger
-	server    *server.Server
+	appSecret     string
+	autoMigrate   bool
+	requireSchema bool
+	db            *database.Supervisor // nil in demo mode
+	migrator      *migration.Migrator
+	archiver      *model.Archiver
+	sessions      *session.Store
+	throttle      *model.LoginThrottle
+	logger        log15.Logger
+	server        *server.Server
 }
 
-func newMain(args Arguments) *Main {
-	db := database.NewMySQL(args.Database)
-	return &Main{
-		appSecret: args.AppSecret,
-		db:        db,
-		logger:    log15.New("module", "main"),
-		server: server.New(server.Options{
-			Addr:        net.JoinHostPort("", args.Port),
-			CookieStore: sessions.NewCookieStore([]byte(args.AppSecret)),
-			DB:          db,
-		}),
+func newMain(args Arguments) (*Main, error) {
+	passwords, err := cryptoutil.NewPasswordHasher(args.Passwords)
+	if err != nil {
+		return nil, err
+	}
+	mail, err := mailer.New(args.Mail)
+	if err != nil {
+		return nil, err
+	}
+	if args.BaseURL == "" {
+		args.BaseURL = "http://localhost:" + args.Port
+	}
+	if args.Demo {
+		return newDemoMain(args, passwords, mail)
+	}
+	backend, err := database.New(args.Database)
+	if err != nil {
+		return nil, err
+	}
+	conn := database.Intercept(backend, interceptors(args)...)
+	// The migrations run on the connection itself, since the Supervisor turns
+	// queries away until they are done.
+	migrator, err := migration.New(conn, args.Database.Driver)
+	if err != nil {
+		return nil, err
 	}
+	m := &Main{
+		appSecret:     args.AppSecret,
+		autoMigrate:   args.AutoMigrate || args.Database.Driver == database.DriverSQLite,
+		requireSchema: args.RequireSchema,
+		migrator:      migrator,
+		logger:        log15.New("module", "main"),
+	}
+	supervisorOpt := args.Supervisor
+	supervisorOpt.Prepare = m.prepareDatabase
+	db := database.NewSupervisor(conn, supervisorOpt)
+	store := model.NewSQLStore(db)
+	sessions := newSessionStore(args, store)
+	throttle := model.NewLoginThrottle(store, args.Throttle)
+	m.db = db
+	m.archiver = model.NewArchiver(store, args.Archiver)
+	m.sessions = sessions
+	m.throttle = throttle
+	m.server = server.New(server.Options{
+		Addr:        net.JoinHostPort("", args.Port),
+		DebugAddr:   args.DebugAddr,
+		CookieStore: sessions,
+		Store:       store,
+		Passwords:   passwords,
+		Throttle:    throttle,
+
+		Mailer:               mail,
+		BaseURL:              args.BaseURL,
+		TokenSecret:          appKey(args, "token"),
+		RequireVerifiedEmail: args.RequireVerifiedEmail,
+		TwoFactorRoles:       args.TwoFactorRoles,
+
+		PosterSecret:      appKey(args, "poster"),
+		TrustForwardedFor: args.TrustForwardedFor,
+
+		ReadYourWrites: args.Database.ReadYourWrites,
+		DBStatus:       db.Status,
+	})
+	return m, nil
+}
+
+// appKey returns the key derived from -app-secret for purpose, or nil without
+// a secret, so that the server makes up a random key.
+func appKey(args Arguments, purpose string) []byte {
+	if args.AppSecret == "" {
+		return nil
+	}
+	return cryptoutil.DeriveKey([]byte(args.AppSecret), purpose)
+}
+
+// newSessionStore returns the store of the sign in sessions in store.
+func newSessionStore(args Arguments, store model.Store) *session.Store {
+	opt := args.Sessions
+	opt.TrustForwardedFor = args.TrustForwardedFor
+	return session.NewStore(store, opt)
 }
 
 // Run ...
@@ -75,14 +236,31 @@ func (m *Main) Run() error {
 	signalCtx, cancelFunc := m.createSignalHandler()
 	var wg sync.WaitGroup
 	dbErrCh := make(chan error, 1)
-	go func() {
+	if m.db != nil {
 		wg.Add(1)
-		dbErrCh <- m.runDatabase(signalCtx)
+		go func() {
+			dbErrCh <- m.db.Run(signalCtx)
+			wg.Done()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		m.archiver.Run(signalCtx)
+		wg.Done()
+	}()
+	wg.Add(1)
+	go func() {
+		m.sessions.Run(signalCtx)
+		wg.Done()
+	}()
+	wg.Add(1)
+	go func() {
+		m.throttle.Run(signalCtx)
 		wg.Done()
 	}()
 	serverErrCh := make(chan error, 1)
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		serverErrCh <- m.server.Run(signalCtx)
 		wg.Done()
 	}()
@@ -115,22 +293,46 @@ func (m *Main) createSignalHandler() (context.Context, context.CancelFunc) {
 	return ctx, cancel
 }
 
-func (m *Main) runDatabase(ctx context.Context) error {
-	if err := m.db.Connect(); err != nil {
+// prepareDatabase migrates the schema when autoMigrate is set. The Supervisor
+// runs it once the database is connected, so the server starts degraded and
+// keeps answering while the database is down. With requireSchema, a schema
+// which does not match the migrations of this binary stops the server.
+func (m *Main) prepareDatabase(ctx context.Context) error {
+	if m.autoMigrate {
+		if _, err := m.migrator.Up(ctx); err != nil {
+			return err
+		}
+	}
+	pending, err := m.migrator.Pending(ctx)
+	if err != nil {
 		return err
 	}
-	for {
-		if err := m.db.Ping(); err != nil {
-			return err
+	version, err := m.migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if m.requireSchema {
+		if len(pending) > 0 {
+			return &database.PermanentError{Err: fmt.Errorf("schema is behind by %d migrations, run `migrate up`", len(pending))}
 		}
-		select {
-		case <-time.After(10 * time.Second):
-			// noop
-		case <-ctx.Done():
-			if err := m.db.Disconnect(); err != nil {
-				return err
-			}
-			return ctx.Err()
+		if version > m.migrator.Latest() {
+			return &database.PermanentError{Err: fmt.Errorf("schema version %d is newer than the latest migration %d of this binary", version, m.migrator.Latest())}
 		}
 	}
+	if len(pending) > 0 {
+		m.logger.Warn("Schema has pending migrations", "pending", len(pending))
+	}
+	return nil
+}
+
+func interceptors(args Arguments) []database.Interceptor {
+	logger := log15.New("module", "database")
+	interceptors := []database.Interceptor{database.Metrics()}
+	if args.SlowQueryThreshold > 0 {
+		interceptors = append(interceptors, database.SlowQueryLog(args.SlowQueryThreshold, logger))
+	}
+	if args.Trace {
+		interceptors = append(interceptors, database.Trace(database.NewLogTracer(logger)))
+	}
+	return interceptors
 }
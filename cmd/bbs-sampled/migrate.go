@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/database/migration"
+)
+
+func runMigrate(args Arguments, command string) error {
+	return withMigrator(args, func(ctx context.Context, db database.Database, migrator *migration.Migrator) error {
+		return migrate(ctx, migrator, command)
+	})
+}
+
+func migrate(ctx context.Context, migrator *migration.Migrator, command string) error {
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d %s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		m, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %04d %s\n", m.Version, m.Name)
+		return nil
+	case "redo":
+		m, err := migrator.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("redone %04d %s\n", m.Version, m.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			status := "pending"
+			if s.Applied {
+				status = "applied"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Migration.Version, s.Migration.Name, status, s.AppliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q (up, down, status, redo)", command)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTarget fails its first failures checks, or every check when failures
+// is negative.
+type fakeTarget struct {
+	name string
+	// block makes a failing check wait until its context is done.
+	block bool
+
+	mu       sync.Mutex
+	failures int
+	checks   int
+}
+
+func (t *fakeTarget) String() string {
+	return t.name
+}
+
+func (t *fakeTarget) Check(ctx context.Context) error {
+	t.mu.Lock()
+	t.checks++
+	ready := t.failures == 0
+	if t.failures > 0 {
+		t.failures--
+	}
+	t.mu.Unlock()
+	if ready {
+		return nil
+	}
+	if t.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return errors.New("not ready")
+}
+
+func (t *fakeTarget) checked() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.checks
+}
+
+func newWaiter(any bool) *waiter {
+	return &waiter{
+		checkTimeout: time.Second,
+		minBackoff:   time.Millisecond,
+		maxBackoff:   4 * time.Millisecond,
+		any:          any,
+	}
+}
+
+func TestWaitTargets(t *testing.T) {
+	const deadline = 200 * time.Millisecond
+	tests := []struct {
+		name     string
+		any      bool
+		failures []int
+		want     bool
+	}{
+		{"all ready", false, []int{0, 2, 5}, true},
+		{"all with one never ready", false, []int{0, -1}, false},
+		{"all without targets", false, nil, true},
+		{"any with one never ready", true, []int{-1, 3}, true},
+		{"any with none ready", true, []int{-1, -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targets []Target
+			var fakes []*fakeTarget
+			for i, failures := range tt.failures {
+				target := &fakeTarget{name: fmt.Sprintf("fake%d", i), failures: failures}
+				targets = append(targets, target)
+				fakes = append(fakes, target)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), deadline)
+			defer cancel()
+			start := time.Now()
+			if got := newWaiter(tt.any).waitTargets(ctx, targets); got != tt.want {
+				t.Fatalf("waitTargets() = %v, want %v", got, tt.want)
+			}
+			elapsed := time.Since(start)
+			if tt.want && elapsed >= deadline {
+				t.Errorf("waitTargets() took %v, want the targets ready before the deadline", elapsed)
+			}
+			if !tt.want && (elapsed < deadline || elapsed > deadline+time.Second) {
+				t.Errorf("waitTargets() gave up after %v, want at the %v deadline", elapsed, deadline)
+			}
+			for i, target := range fakes {
+				checks := target.checked()
+				switch failures := tt.failures[i]; {
+				case failures < 0 && checks < 2:
+					t.Errorf("%s checked %d times, want retries until the deadline", target, checks)
+				case failures >= 0 && !tt.any && checks != failures+1:
+					t.Errorf("%s checked %d times, want %d", target, checks, failures+1)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitCheckTimeout(t *testing.T) {
+	target := &fakeTarget{name: "blocking", failures: 3, block: true}
+	w := newWaiter(false)
+	w.checkTimeout = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Each blocking check is given up after checkTimeout, rather than when
+	// the overall deadline expires.
+	if !w.wait(ctx, target) {
+		t.Fatal("wait() = false, want the target ready after its blocking checks")
+	}
+	if checks := target.checked(); checks != 4 {
+		t.Errorf("checked %d times, want 4", checks)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/database/migration"
+)
+
+func TestSchemaTarget(t *testing.T) {
+	ctx := context.Background()
+	opt := database.Options{Driver: database.DriverSQLite, Path: filepath.Join(t.TempDir(), "bbs.db")}
+	db := database.NewSQLite(opt)
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Disconnect()
+	m, err := migration.New(db, database.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := m.Latest()
+	target, err := parseTarget("schema:1", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database which was never migrated is not ready, and checking it
+	// does not create schema_migrations as the migrator would.
+	if err := target.Check(ctx); err == nil {
+		t.Error("Check() of a database never migrated succeeded")
+	}
+	rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE name = 'schema_migrations'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.Next() {
+		t.Error("Check() created schema_migrations")
+	}
+	rows.Close()
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		version int64
+		ready   bool
+	}{
+		{1, true},
+		{latest, true},
+		{latest + 1, false},
+	}
+	for _, tt := range tests {
+		target := &schemaTarget{opt: opt, version: tt.version}
+		if err := target.Check(ctx); (err == nil) != tt.ready {
+			t.Errorf("Check() of %s error = %v, want ready %v", target, err, tt.ready)
+		}
+	}
+}
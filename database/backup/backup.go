@@ -0,0 +1,228 @@
+// Package backup writes and restores logical backups of the board which
+// can be restored into any of the supported databases.
+//
+// An archive is a gzip compressed JSON lines stream. The first line is the
+// Manifest, followed by one line per row in the order of the tables, and the
+// last line holds the row count and the SHA-256 checksum of the row lines of
+// every table.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// FormatVersion is the version of the archive format written by Write.
+const FormatVersion = 1
+
+// Manifest describes an archive.
+type Manifest struct {
+	Format        int       `json:"format"`
+	CreatedAt     time.Time `json:"created_at"`
+	Driver        string    `json:"driver"`
+	SchemaVersion int64     `json:"schema_version"`
+	PersonalData  bool      `json:"personal_data"`
+	Tables        []string  `json:"tables"`
+}
+
+// Checksum ...
+type Checksum struct {
+	Rows   int64  `json:"rows"`
+	SHA256 string `json:"sha256"`
+}
+
+type manifestLine struct {
+	Manifest *Manifest `json:"manifest"`
+}
+
+type rowLine struct {
+	Table string `json:"table"`
+	Row   Row    `json:"row"`
+}
+
+type checksumLine struct {
+	Checksums map[string]Checksum `json:"checksums"`
+}
+
+// Options ...
+type Options struct {
+	Driver        string
+	SchemaVersion int64
+	// ExcludePersonalData redacts the columns which hold personal data.
+	ExcludePersonalData bool
+}
+
+// Write streams every registered table of db into w. The tables are read in
+// one transaction so that the archive is consistent, without the default
+// query timeout, which whole tables would outlast; the deadline of ctx still
+// applies.
+func Write(ctx context.Context, db database.Database, w io.Writer, opt Options) (map[string]Checksum, error) {
+	ctx = database.WithoutTimeout(ctx)
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	manifest := &Manifest{
+		Format:        FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Driver:        opt.Driver,
+		SchemaVersion: opt.SchemaVersion,
+		PersonalData:  !opt.ExcludePersonalData,
+	}
+	for _, t := range tables {
+		manifest.Tables = append(manifest.Tables, t.Name)
+	}
+	if err := writeLine(bw, nil, manifestLine{Manifest: manifest}); err != nil {
+		return nil, err
+	}
+	checksums := map[string]Checksum{}
+	txOpts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	if opt.Driver == database.DriverSQLite {
+		// Transactions are serializable and the options are not supported.
+		txOpts = nil
+	}
+	tx, err := db.BeginTx(ctx, txOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, t := range tables {
+		sum, err := writeTable(ctx, tx, bw, t, opt.ExcludePersonalData)
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %v", t.Name, err)
+		}
+		checksums[t.Name] = sum
+	}
+	if err := writeLine(bw, nil, checksumLine{Checksums: checksums}); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+func writeTable(ctx context.Context, q database.Querier, w io.Writer, t Table, redact bool) (Checksum, error) {
+	names := columnNames(t)
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s ORDER BY id", strings.Join(names, ", "), t.Name))
+	if err != nil {
+		return Checksum{}, err
+	}
+	defer rows.Close()
+	h := sha256.New()
+	var count int64
+	values := make([]interface{}, len(t.Columns))
+	dest := make([]interface{}, len(t.Columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return Checksum{}, err
+		}
+		row := Row{}
+		for i, c := range t.Columns {
+			v, err := normalize(c, values[i])
+			if err != nil {
+				return Checksum{}, err
+			}
+			row[c.Name] = v
+		}
+		if redact {
+			for _, c := range t.Columns {
+				if c.Redact != nil {
+					row[c.Name] = c.Redact(row)
+				}
+			}
+		}
+		if err := writeLine(w, h, rowLine{Table: t.Name, Row: row}); err != nil {
+			return Checksum{}, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return Checksum{}, err
+	}
+	return Checksum{Rows: count, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// writeLine writes v as a JSON line to w and, when h is not nil, to h.
+func writeLine(w io.Writer, h hash.Hash, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if h != nil {
+		h.Write(b)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// normalize converts a value scanned from any of the drivers to the
+// representation of c's type in an archive.
+func normalize(c Column, v interface{}) (interface{}, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch c.Type {
+	case Int:
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case Text:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case Bool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case Time:
+		switch v := v.(type) {
+		case time.Time:
+			return v.Format(TimeFormat), nil
+		case string:
+			for _, layout := range []string{TimeFormat, time.RFC3339Nano} {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t.Format(TimeFormat), nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("column %s: unexpected value %v (%T)", c.Name, v, v)
+}
+
+func columnNames(t Table) []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/database/migration"
+)
+
+// newTestDB returns a migrated SQLite database in a temporary file and its
+// schema version.
+func newTestDB(t *testing.T) (database.Database, int64) {
+	db := database.NewSQLite(database.Options{Path: filepath.Join(t.TempDir(), "bbs.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	m, err := migration.New(db, database.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db, m.Latest()
+}
+
+// seed fills db with a few rows of every table.
+func seed(t *testing.T, db database.Database) {
+	for _, query := range []string{
+		`INSERT INTO users (name, email, password_hash) VALUES ('alice', 'alice@example.com', 'hash1')`,
+		`INSERT INTO users (name, email, password_hash) VALUES ('bob', 'bob@example.com', 'hash2')`,
+		`INSERT INTO messages (user_id, message, created_at) VALUES (1, 'hello', '2026-10-18 05:17:26')`,
+		`INSERT INTO messages (user_id, message, created_at) VALUES (2, 'こんにちは', '2026-10-18 05:18:00')`,
+		`INSERT INTO messages (user_id, message, created_at) VALUES (1, NULL, '2026-10-18 05:19:00')`,
+	} {
+		if _, err := db.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+}
+
+// dump returns the rows of every registered table of db as they are written
+// to an archive.
+func dump(t *testing.T, db database.Database) map[string][]string {
+	tables := map[string][]string{}
+	for _, table := range Tables() {
+		var b bytes.Buffer
+		if _, err := writeTable(context.Background(), db, &b, table, false); err != nil {
+			t.Fatalf("dump %s: %v", table.Name, err)
+		}
+		tables[table.Name] = strings.SplitAfter(b.String(), "\n")
+		tables[table.Name] = tables[table.Name][:len(tables[table.Name])-1]
+	}
+	return tables
+}
+
+func TestRoundTrip(t *testing.T) {
+	src, version := newTestDB(t)
+	seed(t, src)
+	ctx := context.Background()
+	var archive bytes.Buffer
+	written, err := Write(ctx, src, &archive, Options{Driver: database.DriverSQLite, SchemaVersion: version})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, _ := newTestDB(t)
+	manifest, restored, err := Restore(ctx, dst, &archive, RestoreOptions{Driver: database.DriverSQLite, SchemaVersion: version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.SchemaVersion != version || manifest.Driver != database.DriverSQLite {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	if !reflect.DeepEqual(restored, written) {
+		t.Errorf("restored checksums = %v, want %v", restored, written)
+	}
+	if got, want := dump(t, dst), dump(t, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored tables =\n%v\nwant\n%v", got, want)
+	}
+	// The sequences continue after the restored ids.
+	id, err := dst.InsertContext(ctx, `INSERT INTO users (name, email, password_hash) VALUES ('carol', 'carol@example.com', 'hash3')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 3 {
+		t.Errorf("id of a new user = %d, want 3", id)
+	}
+}
+
+func TestRestoreRejects(t *testing.T) {
+	src, version := newTestDB(t)
+	seed(t, src)
+	ctx := context.Background()
+	var archive bytes.Buffer
+	if _, err := Write(ctx, src, &archive, Options{Driver: database.DriverSQLite, SchemaVersion: version}); err != nil {
+		t.Fatal(err)
+	}
+	valid := archive.Bytes()
+
+	// recompress returns the archive with its uncompressed content edited
+	// by edit.
+	recompress := func(edit func(string) string) []byte {
+		zr, err := gzip.NewReader(bytes.NewReader(valid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		zw := gzip.NewWriter(&out)
+		zw.Write([]byte(edit(string(b))))
+		zw.Close()
+		return out.Bytes()
+	}
+	corrupt := append([]byte(nil), valid...)
+	corrupt[len(corrupt)/2] ^= 0xff
+
+	tests := []struct {
+		name    string
+		archive []byte
+		version int64
+	}{
+		{"not gzip", []byte("users,messages\n"), version},
+		{"truncated", valid[:len(valid)-20], version},
+		{"corrupt", corrupt, version},
+		{"without checksums", recompress(func(s string) string {
+			return s[:strings.LastIndex(strings.TrimSuffix(s, "\n"), "\n")+1]
+		}), version},
+		{"tampered row", recompress(func(s string) string {
+			return strings.Replace(s, "hello", "hellp", 1)
+		}), version},
+		{"data after the checksums", recompress(func(s string) string {
+			return s + "{}\n"
+		}), version},
+		{"newer schema", valid, version - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, _ := newTestDB(t)
+			want := dump(t, dst)
+			_, _, err := Restore(ctx, dst, bytes.NewReader(tt.archive), RestoreOptions{Driver: database.DriverSQLite, SchemaVersion: tt.version})
+			if err == nil {
+				t.Fatal("Restore() succeeded")
+			}
+			if got := dump(t, dst); !reflect.DeepEqual(got, want) {
+				t.Errorf("tables after a failed restore =\n%v\nwant\n%v", got, want)
+			}
+		})
+	}
+}
+
+func TestRestoreNotEmpty(t *testing.T) {
+	src, version := newTestDB(t)
+	seed(t, src)
+	ctx := context.Background()
+	var archive bytes.Buffer
+	if _, err := Write(ctx, src, &archive, Options{Driver: database.DriverSQLite, SchemaVersion: version}); err != nil {
+		t.Fatal(err)
+	}
+	want := dump(t, src)
+	_, _, err := Restore(ctx, src, &archive, RestoreOptions{Driver: database.DriverSQLite, SchemaVersion: version})
+	if err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Fatalf("Restore() into a filled database error = %v, want not empty", err)
+	}
+	if got := dump(t, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("tables after a refused restore =\n%v\nwant\n%v", got, want)
+	}
+}
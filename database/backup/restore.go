@@ -0,0 +1,217 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// RestoreOptions ...
+type RestoreOptions struct {
+	Driver string
+	// SchemaVersion is the migration version of the target database, which
+	// must not be older than the one of the archive.
+	SchemaVersion int64
+}
+
+// Restore reads an archive written by Write from r into db, whose tables must
+// be empty. Nothing is restored unless the whole archive is read and its
+// checksums match. Like Write it runs without the default query timeout.
+func Restore(ctx context.Context, db database.Database, r io.Reader, opt RestoreOptions) (*Manifest, map[string]Checksum, error) {
+	ctx = database.WithoutTimeout(ctx)
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+	br := bufio.NewReader(zr)
+	var head manifestLine
+	if line, err := readLine(br); err != nil {
+		return nil, nil, err
+	} else if err := json.Unmarshal(line, &head); err != nil || head.Manifest == nil {
+		return nil, nil, fmt.Errorf("backup: missing manifest")
+	}
+	manifest := head.Manifest
+	if err := checkManifest(manifest, opt); err != nil {
+		return nil, nil, err
+	}
+	restored := map[string]Checksum{}
+	err = db.WithTx(ctx, func(tx database.Tx) error {
+		if err := prepareTables(ctx, tx, manifest.Tables); err != nil {
+			return err
+		}
+		hashes := map[string]hash.Hash{}
+		counts := map[string]int64{}
+		for {
+			line, err := readLine(br)
+			if err == io.EOF {
+				return fmt.Errorf("backup: archive is truncated")
+			}
+			if err != nil {
+				return err
+			}
+			var trailer checksumLine
+			if bytes.HasPrefix(line, []byte(`{"checksums"`)) {
+				if err := json.Unmarshal(line, &trailer); err != nil {
+					return err
+				}
+				for _, name := range manifest.Tables {
+					got := Checksum{Rows: counts[name], SHA256: hex.EncodeToString(sum(hashes[name]))}
+					if want := trailer.Checksums[name]; got != want {
+						return fmt.Errorf("backup: checksum mismatch for %s: %d rows %s, want %d rows %s", name, got.Rows, got.SHA256, want.Rows, want.SHA256)
+					}
+					restored[name] = got
+				}
+				if _, err := readLine(br); err != io.EOF {
+					return fmt.Errorf("backup: unexpected data after the checksums")
+				}
+				return resetSequences(ctx, tx, opt.Driver, manifest.Tables)
+			}
+			table, err := insertRow(ctx, tx, line)
+			if err != nil {
+				return err
+			}
+			if hashes[table] == nil {
+				hashes[table] = sha256.New()
+			}
+			hashes[table].Write(line)
+			counts[table]++
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, restored, nil
+}
+
+func checkManifest(m *Manifest, opt RestoreOptions) error {
+	if m.Format < 1 || m.Format > FormatVersion {
+		return fmt.Errorf("backup: unsupported archive format %d", m.Format)
+	}
+	if m.SchemaVersion > opt.SchemaVersion {
+		return fmt.Errorf("backup: archive schema version %d is newer than the database's %d", m.SchemaVersion, opt.SchemaVersion)
+	}
+	for _, name := range m.Tables {
+		if _, ok := lookupTable(name); !ok {
+			return fmt.Errorf("backup: unknown table %q", name)
+		}
+	}
+	return nil
+}
+
+// prepareTables makes sure that the tables are empty, except for the rows the
+// migrations inserted into seeded tables, which are deleted.
+func prepareTables(ctx context.Context, q database.Querier, names []string) error {
+	var seeded []string
+	for _, name := range names {
+		if t, _ := lookupTable(name); t.Seeded {
+			seeded = append(seeded, name)
+			continue
+		}
+		if err := checkEmpty(ctx, q, name); err != nil {
+			return err
+		}
+	}
+	for _, name := range seeded {
+		if _, err := q.ExecuteContext(ctx, fmt.Sprintf("DELETE FROM %s", name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkEmpty(ctx context.Context, q database.Querier, table string) error {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT id FROM %s LIMIT 1", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		return fmt.Errorf("backup: table %s is not empty", table)
+	}
+	return rows.Err()
+}
+
+// insertRow inserts the row of line and returns its table name.
+func insertRow(ctx context.Context, q database.Querier, line []byte) (string, error) {
+	var row rowLine
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&row); err != nil {
+		return "", err
+	}
+	t, ok := lookupTable(row.Table)
+	if !ok {
+		return "", fmt.Errorf("backup: unknown table %q", row.Table)
+	}
+	var names, binds []string
+	var args []interface{}
+	for _, c := range t.Columns {
+		v, ok := row.Row[c.Name]
+		if !ok {
+			// Written before the column was added, so its default is used.
+			continue
+		}
+		if n, ok := v.(json.Number); ok {
+			i, err := n.Int64()
+			if err != nil {
+				return "", fmt.Errorf("backup: %s.%s: %v", t.Name, c.Name, err)
+			}
+			v = i
+		}
+		names = append(names, c.Name)
+		binds = append(binds, "?")
+		args = append(args, v)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.Name, strings.Join(names, ", "), strings.Join(binds, ", "))
+	if _, err := q.ExecuteContext(ctx, query, args...); err != nil {
+		return "", fmt.Errorf("backup: restore %s: %v", t.Name, err)
+	}
+	return t.Name, nil
+}
+
+// resetSequences moves the id sequences past the restored ids. MySQL and
+// SQLite do so on their own when ids are inserted explicitly.
+func resetSequences(ctx context.Context, q database.Querier, driver string, names []string) error {
+	if driver != database.DriverPostgres {
+		return nil
+	}
+	for _, name := range names {
+		if t, _ := lookupTable(name); !t.Serial {
+			continue
+		}
+		query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false)`, name)
+		rows, err := q.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// readLine returns the next line of r including its newline.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		return nil, fmt.Errorf("backup: archive is truncated")
+	}
+	return line, err
+}
+
+func sum(h hash.Hash) []byte {
+	if h == nil {
+		return sha256.New().Sum(nil)
+	}
+	return h.Sum(nil)
+}
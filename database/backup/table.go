@@ -0,0 +1,171 @@
+package backup
+
+import (
+	"fmt"
+)
+
+// ColumnType is the type of the values of a column in an archive.
+type ColumnType int
+
+const (
+	// Int columns are written as JSON numbers.
+	Int ColumnType = iota
+	// Text columns are written as JSON strings.
+	Text
+	// Time columns are written as strings in TimeFormat.
+	Time
+	// Bool columns are written as JSON booleans.
+	Bool
+)
+
+// TimeFormat is the layout of Time values in an archive.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// Column ...
+type Column struct {
+	Name string
+	Type ColumnType
+	// Redact returns the value written instead of the column value when
+	// personal data is excluded, or is nil for columns without personal data.
+	Redact func(row Row) interface{}
+}
+
+// Row is a table row keyed by column name.
+type Row map[string]interface{}
+
+// Table describes a table included in the backups.
+type Table struct {
+	Name    string
+	Columns []Column
+	// Serial is set when the id column is generated by the database.
+	Serial bool
+	// Seeded is set when the migrations insert rows, which are replaced by
+	// the rows of an archive.
+	Seeded bool
+}
+
+// tables are the registered tables, in the order they are restored.
+var tables []Table
+
+// Register adds t to the backups. Tables must be registered after the tables
+// they reference.
+func Register(t Table) {
+	for _, registered := range tables {
+		if registered.Name == t.Name {
+			panic(fmt.Sprintf("backup: table %q registered twice", t.Name))
+		}
+	}
+	tables = append(tables, t)
+}
+
+// Tables returns the registered tables.
+func Tables() []Table {
+	return append([]Table(nil), tables...)
+}
+
+func lookupTable(name string) (Table, bool) {
+	for _, t := range tables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Table{}, false
+}
+
+func init() {
+	Register(Table{
+		Name:   "users",
+		Serial: true,
+		Columns: []Column{
+			{Name: "id", Type: Int},
+			{Name: "name", Type: Text},
+			{Name: "email", Type: Text, Redact: func(row Row) interface{} {
+				// Emails are unique, so they are replaced instead of cleared.
+				return fmt.Sprintf("user%v@example.invalid", row["id"])
+			}},
+			{Name: "password_hash", Type: Text, Redact: func(Row) interface{} {
+				return ""
+			}},
+			{Name: "role", Type: Text},
+			{Name: "email_verified_at", Type: Time},
+			// Clearing the TOTP secret withdraws two-factor sign in, which
+			// the cleared password hash makes moot.
+			{Name: "totp_secret", Type: Text, Redact: func(Row) interface{} {
+				return ""
+			}},
+			{Name: "totp_last_step", Type: Int},
+		},
+	})
+	Register(Table{
+		Name:   "recovery_codes",
+		Serial: true,
+		Columns: []Column{
+			{Name: "id", Type: Int},
+			{Name: "user_id", Type: Int},
+			{Name: "code_hash", Type: Text, Redact: func(Row) interface{} {
+				return ""
+			}},
+		},
+	})
+	Register(Table{
+		Name:   "boards",
+		Serial: true,
+		Seeded: true,
+		Columns: []Column{
+			{Name: "id", Type: Int},
+			{Name: "slug", Type: Text},
+			{Name: "title", Type: Text},
+			{Name: "description", Type: Text},
+			{Name: "sort_order", Type: Int},
+			{Name: "post_policy", Type: Text},
+			{Name: "max_message_length", Type: Int},
+			{Name: "max_posts_per_thread", Type: Int},
+			{Name: "read_only", Type: Bool},
+			{Name: "anonymous", Type: Bool},
+			{Name: "allow_guests", Type: Bool},
+			{Name: "default_name", Type: Text},
+		},
+	})
+	Register(Table{
+		Name:   "threads",
+		Serial: true,
+		Columns: []Column{
+			{Name: "id", Type: Int},
+			{Name: "board_id", Type: Int},
+			{Name: "subject", Type: Text},
+			{Name: "created_at", Type: Time},
+			{Name: "bumped_at", Type: Time},
+			{Name: "last_post_no", Type: Int},
+			{Name: "closed_at", Type: Time},
+			{Name: "archived_at", Type: Time},
+		},
+	})
+	Register(Table{
+		Name:   "messages",
+		Serial: true,
+		Columns: []Column{
+			{Name: "id", Type: Int},
+			{Name: "board_id", Type: Int},
+			{Name: "thread_id", Type: Int},
+			{Name: "post_no", Type: Int},
+			{Name: "user_id", Type: Int},
+			{Name: "name", Type: Text},
+			{Name: "tripcode", Type: Text},
+			{Name: "poster_id", Type: Text},
+			{Name: "ip_hash", Type: Text, Redact: func(row Row) interface{} {
+				// Guest posts are told from system notices by their hash, so
+				// it is replaced by one which links no posts.
+				if row["ip_hash"] == "" {
+					return ""
+				}
+				return fmt.Sprintf("redacted-%v", row["id"])
+			}},
+			{Name: "sage", Type: Bool},
+			{Name: "message", Type: Text},
+			{Name: "created_at", Type: Time},
+		},
+	})
+	// sessions are left out: they are worthless without the cookies of the
+	// browsers, so a restore signs everyone out. login_attempts only matter
+	// for minutes.
+}
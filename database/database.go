@@ -1,29 +1,185 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
 	// ErrConnNotExist ...
 	ErrConnNotExist = errors.New("database: connection is not exists")
+
+	// ErrTimeout ...
+	ErrTimeout = errors.New("database: query timeout")
 )
 
+// Querier ...
+type Querier interface {
+	// QueryContext ...
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error)
+
+	// ExecuteContext ...
+	ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+
+	// InsertContext executes an INSERT statement and returns the generated id.
+	InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error)
+}
+
+// Tx ...
+type Tx interface {
+	Querier
+
+	// Commit ...
+	Commit() error
+
+	// Rollback ...
+	Rollback() error
+}
+
+// DuplicateError is returned when a statement violates a unique constraint.
+type DuplicateError struct {
+	Err error
+}
+
+// Error ...
+func (e *DuplicateError) Error() string {
+	return "database: duplicate entry: " + e.Err.Error()
+}
+
+// Unwrap ...
+func (e *DuplicateError) Unwrap() error {
+	return e.Err
+}
+
+// IsDuplicate ...
+func IsDuplicate(err error) bool {
+	var dup *DuplicateError
+	return errors.As(err, &dup)
+}
+
 // Database ...
 type Database interface {
 	// Connnect ...
 	Connect() error
 
 	// Query ...
-	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Query(query string, args ...interface{}) (*Rows, error)
+
+	// QueryContext ...
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error)
 
 	// Execute ...
 	Execute(query string, args ...interface{}) (sql.Result, error)
 
+	// ExecuteContext ...
+	ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+
+	// InsertContext executes an INSERT statement and returns the generated id.
+	InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error)
+
 	// Disconnect ...
 	Disconnect() error
 
 	// Ping ...
 	Ping() error
+
+	// PingContext ...
+	PingContext(ctx context.Context) error
+
+	// BeginTx starts a transaction. The default isolation level is used when opts is nil.
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error)
+
+	// WithTx runs fn in a transaction which is committed when fn returns nil
+	// and rolled back when fn returns an error or panics.
+	WithTx(ctx context.Context, fn func(Tx) error) error
+}
+
+// Rows wraps sql.Rows so that resources bound to the query, such as the
+// context deadline and the prepared statement, are released together with the rows.
+// Like the queries, Err and Scan return ErrTimeout once the deadline expired.
+type Rows struct {
+	*sql.Rows
+	// ctx is the context of the query.
+	ctx     context.Context
+	release func()
+}
+
+// Err ...
+func (r *Rows) Err() error {
+	return r.convertError(r.Rows.Err())
+}
+
+// Scan ...
+func (r *Rows) Scan(dest ...interface{}) error {
+	return r.convertError(r.Rows.Scan(dest...))
+}
+
+func (r *Rows) convertError(err error) error {
+	if r.ctx == nil {
+		return err
+	}
+	return convertError(r.ctx, err)
+}
+
+// Close ...
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	if r.release != nil {
+		r.release()
+		r.release = nil
+	}
+	return err
+}
+
+// runTx ...
+func runTx(ctx context.Context, db Database, fn func(Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+	return fn(tx)
+}
+
+type noTimeoutKey struct{}
+
+// WithoutTimeout lifts the default timeout from the statements run with ctx,
+// for long running ones such as migrations. The deadline of ctx still applies.
+func WithoutTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTimeoutKey{}, true)
+}
+
+// withTimeout applies the default timeout unless ctx already has an earlier
+// deadline or is marked by WithoutTimeout.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 || ctx.Value(noTimeoutKey{}) != nil {
+		return context.WithCancel(ctx)
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// convertError replaces the error caused by an expired deadline with ErrTimeout.
+func convertError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
 }
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// dialect describes how a SQL backend differs from the MySQL-style queries
+// written by the models.
+type dialect struct {
+	// bindvar rewrites the `?` placeholders to $1, $2, ... when set.
+	bindvar bool
+
+	// returning makes inserts report the new id with `RETURNING id`
+	// instead of sql.Result.LastInsertId.
+	returning bool
+
+	// onConflict rewrites the upserts `ON DUPLICATE KEY UPDATE` to `ON
+	// CONFLICT (id) DO UPDATE SET`, so they must conflict on the id column
+	// and set the new values with placeholders rather than VALUES().
+	onConflict bool
+
+	// isDuplicate reports whether err is a unique constraint violation.
+	isDuplicate func(err error) bool
+}
+
+// rewrite turns a MySQL-style query into one of the backend.
+func (d dialect) rewrite(query string) string {
+	if d.onConflict {
+		query = strings.Replace(query, "ON DUPLICATE KEY UPDATE", "ON CONFLICT (id) DO UPDATE SET", 1)
+	}
+	return d.rebind(query)
+}
+
+// rebind ...
+func (d dialect) rebind(query string) string {
+	if !d.bindvar {
+		return query
+	}
+	var buf strings.Builder
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '?':
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// convertError ...
+func (d dialect) convertError(ctx context.Context, err error) error {
+	err = convertError(ctx, err)
+	if err != nil && d.isDuplicate != nil && d.isDuplicate(err) {
+		return &DuplicateError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package database
+
+import "testing"
+
+func TestDialectRewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect dialect
+		query   string
+		want    string
+	}{
+		{"mysql unchanged", mysqlDialect, "SELECT * FROM users WHERE id=? AND email=?", "SELECT * FROM users WHERE id=? AND email=?"},
+		{"placeholders", postgresDialect, "SELECT * FROM users WHERE id=? AND email=?", "SELECT * FROM users WHERE id=$1 AND email=$2"},
+		{"no placeholders", postgresDialect, "SELECT 1", "SELECT 1"},
+		{"single quoted", postgresDialect, "SELECT '?' FROM t WHERE a=?", "SELECT '?' FROM t WHERE a=$1"},
+		{"double quoted", postgresDialect, `SELECT "a?" FROM t WHERE a=?`, `SELECT "a?" FROM t WHERE a=$1`},
+		{"backquoted", postgresDialect, "SELECT `a?` FROM t WHERE a=?", "SELECT `a?` FROM t WHERE a=$1"},
+		{"quote of the other kind", postgresDialect, `SELECT 'it"s ?' FROM t WHERE a=?`, `SELECT 'it"s ?' FROM t WHERE a=$1`},
+		{"multibyte", postgresDialect, "SELECT 'テスト?' WHERE a=? AND b=?", "SELECT 'テスト?' WHERE a=$1 AND b=$2"},
+		{
+			"upsert",
+			postgresDialect,
+			"INSERT INTO t(id, n) VALUES (?, ?) ON DUPLICATE KEY UPDATE n = ?",
+			"INSERT INTO t(id, n) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET n = $3",
+		},
+		{
+			"upsert without bindvars",
+			sqliteDialect,
+			"INSERT INTO t(id, n) VALUES (?, ?) ON DUPLICATE KEY UPDATE n = ?",
+			"INSERT INTO t(id, n) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET n = ?",
+		},
+		{
+			"mysql upsert unchanged",
+			mysqlDialect,
+			"INSERT INTO t(id, n) VALUES (?, ?) ON DUPLICATE KEY UPDATE n = ?",
+			"INSERT INTO t(id, n) VALUES (?, ?) ON DUPLICATE KEY UPDATE n = ?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.rewrite(tt.query); got != tt.want {
+				t.Errorf("rewrite(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
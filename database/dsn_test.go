@@ -0,0 +1,116 @@
+package database
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestMySQLDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Options
+		check   func(t *testing.T, cfg *mysql.Config)
+		wantErr bool
+	}{
+		{
+			name: "special characters",
+			opt:  Options{Addr: "db:3306", Name: "bbs", User: "bbs", Password: "p@ss/w:rd?&="},
+			check: func(t *testing.T, cfg *mysql.Config) {
+				if cfg.Passwd != "p@ss/w:rd?&=" || cfg.Addr != "db:3306" || cfg.DBName != "bbs" {
+					t.Errorf("config = %s@%s/%s password %q", cfg.User, cfg.Addr, cfg.DBName, cfg.Passwd)
+				}
+				if !cfg.ParseTime || cfg.Loc != time.UTC {
+					t.Errorf("ParseTime = %v, Loc = %v, want true, UTC", cfg.ParseTime, cfg.Loc)
+				}
+			},
+		},
+		{
+			name: "charset, collation and timeouts",
+			opt:  Options{Addr: "db:3306", Charset: "utf8mb4", Collation: "utf8mb4_bin", Location: "Asia/Tokyo", DialTimeout: 5 * time.Second, ReadTimeout: time.Minute},
+			check: func(t *testing.T, cfg *mysql.Config) {
+				if cfg.Params["charset"] != "utf8mb4" || cfg.Collation != "utf8mb4_bin" {
+					t.Errorf("charset = %q, collation = %q", cfg.Params["charset"], cfg.Collation)
+				}
+				if cfg.Loc.String() != "Asia/Tokyo" {
+					t.Errorf("Loc = %v, want Asia/Tokyo", cfg.Loc)
+				}
+				if cfg.Timeout != 5*time.Second || cfg.ReadTimeout != time.Minute {
+					t.Errorf("Timeout = %v, ReadTimeout = %v", cfg.Timeout, cfg.ReadTimeout)
+				}
+			},
+		},
+		{
+			name: "tls",
+			opt:  Options{Addr: "db:3306", TLSMode: TLSRequire},
+			check: func(t *testing.T, cfg *mysql.Config) {
+				if cfg.TLSConfig != mysqlTLSConfigName {
+					t.Errorf("TLSConfig = %q, want %q", cfg.TLSConfig, mysqlTLSConfigName)
+				}
+			},
+		},
+		{name: "unknown tls mode", opt: Options{Addr: "db:3306", TLSMode: "sometimes"}, wantErr: true},
+		{name: "unknown location", opt: Options{Addr: "db:3306", Location: "Nowhere/Town"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := mysqlDSN(tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mysqlDSN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			cfg, err := mysql.ParseDSN(dsn)
+			if err != nil {
+				t.Fatalf("ParseDSN(%q) error = %v", dsn, err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestPostgresDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Options
+		want    url.Values
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			opt:  Options{Addr: "db:5432", Name: "bbs", User: "bbs", Password: "p@ss/w:rd?&="},
+			want: url.Values{"sslmode": {"disable"}},
+		},
+		{
+			name: "tls and timeouts",
+			opt:  Options{Addr: "db:5432", Name: "bbs", User: "bbs", TLSMode: TLSVerifyFull, TLSCAFile: "/etc/ca.pem", DialTimeout: 1500 * time.Millisecond, Location: "UTC"},
+			want: url.Values{"sslmode": {"verify-full"}, "sslrootcert": {"/etc/ca.pem"}, "connect_timeout": {"2"}, "timezone": {"UTC"}},
+		},
+		{name: "unknown tls mode", opt: Options{TLSMode: "sometimes"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := postgresDSN(tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("postgresDSN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			u, err := url.Parse(dsn)
+			if err != nil {
+				t.Fatalf("url.Parse(%q) error = %v", dsn, err)
+			}
+			password, _ := u.User.Password()
+			if u.User.Username() != tt.opt.User || password != tt.opt.Password || u.Host != tt.opt.Addr || u.Path != "/"+tt.opt.Name {
+				t.Errorf("postgresDSN() = %q does not keep the credentials and address", dsn)
+			}
+			if got := u.Query(); got.Encode() != tt.want.Encode() {
+				t.Errorf("postgresDSN() parameters = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
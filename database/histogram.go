@@ -0,0 +1,78 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the histogram buckets.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Histogram is a latency histogram which is published with expvar.
+type Histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	errors uint64
+	sum    time.Duration
+}
+
+// NewHistogram ...
+func NewHistogram() *Histogram {
+	return &Histogram{
+		counts: make([]uint64, len(latencyBuckets)+1),
+	}
+}
+
+// Observe ...
+func (h *Histogram) Observe(d time.Duration, err error) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if err != nil {
+		h.errors++
+	}
+}
+
+// String implements expvar.Var.
+func (h *Histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"count":%d,"errors":%d,"sum_seconds":%f,"buckets":{`, h.count, h.errors, h.sum.Seconds())
+	var cumulative uint64
+	for i, n := range h.counts {
+		cumulative += n
+		le := "+Inf"
+		if i < len(latencyBuckets) {
+			le = fmt.Sprintf("%g", latencyBuckets[i].Seconds())
+		}
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `"%s":%d`, le, cumulative)
+	}
+	buf.WriteString("}}")
+	return buf.String()
+}
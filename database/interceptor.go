@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const (
+	// OpQuery ...
+	OpQuery = "query"
+	// OpExecute ...
+	OpExecute = "execute"
+	// OpInsert ...
+	OpInsert = "insert"
+	// OpPing ...
+	OpPing = "ping"
+	// OpBegin ...
+	OpBegin = "begin"
+	// OpCommit ...
+	OpCommit = "commit"
+	// OpRollback ...
+	OpRollback = "rollback"
+)
+
+type queryNameKey struct{}
+
+// WithQueryName labels the statements run with ctx, e.g. "messages.find_all".
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+// QueryName returns the label set by WithQueryName or "unnamed".
+func QueryName(ctx context.Context) string {
+	if name, ok := ctx.Value(queryNameKey{}).(string); ok {
+		return name
+	}
+	return "unnamed"
+}
+
+// Call describes a database operation passed to the interceptors.
+type Call struct {
+	Name  string
+	Op    string
+	Query string
+	Args  []interface{}
+}
+
+// Invoker runs the intercepted operation.
+type Invoker func(ctx context.Context) error
+
+// Interceptor is run around every operation of an Intercepted database and
+// must call next to run the operation.
+type Interceptor func(ctx context.Context, call *Call, next Invoker) error
+
+// chain composes interceptors so that the first one is the outermost.
+func chain(interceptors []Interceptor) Interceptor {
+	return func(ctx context.Context, call *Call, next Invoker) error {
+		invoker := next
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], invoker
+			invoker = func(ctx context.Context) error {
+				return interceptor(ctx, call, inner)
+			}
+		}
+		return invoker(ctx)
+	}
+}
+
+// Intercepted is a Database which runs interceptors around the operations of
+// another Database. Queries are measured until their rows are returned.
+type Intercepted struct {
+	db          Database
+	interceptor Interceptor
+}
+
+// Intercept ...
+func Intercept(db Database, interceptors ...Interceptor) *Intercepted {
+	return &Intercepted{
+		db:          db,
+		interceptor: chain(interceptors),
+	}
+}
+
+func (i *Intercepted) invoke(ctx context.Context, op, query string, args []interface{}, fn Invoker) error {
+	call := &Call{
+		Name:  QueryName(ctx),
+		Op:    op,
+		Query: query,
+		Args:  args,
+	}
+	return i.interceptor(ctx, call, fn)
+}
+
+// Connect ...
+func (i *Intercepted) Connect() error {
+	return i.db.Connect()
+}
+
+// Query ...
+func (i *Intercepted) Query(query string, args ...interface{}) (*Rows, error) {
+	return i.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext ...
+func (i *Intercepted) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	return interceptQuery(ctx, i, i.db, query, args)
+}
+
+// Execute ...
+func (i *Intercepted) Execute(query string, args ...interface{}) (sql.Result, error) {
+	return i.ExecuteContext(context.Background(), query, args...)
+}
+
+// ExecuteContext ...
+func (i *Intercepted) ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return interceptExecute(ctx, i, i.db, query, args)
+}
+
+// InsertContext ...
+func (i *Intercepted) InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return interceptInsert(ctx, i, i.db, query, args)
+}
+
+// Disconnect ...
+func (i *Intercepted) Disconnect() error {
+	return i.db.Disconnect()
+}
+
+// Ping ...
+func (i *Intercepted) Ping() error {
+	return i.PingContext(context.Background())
+}
+
+// PingContext ...
+func (i *Intercepted) PingContext(ctx context.Context) error {
+	return i.invoke(ctx, OpPing, "", nil, func(ctx context.Context) error {
+		return i.db.PingContext(ctx)
+	})
+}
+
+// BeginTx ...
+func (i *Intercepted) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	var tx Tx
+	err := i.invoke(ctx, OpBegin, "", nil, func(ctx context.Context) error {
+		var err error
+		tx, err = i.db.BeginTx(ctx, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedTx{tx: tx, parent: i, name: QueryName(ctx)}, nil
+}
+
+// WithTx ...
+func (i *Intercepted) WithTx(ctx context.Context, fn func(Tx) error) error {
+	return runTx(ctx, i, fn)
+}
+
+type interceptedTx struct {
+	tx     Tx
+	parent *Intercepted
+	name   string
+}
+
+// QueryContext ...
+func (t *interceptedTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	return interceptQuery(ctx, t.parent, t.tx, query, args)
+}
+
+// ExecuteContext ...
+func (t *interceptedTx) ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return interceptExecute(ctx, t.parent, t.tx, query, args)
+}
+
+// InsertContext ...
+func (t *interceptedTx) InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return interceptInsert(ctx, t.parent, t.tx, query, args)
+}
+
+// Commit ...
+func (t *interceptedTx) Commit() error {
+	ctx := WithQueryName(context.Background(), t.name)
+	return t.parent.invoke(ctx, OpCommit, "", nil, func(context.Context) error {
+		return t.tx.Commit()
+	})
+}
+
+// Rollback ...
+func (t *interceptedTx) Rollback() error {
+	ctx := WithQueryName(context.Background(), t.name)
+	return t.parent.invoke(ctx, OpRollback, "", nil, func(context.Context) error {
+		return t.tx.Rollback()
+	})
+}
+
+func interceptQuery(ctx context.Context, i *Intercepted, q Querier, query string, args []interface{}) (*Rows, error) {
+	var rows *Rows
+	err := i.invoke(ctx, OpQuery, query, args, func(ctx context.Context) error {
+		var err error
+		rows, err = q.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func interceptExecute(ctx context.Context, i *Intercepted, q Querier, query string, args []interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := i.invoke(ctx, OpExecute, query, args, func(ctx context.Context) error {
+		var err error
+		result, err = q.ExecuteContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func interceptInsert(ctx context.Context, i *Intercepted, q Querier, query string, args []interface{}) (int64, error) {
+	var id int64
+	err := i.invoke(ctx, OpInsert, query, args, func(ctx context.Context) error {
+		var err error
+		id, err = q.InsertContext(ctx, query, args...)
+		return err
+	})
+	return id, err
+}
+
+var _ Database = (*Intercepted)(nil)
+var _ Tx = (*interceptedTx)(nil)
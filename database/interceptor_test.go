@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inconshreveable/log15"
+)
+
+// recorder returns an interceptor which appends what it sees to calls.
+func recorder(label string, calls *[]string) Interceptor {
+	return func(ctx context.Context, call *Call, next Invoker) error {
+		*calls = append(*calls, label+" "+call.Name+" "+call.Op)
+		err := next(ctx)
+		result := "ok"
+		if err != nil {
+			result = "failed"
+		}
+		*calls = append(*calls, label+" "+result)
+		return err
+	}
+}
+
+func TestInterceptorChain(t *testing.T) {
+	errFail := errors.New("fail")
+	tests := []struct {
+		name    string
+		run     func(ctx context.Context, db Database) error
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "query",
+			run: func(ctx context.Context, db Database) error {
+				rows, err := db.QueryContext(ctx, `SELECT body FROM notes`)
+				if err == nil {
+					rows.Close()
+				}
+				return err
+			},
+			want: []string{"outer test query", "inner test query", "inner ok", "outer ok"},
+		},
+		{
+			name: "failed execute",
+			run: func(ctx context.Context, db Database) error {
+				_, err := db.ExecuteContext(ctx, `INSERT INTO missing(body) VALUES (?)`, "hello")
+				return err
+			},
+			want:    []string{"outer test execute", "inner test execute", "inner failed", "outer failed"},
+			wantErr: true,
+		},
+		{
+			name: "transaction",
+			run: func(ctx context.Context, db Database) error {
+				return db.WithTx(ctx, func(tx Tx) error {
+					_, err := tx.InsertContext(ctx, `INSERT INTO notes(body) VALUES (?)`, "hello")
+					return err
+				})
+			},
+			want: []string{
+				"outer test begin", "inner test begin", "inner ok", "outer ok",
+				"outer test insert", "inner test insert", "inner ok", "outer ok",
+				"outer test commit", "inner test commit", "inner ok", "outer ok",
+			},
+		},
+		{
+			name: "rolled back transaction",
+			run: func(ctx context.Context, db Database) error {
+				return db.WithTx(ctx, func(tx Tx) error { return errFail })
+			},
+			want: []string{
+				"outer test begin", "inner test begin", "inner ok", "outer ok",
+				"outer test rollback", "inner test rollback", "inner ok", "outer ok",
+			},
+			wantErr: true,
+		},
+		{
+			name: "ping",
+			run:  func(ctx context.Context, db Database) error { return db.PingContext(ctx) },
+			want: []string{"outer test ping", "inner test ping", "inner ok", "outer ok"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			db := Intercept(newTestSQLite(t), recorder("outer", &calls), recorder("inner", &calls))
+			err := tt.run(WithQueryName(context.Background(), "test"), db)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("calls =\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		want      bool
+	}{
+		{"slow", 0, true},
+		{"fast", time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []*log15.Record
+			logger := log15.New()
+			logger.SetHandler(log15.FuncHandler(func(r *log15.Record) error {
+				records = append(records, r)
+				return nil
+			}))
+			db := Intercept(newTestSQLite(t), SlowQueryLog(tt.threshold, logger))
+			rows, err := db.QueryContext(WithQueryName(context.Background(), "notes.find_all"), "SELECT body\n\t\tFROM notes")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+			if logged := len(records) == 1; logged != tt.want {
+				t.Fatalf("logged %d records, want logged %v", len(records), tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			// The record is name, op, elapsed, query and err.
+			ctx := records[0].Ctx
+			if ctx[1] != "notes.find_all" || ctx[3] != OpQuery || ctx[7] != "SELECT body FROM notes" {
+				t.Errorf("logged %v, want the name, the op and the compacted query", ctx)
+			}
+		})
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for _, d := range []time.Duration{
+		time.Millisecond, // on the bound of the first bucket
+		1500 * time.Microsecond,
+		time.Second,
+		time.Minute, // past the last bucket
+	} {
+		h.Observe(d, nil)
+	}
+	h.Observe(3*time.Millisecond, errors.New("fail"))
+	want := `{"count":5,"errors":1,"sum_seconds":61.005500,"buckets":{` +
+		`"0.001":1,"0.002":2,"0.005":3,"0.01":3,"0.025":3,"0.05":3,"0.1":3,"0.25":3,"0.5":3,"1":4,"2.5":4,"5":4,"+Inf":5}}`
+	if got := h.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) SetError(err error)                         { s.err = err }
+func (s *testSpan) End()                                       { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestTrace(t *testing.T) {
+	tracer := &testTracer{}
+	db := Intercept(newTestSQLite(t), Trace(tracer))
+	ctx := WithQueryName(context.Background(), "notes.save")
+	db.ExecuteContext(ctx, `INSERT INTO notes(body) VALUES (?)`, "hello")
+	db.ExecuteContext(ctx, `INSERT INTO missing(body) VALUES (?)`, "hello")
+	if len(tracer.spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(tracer.spans))
+	}
+	for i, span := range tracer.spans {
+		if span.name != "db.notes.save" || span.attrs["db.operation"] != OpExecute || !span.ended {
+			t.Errorf("span %d = %+v", i, span)
+		}
+		if (span.err != nil) != (i == 1) {
+			t.Errorf("span %d error = %v", i, span.err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inconshreveable/log15"
+)
+
+// maxLoggedQuery is the maximum length of the SQL text written to the logs.
+const maxLoggedQuery = 200
+
+// SlowQueryLog logs the operations which take longer than threshold.
+func SlowQueryLog(threshold time.Duration, logger log15.Logger) Interceptor {
+	return func(ctx context.Context, call *Call, next Invoker) error {
+		start := time.Now()
+		err := next(ctx)
+		if elapsed := time.Since(start); elapsed >= threshold {
+			logger.Warn("Slow query", "name", call.Name, "op", call.Op, "elapsed", elapsed, "query", compactQuery(call.Query), "err", err)
+		}
+		return err
+	}
+}
+
+var (
+	// latencies is published as "database_latency" in expvar.
+	latencies   = expvar.NewMap("database_latency")
+	latenciesMu sync.Mutex
+)
+
+// Metrics records the latency of the operations in a histogram per query name.
+func Metrics() Interceptor {
+	return func(ctx context.Context, call *Call, next Invoker) error {
+		start := time.Now()
+		err := next(ctx)
+		histogram(call.Name+"."+call.Op).Observe(time.Since(start), err)
+		return err
+	}
+}
+
+func histogram(key string) *Histogram {
+	if h, ok := latencies.Get(key).(*Histogram); ok {
+		return h
+	}
+	latenciesMu.Lock()
+	defer latenciesMu.Unlock()
+	// Checked again as another goroutine may have created it meanwhile.
+	if h, ok := latencies.Get(key).(*Histogram); ok {
+		return h
+	}
+	h := NewHistogram()
+	latencies.Set(key, h)
+	return h
+}
+
+// Trace creates a span for every operation with tracer.
+func Trace(tracer Tracer) Interceptor {
+	return func(ctx context.Context, call *Call, next Invoker) error {
+		ctx, span := tracer.StartSpan(ctx, "db."+call.Name)
+		span.SetAttribute("db.operation", call.Op)
+		if call.Query != "" {
+			span.SetAttribute("db.statement", compactQuery(call.Query))
+		}
+		err := next(ctx)
+		if err != nil {
+			span.SetError(err)
+		}
+		span.End()
+		return err
+	}
+}
+
+// compactQuery collapses the white space of query for logging.
+func compactQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedQuery {
+		query = query[:maxLoggedQuery] + "..."
+	}
+	return query
+}
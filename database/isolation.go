@@ -0,0 +1,36 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// IsolationLevel ...
+type IsolationLevel sql.IsolationLevel
+
+// String ...
+func (l IsolationLevel) String() string {
+	if sql.IsolationLevel(l) == sql.LevelDefault {
+		return "default"
+	}
+	return strings.Replace(strings.ToLower(sql.IsolationLevel(l).String()), " ", "-", -1)
+}
+
+// Set parses names such as "read-committed" or "Read Committed".
+func (l *IsolationLevel) Set(s string) error {
+	name := strings.Replace(strings.ToLower(strings.TrimSpace(s)), "_", "-", -1)
+	name = strings.Replace(name, " ", "-", -1)
+	for lvl := sql.LevelDefault; lvl <= sql.LevelLinearizable; lvl++ {
+		if IsolationLevel(lvl).String() == name {
+			*l = IsolationLevel(lvl)
+			return nil
+		}
+	}
+	return fmt.Errorf("database: unknown isolation level %q", s)
+}
+
+// TxOptions ...
+func (l IsolationLevel) TxOptions() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: sql.IsolationLevel(l)}
+}
@@ -0,0 +1,8 @@
+package database
+
+import (
+	"expvar"
+)
+
+// metrics is published as "database" in expvar.
+var metrics = expvar.NewMap("database")
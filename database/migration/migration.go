@@ -0,0 +1,107 @@
+package migration
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed sql
+var files embed.FS
+
+// Migration ...
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the migrations of driver in ascending order of version.
+func Load(driver string) ([]*Migration, error) {
+	dir := path.Join("sql", driver)
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("migration: no migrations for driver %q", driver)
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		version, name, direction, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration: version %d has two names %q and %q", version, m.Name, name)
+		}
+		switch direction {
+		case "up":
+			m.Up = string(body)
+		case "down":
+			m.Down = string(body)
+		}
+	}
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration: version %d needs both up and down files", m.Version)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parseFileName splits names like "0001_create_users.up.sql".
+func parseFileName(name string) (int64, string, string, error) {
+	parts := strings.Split(name, ".")
+	if len(parts) != 3 || parts[2] != "sql" || (parts[1] != "up" && parts[1] != "down") {
+		return 0, "", "", fmt.Errorf("migration: invalid file name %q", name)
+	}
+	i := strings.Index(parts[0], "_")
+	if i < 0 {
+		return 0, "", "", fmt.Errorf("migration: invalid file name %q", name)
+	}
+	version, err := strconv.ParseInt(parts[0][:i], 10, 64)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("migration: invalid version in %q", name)
+	}
+	return version, parts[0][i+1:], parts[1], nil
+}
+
+// statements splits a migration into the statements which are terminated
+// by a semicolon at the end of a line.
+func statements(script string) []string {
+	stmts := []string{}
+	var buf strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if buf.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(buf.String()))
+			buf.Reset()
+		}
+	}
+	if s := strings.TrimSpace(buf.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
@@ -0,0 +1,263 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+var (
+	// ErrNoMigration ...
+	ErrNoMigration = errors.New("migration: no migration has been applied")
+)
+
+const createTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT NOT NULL PRIMARY KEY,
+  applied_at TIMESTAMP NOT NULL
+)`
+
+// createProgressTable keeps the number of statements of a migration which
+// were run, on databases which commit DDL statements implicitly.
+const createProgressTable = `
+CREATE TABLE IF NOT EXISTS schema_migration_progress (
+  version BIGINT NOT NULL,
+  direction VARCHAR(4) NOT NULL,
+  statements INT NOT NULL,
+  PRIMARY KEY (version, direction)
+)`
+
+// Status ...
+type Status struct {
+	Migration *Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// Migrator ...
+type Migrator struct {
+	db         database.Database
+	migrations []*Migration
+	// transactionalDDL is false for MySQL, which commits every DDL statement
+	// implicitly, so that a migration failing half way cannot be rolled back.
+	transactionalDDL bool
+	logger           log15.Logger
+}
+
+// New ...
+func New(db database.Database, driver string) (*Migrator, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:               db,
+		migrations:       migrations,
+		transactionalDDL: driver != database.DriverMySQL,
+		logger:           log15.New("module", "migration"),
+	}, nil
+}
+
+// Latest returns the version of the newest known migration.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the newest applied version, or 0 when nothing is applied.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]*Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := []*Migration{}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Status ...
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, &Status{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, migration := range pending {
+		if err := m.apply(ctx, migration); err != nil {
+			return pending[:i], err
+		}
+	}
+	return pending, nil
+}
+
+// Down reverts the newest applied migration.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, ErrNoMigration
+	}
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, m.revert(ctx, migration)
+		}
+	}
+	return nil, errors.New("migration: applied version is unknown to this binary")
+}
+
+// Redo reverts and re-applies the newest applied migration.
+func (m *Migrator) Redo(ctx context.Context) (*Migration, error) {
+	migration, err := m.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return migration, m.apply(ctx, migration)
+}
+
+func (m *Migrator) apply(ctx context.Context, migration *Migration) error {
+	m.logger.Info("Apply migration", "version", migration.Version, "name", migration.Name)
+	query := `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`
+	return m.run(ctx, migration.Version, "up", migration.Up, query, migration.Version, time.Now().UTC())
+}
+
+func (m *Migrator) revert(ctx context.Context, migration *Migration) error {
+	m.logger.Info("Revert migration", "version", migration.Version, "name", migration.Name)
+	query := `DELETE FROM schema_migrations WHERE version=?`
+	return m.run(ctx, migration.Version, "down", migration.Down, query, migration.Version)
+}
+
+// run runs the statements of script and then record, which marks the
+// migration applied or reverted, without the default query timeout: they
+// may rewrite whole tables. Without transactional DDL the statements which
+// succeeded are counted in schema_migration_progress, and skipped when the
+// script is run again after a failure.
+func (m *Migrator) run(ctx context.Context, version int64, direction, script, record string, args ...interface{}) error {
+	ctx = database.WithoutTimeout(ctx)
+	stmts := statements(script)
+	if m.transactionalDDL {
+		return m.db.WithTx(ctx, func(tx database.Tx) error {
+			for _, stmt := range stmts {
+				if _, err := tx.ExecuteContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecuteContext(ctx, record, args...)
+			return err
+		})
+	}
+	done, err := m.progress(ctx, version, direction)
+	if err != nil {
+		return err
+	}
+	if done > 0 {
+		m.logger.Warn("Resume partially run migration", "version", version, "direction", direction, "skipped", done)
+	}
+	for i := done; i < len(stmts); i++ {
+		if _, err := m.db.ExecuteContext(ctx, stmts[i]); err != nil {
+			m.logger.Error("Migration statement error", "version", version, "direction", direction, "statement", i+1, "err", err)
+			return err
+		}
+		query := `REPLACE INTO schema_migration_progress(version, direction, statements) VALUES (?, ?, ?)`
+		if _, err := m.db.ExecuteContext(ctx, query, version, direction, i+1); err != nil {
+			return err
+		}
+	}
+	return m.db.WithTx(ctx, func(tx database.Tx) error {
+		if _, err := tx.ExecuteContext(ctx, record, args...); err != nil {
+			return err
+		}
+		query := `DELETE FROM schema_migration_progress WHERE version=? AND direction=?`
+		_, err := tx.ExecuteContext(ctx, query, version, direction)
+		return err
+	})
+}
+
+// progress returns the number of statements of the migration version in
+// direction which were run before it failed.
+func (m *Migrator) progress(ctx context.Context, version int64, direction string) (int, error) {
+	if _, err := m.db.ExecuteContext(ctx, createProgressTable); err != nil {
+		return 0, err
+	}
+	query := `SELECT statements FROM schema_migration_progress WHERE version=? AND direction=?`
+	rows, err := m.db.QueryContext(ctx, query, version, direction)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var done int
+	if rows.Next() {
+		if err := rows.Scan(&done); err != nil {
+			return 0, err
+		}
+	}
+	return done, rows.Err()
+}
+
+// applied returns the applied versions with the time they were applied.
+func (m *Migrator) applied(ctx context.Context) (map[int64]string, error) {
+	if _, err := m.db.ExecuteContext(ctx, createTable); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int64]string{}
+	for rows.Next() {
+		var version int64
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
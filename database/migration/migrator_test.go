@@ -0,0 +1,56 @@
+package migration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+func TestMigratorSQLite(t *testing.T) {
+	db := database.NewSQLite(database.Options{Path: filepath.Join(t.TempDir(), "bbs.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Disconnect()
+	m, err := New(db, database.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	steps := []struct {
+		name string
+		run  func() error
+		want int64
+	}{
+		{"up", func() error { _, err := m.Up(ctx); return err }, m.Latest()},
+		{"up again", func() error { _, err := m.Up(ctx); return err }, m.Latest()},
+		{"redo", func() error { _, err := m.Redo(ctx); return err }, m.Latest()},
+		{"down", func() error { _, err := m.Down(ctx); return err }, m.Latest() - 1},
+		{"down to nothing", func() error {
+			for {
+				if _, err := m.Down(ctx); err != nil {
+					if err == ErrNoMigration {
+						return nil
+					}
+					return err
+				}
+			}
+		}, 0},
+		{"up from nothing", func() error { _, err := m.Up(ctx); return err }, m.Latest()},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		version, err := m.Version(ctx)
+		if err != nil {
+			t.Fatalf("%s: Version() error = %v", step.name, err)
+		}
+		if version != step.want {
+			t.Errorf("%s: Version() = %d, want %d", step.name, version, step.want)
+		}
+	}
+}
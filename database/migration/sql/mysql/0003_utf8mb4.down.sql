@@ -0,0 +1,2 @@
+ALTER TABLE `messages` CONVERT TO CHARACTER SET utf8 COLLATE utf8_general_ci;
+ALTER TABLE `users` CONVERT TO CHARACTER SET utf8 COLLATE utf8_general_ci;
@@ -0,0 +1,4 @@
+ALTER TABLE `users` DROP COLUMN `role`;
+ALTER TABLE `messages` DROP FOREIGN KEY `messages_board_fk`;
+ALTER TABLE `messages` DROP COLUMN `board_id`;
+DROP TABLE IF EXISTS `boards`;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `boards` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `slug` varchar(64) NOT NULL,
+  `title` varchar(128) NOT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `sort_order` int(11) NOT NULL DEFAULT 0,
+  `post_policy` varchar(16) NOT NULL DEFAULT 'members',
+  `max_message_length` int(11) NOT NULL DEFAULT 1000,
+  `read_only` tinyint(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `slug` (`slug`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- The default board gets id 1, which existing messages are moved to.
+INSERT IGNORE INTO `boards` (`slug`, `title`, `description`) VALUES ('general', 'General', 'Anything goes.');
+ALTER TABLE `messages` ADD COLUMN `board_id` bigint(20) NOT NULL DEFAULT 1, ADD KEY `board_id` (`board_id`), ADD CONSTRAINT `messages_board_fk` FOREIGN KEY (`board_id`) REFERENCES `boards` (`id`);
+ALTER TABLE `users` ADD COLUMN `role` varchar(16) NOT NULL DEFAULT 'member';
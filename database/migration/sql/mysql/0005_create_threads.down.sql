@@ -0,0 +1,3 @@
+ALTER TABLE `messages` DROP FOREIGN KEY `messages_thread_fk`;
+ALTER TABLE `messages` DROP COLUMN `thread_id`;
+DROP TABLE IF EXISTS `threads`;
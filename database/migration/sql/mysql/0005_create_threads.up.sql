@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `threads` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `board_id` bigint(20) NOT NULL,
+  `subject` varchar(128) NOT NULL,
+  `created_at` datetime NOT NULL,
+  `bumped_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `board_bumped_at` (`board_id`, `bumped_at`),
+  CONSTRAINT `threads_board_fk` FOREIGN KEY (`board_id`) REFERENCES `boards` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- The messages posted before threads existed go to one thread per board.
+INSERT INTO `threads` (`board_id`, `subject`, `created_at`, `bumped_at`)
+SELECT `board_id`, 'Earlier messages', MIN(`created_at`), MAX(`created_at`) FROM `messages` GROUP BY `board_id`;
+ALTER TABLE `messages` ADD COLUMN `thread_id` bigint(20) NULL, ADD KEY `thread_id` (`thread_id`), ADD CONSTRAINT `messages_thread_fk` FOREIGN KEY (`thread_id`) REFERENCES `threads` (`id`);
+UPDATE `messages` SET `thread_id` = (SELECT `id` FROM `threads` WHERE `threads`.`board_id` = `messages`.`board_id`);
@@ -0,0 +1,3 @@
+ALTER TABLE `messages` DROP INDEX `thread_post_no`;
+ALTER TABLE `messages` DROP COLUMN `post_no`;
+ALTER TABLE `threads` DROP COLUMN `last_post_no`;
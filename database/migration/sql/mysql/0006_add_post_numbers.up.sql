@@ -0,0 +1,11 @@
+ALTER TABLE `threads` ADD COLUMN `last_post_no` int(11) NOT NULL DEFAULT 0;
+ALTER TABLE `messages` ADD COLUMN `post_no` int(11) NULL;
+-- Number the messages of every thread in the order they were posted.
+UPDATE `messages` m INNER JOIN (
+  SELECT a.`id`, COUNT(*) AS `post_no` FROM `messages` a
+  INNER JOIN `messages` b ON b.`thread_id` = a.`thread_id` AND b.`id` <= a.`id`
+  GROUP BY a.`id`
+) p ON p.`id` = m.`id`
+SET m.`post_no` = p.`post_no`;
+UPDATE `threads` SET `last_post_no` = (SELECT COUNT(*) FROM `messages` WHERE `messages`.`thread_id` = `threads`.`id`);
+ALTER TABLE `messages` ADD UNIQUE KEY `thread_post_no` (`thread_id`, `post_no`);
@@ -0,0 +1,4 @@
+DELETE FROM `messages` WHERE `user_id` IS NULL;
+ALTER TABLE `messages` MODIFY `user_id` bigint(20) NOT NULL;
+ALTER TABLE `threads` DROP COLUMN `archived_at`, DROP COLUMN `closed_at`;
+ALTER TABLE `boards` DROP COLUMN `max_posts_per_thread`;
@@ -0,0 +1,4 @@
+ALTER TABLE `boards` ADD COLUMN `max_posts_per_thread` int(11) NOT NULL DEFAULT 1000;
+ALTER TABLE `threads` ADD COLUMN `closed_at` datetime NULL, ADD COLUMN `archived_at` datetime NULL;
+-- System notices, such as the one closing a full thread, have no user.
+ALTER TABLE `messages` MODIFY `user_id` bigint(20) NULL;
@@ -0,0 +1,3 @@
+DELETE FROM `messages` WHERE `user_id` IS NULL AND `ip_hash` <> '';
+ALTER TABLE `messages` DROP KEY `ip_hash`, DROP COLUMN `ip_hash`, DROP COLUMN `poster_id`, DROP COLUMN `tripcode`, DROP COLUMN `name`;
+ALTER TABLE `boards` DROP COLUMN `default_name`, DROP COLUMN `allow_guests`, DROP COLUMN `anonymous`;
@@ -0,0 +1,4 @@
+ALTER TABLE `boards` ADD COLUMN `anonymous` tinyint(1) NOT NULL DEFAULT 0, ADD COLUMN `allow_guests` tinyint(1) NOT NULL DEFAULT 0, ADD COLUMN `default_name` varchar(64) NOT NULL DEFAULT 'Anonymous';
+-- name is empty unless the message was posted on an anonymous board, where
+-- the account name is not shown. ip_hash lets moderators link posts.
+ALTER TABLE `messages` ADD COLUMN `name` varchar(64) NOT NULL DEFAULT '', ADD COLUMN `tripcode` varchar(16) NOT NULL DEFAULT '', ADD COLUMN `poster_id` varchar(16) NOT NULL DEFAULT '', ADD COLUMN `ip_hash` varchar(64) NOT NULL DEFAULT '', ADD KEY `ip_hash` (`ip_hash`);
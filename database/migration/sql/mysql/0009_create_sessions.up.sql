@@ -0,0 +1,16 @@
+-- id is the SHA-256 of the token in the session cookie, so the table does not
+-- hold usable tokens.
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `id` char(64) NOT NULL,
+  `user_id` bigint(20) NOT NULL,
+  `data` text NOT NULL,
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `ip` varchar(64) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL,
+  `last_seen_at` datetime NOT NULL,
+  `expires_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `user_id` (`user_id`),
+  KEY `expires_at` (`expires_at`),
+  CONSTRAINT `sessions_user_fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
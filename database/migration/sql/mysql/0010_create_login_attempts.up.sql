@@ -0,0 +1,10 @@
+-- id is "email:" and the SHA-256 of the email or "ip:" and the address, so
+-- failures are counted for unknown emails too.
+CREATE TABLE IF NOT EXISTS `login_attempts` (
+  `id` varchar(80) NOT NULL,
+  `failures` int NOT NULL DEFAULT 0,
+  `last_failed_at` datetime NOT NULL,
+  `locked_until` datetime NULL,
+  PRIMARY KEY (`id`),
+  KEY `last_failed_at` (`last_failed_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
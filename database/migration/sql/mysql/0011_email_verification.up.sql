@@ -0,0 +1,3 @@
+-- The accounts made before verification existed are taken as verified.
+ALTER TABLE `users` ADD COLUMN `email_verified_at` datetime NULL;
+UPDATE `users` SET `email_verified_at` = CURRENT_TIMESTAMP;
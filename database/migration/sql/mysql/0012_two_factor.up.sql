@@ -0,0 +1,14 @@
+-- totp_secret is empty unless the user enrolled in two-factor sign in.
+-- totp_last_step is the time step of the last code used, so that a code works
+-- once.
+ALTER TABLE `users` ADD COLUMN `totp_secret` varchar(64) NOT NULL DEFAULT '';
+ALTER TABLE `users` ADD COLUMN `totp_last_step` bigint(20) NOT NULL DEFAULT 0;
+-- code_hash is the SHA-256 of a single-use recovery code.
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `user_id` bigint(20) NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `recovery_codes_user_fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
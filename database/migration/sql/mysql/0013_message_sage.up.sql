@@ -0,0 +1,3 @@
+-- sage is set on replies which did not bump their thread, which dat files
+-- show in the mail field.
+ALTER TABLE `messages` ADD COLUMN `sage` tinyint(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS users (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR(20) NOT NULL,
+  email VARCHAR(128) NOT NULL UNIQUE,
+  password_hash VARCHAR(128) NOT NULL
+);
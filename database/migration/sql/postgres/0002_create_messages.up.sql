@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS messages (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users (id),
+  message TEXT,
+  created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS messages_user_id ON messages (user_id);
+CREATE INDEX IF NOT EXISTS idx_posted_time ON messages (created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN role;
+ALTER TABLE messages DROP COLUMN board_id;
+DROP TABLE IF EXISTS boards;
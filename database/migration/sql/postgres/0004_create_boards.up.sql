@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS boards (
+  id BIGSERIAL PRIMARY KEY,
+  slug VARCHAR(64) NOT NULL UNIQUE,
+  title VARCHAR(128) NOT NULL,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  sort_order INTEGER NOT NULL DEFAULT 0,
+  post_policy VARCHAR(16) NOT NULL DEFAULT 'members',
+  max_message_length INTEGER NOT NULL DEFAULT 1000,
+  read_only BOOLEAN NOT NULL DEFAULT FALSE
+);
+-- The default board gets id 1, which existing messages are moved to.
+INSERT INTO boards (slug, title, description) VALUES ('general', 'General', 'Anything goes.');
+ALTER TABLE messages ADD COLUMN board_id BIGINT NOT NULL DEFAULT 1 REFERENCES boards (id);
+CREATE INDEX IF NOT EXISTS messages_board_id ON messages (board_id);
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'member';
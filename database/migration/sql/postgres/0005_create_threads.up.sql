@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS threads (
+  id BIGSERIAL PRIMARY KEY,
+  board_id BIGINT NOT NULL REFERENCES boards (id),
+  subject VARCHAR(128) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  bumped_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS threads_board_bumped_at ON threads (board_id, bumped_at);
+-- The messages posted before threads existed go to one thread per board.
+INSERT INTO threads (board_id, subject, created_at, bumped_at)
+SELECT board_id, 'Earlier messages', MIN(created_at), MAX(created_at) FROM messages GROUP BY board_id;
+ALTER TABLE messages ADD COLUMN thread_id BIGINT REFERENCES threads (id);
+CREATE INDEX IF NOT EXISTS messages_thread_id ON messages (thread_id);
+UPDATE messages SET thread_id = (SELECT id FROM threads WHERE threads.board_id = messages.board_id);
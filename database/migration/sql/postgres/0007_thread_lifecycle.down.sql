@@ -0,0 +1,5 @@
+DELETE FROM messages WHERE user_id IS NULL;
+ALTER TABLE messages ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE threads DROP COLUMN archived_at;
+ALTER TABLE threads DROP COLUMN closed_at;
+ALTER TABLE boards DROP COLUMN max_posts_per_thread;
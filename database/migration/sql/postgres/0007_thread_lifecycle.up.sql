@@ -0,0 +1,5 @@
+ALTER TABLE boards ADD COLUMN max_posts_per_thread INTEGER NOT NULL DEFAULT 1000;
+ALTER TABLE threads ADD COLUMN closed_at TIMESTAMP;
+ALTER TABLE threads ADD COLUMN archived_at TIMESTAMP;
+-- System notices, such as the one closing a full thread, have no user.
+ALTER TABLE messages ALTER COLUMN user_id DROP NOT NULL;
@@ -0,0 +1,9 @@
+DELETE FROM messages WHERE user_id IS NULL AND ip_hash <> '';
+DROP INDEX IF EXISTS messages_ip_hash;
+ALTER TABLE messages DROP COLUMN ip_hash;
+ALTER TABLE messages DROP COLUMN poster_id;
+ALTER TABLE messages DROP COLUMN tripcode;
+ALTER TABLE messages DROP COLUMN name;
+ALTER TABLE boards DROP COLUMN default_name;
+ALTER TABLE boards DROP COLUMN allow_guests;
+ALTER TABLE boards DROP COLUMN anonymous;
@@ -0,0 +1,12 @@
+-- totp_secret is empty unless the user enrolled in two-factor sign in.
+-- totp_last_step is the time step of the last code used, so that a code works
+-- once.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+-- code_hash is the SHA-256 of a single-use recovery code.
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users (id),
+  code_hash CHAR(64) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id ON recovery_codes (user_id);
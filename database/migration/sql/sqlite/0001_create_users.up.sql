@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name VARCHAR(20) NOT NULL,
+  email VARCHAR(128) NOT NULL,
+  password_hash VARCHAR(128) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users (email);
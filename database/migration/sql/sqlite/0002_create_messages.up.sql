@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS messages (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id),
+  message TEXT,
+  created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS messages_user_id ON messages (user_id);
+CREATE INDEX IF NOT EXISTS idx_posted_time ON messages (created_at);
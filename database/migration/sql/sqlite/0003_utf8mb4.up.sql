@@ -0,0 +1 @@
+-- Text is always stored as UTF-8; nothing to do.
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN role;
+DROP INDEX IF EXISTS messages_board_id;
+ALTER TABLE messages DROP COLUMN board_id;
+DROP TABLE IF EXISTS boards;
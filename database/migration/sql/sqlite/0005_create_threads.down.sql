@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS messages_thread_id;
+ALTER TABLE messages DROP COLUMN thread_id;
+DROP TABLE IF EXISTS threads;
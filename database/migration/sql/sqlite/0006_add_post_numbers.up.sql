@@ -0,0 +1,8 @@
+ALTER TABLE threads ADD COLUMN last_post_no INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN post_no INTEGER;
+-- Number the messages of every thread in the order they were posted.
+UPDATE messages SET post_no = (
+  SELECT COUNT(*) FROM messages b WHERE b.thread_id = messages.thread_id AND b.id <= messages.id
+) WHERE thread_id IS NOT NULL;
+UPDATE threads SET last_post_no = (SELECT COUNT(*) FROM messages WHERE messages.thread_id = threads.id);
+CREATE UNIQUE INDEX IF NOT EXISTS messages_thread_post_no ON messages (thread_id, post_no);
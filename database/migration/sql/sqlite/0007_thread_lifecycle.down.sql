@@ -0,0 +1,21 @@
+CREATE TABLE messages_old (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id),
+  message TEXT,
+  created_at DATETIME NOT NULL,
+  board_id INTEGER NOT NULL DEFAULT 1,
+  thread_id INTEGER REFERENCES threads (id),
+  post_no INTEGER
+);
+INSERT INTO messages_old (id, user_id, message, created_at, board_id, thread_id, post_no)
+SELECT id, user_id, message, created_at, board_id, thread_id, post_no FROM messages WHERE user_id IS NOT NULL;
+DROP TABLE messages;
+ALTER TABLE messages_old RENAME TO messages;
+CREATE INDEX IF NOT EXISTS messages_user_id ON messages (user_id);
+CREATE INDEX IF NOT EXISTS idx_posted_time ON messages (created_at);
+CREATE INDEX IF NOT EXISTS messages_board_id ON messages (board_id);
+CREATE INDEX IF NOT EXISTS messages_thread_id ON messages (thread_id);
+CREATE UNIQUE INDEX IF NOT EXISTS messages_thread_post_no ON messages (thread_id, post_no);
+ALTER TABLE threads DROP COLUMN archived_at;
+ALTER TABLE threads DROP COLUMN closed_at;
+ALTER TABLE boards DROP COLUMN max_posts_per_thread;
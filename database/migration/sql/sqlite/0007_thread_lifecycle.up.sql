@@ -0,0 +1,23 @@
+ALTER TABLE boards ADD COLUMN max_posts_per_thread INTEGER NOT NULL DEFAULT 1000;
+ALTER TABLE threads ADD COLUMN closed_at DATETIME;
+ALTER TABLE threads ADD COLUMN archived_at DATETIME;
+-- System notices, such as the one closing a full thread, have no user.
+-- SQLite cannot drop NOT NULL from a column, so the table is rebuilt.
+CREATE TABLE messages_new (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER REFERENCES users (id),
+  message TEXT,
+  created_at DATETIME NOT NULL,
+  board_id INTEGER NOT NULL DEFAULT 1,
+  thread_id INTEGER REFERENCES threads (id),
+  post_no INTEGER
+);
+INSERT INTO messages_new (id, user_id, message, created_at, board_id, thread_id, post_no)
+SELECT id, user_id, message, created_at, board_id, thread_id, post_no FROM messages;
+DROP TABLE messages;
+ALTER TABLE messages_new RENAME TO messages;
+CREATE INDEX IF NOT EXISTS messages_user_id ON messages (user_id);
+CREATE INDEX IF NOT EXISTS idx_posted_time ON messages (created_at);
+CREATE INDEX IF NOT EXISTS messages_board_id ON messages (board_id);
+CREATE INDEX IF NOT EXISTS messages_thread_id ON messages (thread_id);
+CREATE UNIQUE INDEX IF NOT EXISTS messages_thread_post_no ON messages (thread_id, post_no);
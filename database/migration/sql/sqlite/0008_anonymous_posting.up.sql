@@ -0,0 +1,10 @@
+ALTER TABLE boards ADD COLUMN anonymous BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE boards ADD COLUMN allow_guests BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE boards ADD COLUMN default_name VARCHAR(64) NOT NULL DEFAULT 'Anonymous';
+-- name is empty unless the message was posted on an anonymous board, where
+-- the account name is not shown. ip_hash lets moderators link posts.
+ALTER TABLE messages ADD COLUMN name VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN tripcode VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN poster_id VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN ip_hash VARCHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS messages_ip_hash ON messages (ip_hash);
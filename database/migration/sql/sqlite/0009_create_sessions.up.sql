@@ -0,0 +1,14 @@
+-- id is the SHA-256 of the token in the session cookie, so the table does not
+-- hold usable tokens.
+CREATE TABLE IF NOT EXISTS sessions (
+  id CHAR(64) PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users (id),
+  data TEXT NOT NULL,
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  last_seen_at DATETIME NOT NULL,
+  expires_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_expires_at ON sessions (expires_at);
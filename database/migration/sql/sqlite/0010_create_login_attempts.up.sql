@@ -0,0 +1,9 @@
+-- id is "email:" and the SHA-256 of the email or "ip:" and the address, so
+-- failures are counted for unknown emails too.
+CREATE TABLE IF NOT EXISTS login_attempts (
+  id VARCHAR(80) PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failed_at DATETIME NOT NULL,
+  locked_until DATETIME
+);
+CREATE INDEX IF NOT EXISTS login_attempts_last_failed_at ON login_attempts (last_failed_at);
@@ -1,88 +1,74 @@
 package database
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql" // for sql.Open
+	"github.com/go-sql-driver/mysql"
 )
 
-// Options ...
-type Options struct {
-	Addr     string
-	Name     string
-	User     string
-	Password string
-}
+// mysqlTLSConfigName is the name the TLS configuration is registered with
+// in the MySQL driver.
+const mysqlTLSConfigName = "bbs-sample"
 
 // MySQL ...
 type MySQL struct {
-	dsn  string
-	conn *sql.DB
+	*sqlDB
 }
 
 // NewMySQL ...
 func NewMySQL(opt Options) Database {
 	return &MySQL{
-		dsn: fmt.Sprint(opt.User, ":", opt.Password, "@tcp(", opt.Addr, ")/", opt.Name),
+		sqlDB: newSQLDB("mysql", func() (string, error) { return mysqlDSN(opt) }, mysqlDialect, opt),
 	}
 }
 
-// Connect ...
-func (m *MySQL) Connect() error {
-	conn, err := sql.Open("mysql", m.dsn)
-	if err != nil {
-		return err
+// mysqlDSN builds the DSN with mysql.Config so that the values are escaped by the driver.
+func mysqlDSN(opt Options) (string, error) {
+	loc := time.UTC
+	if opt.Location != "" {
+		l, err := time.LoadLocation(opt.Location)
+		if err != nil {
+			return "", err
+		}
+		loc = l
 	}
-	m.conn = conn
-	return m.Ping()
-}
-
-// Query ...
-func (m *MySQL) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	stmt, err := m.conn.Prepare(query)
-	if err != nil {
-		return nil, err
-	}
-	rows, err := stmt.Query(args...)
-	if err != nil {
-		return nil, err
+	cfg := &mysql.Config{
+		User:                 opt.User,
+		Passwd:               opt.Password,
+		Net:                  "tcp",
+		Addr:                 opt.Addr,
+		DBName:               opt.Name,
+		Params:               map[string]string{},
+		Collation:            opt.Collation,
+		Loc:                  loc,
+		Timeout:              opt.DialTimeout,
+		ReadTimeout:          opt.ReadTimeout,
+		WriteTimeout:         opt.WriteTimeout,
+		AllowNativePasswords: true,
+		ParseTime:            true,
 	}
-	return rows, nil
-}
-
-// Execute ...
-func (m *MySQL) Execute(query string, args ...interface{}) (sql.Result, error) {
-	stmt, err := m.conn.Prepare(query)
-	if err != nil {
-		return nil, err
+	if opt.Charset != "" {
+		cfg.Params["charset"] = opt.Charset
 	}
-	defer stmt.Close()
-	result, err := stmt.Exec(args...)
+	tlsCfg, err := tlsConfig(opt.TLSMode, opt.TLSCAFile, opt.Addr)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return result, nil
-}
-
-// Disconnect ...
-func (m *MySQL) Disconnect() error {
-	if m.conn == nil {
-		return ErrConnNotExist
-	}
-	if err := m.conn.Close(); err != nil {
-		return err
+	if tlsCfg != nil {
+		if err := mysql.RegisterTLSConfig(mysqlTLSConfigName, tlsCfg); err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = mysqlTLSConfigName
 	}
-	m.conn = nil
-	return nil
+	return cfg.FormatDSN(), nil
 }
 
-// Ping ...
-func (m *MySQL) Ping() error {
-	if m.conn == nil {
-		return ErrConnNotExist
-	}
-	return m.conn.Ping()
+var mysqlDialect = dialect{
+	isDuplicate: func(err error) bool {
+		var myErr *mysql.MySQLError
+		return errors.As(err, &myErr) && myErr.Number == 1062 // ER_DUP_ENTRY
+	},
 }
 
 var _ Database = (*MySQL)(nil)
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DriverMySQL ...
+	DriverMySQL = "mysql"
+
+	// DriverSQLite ...
+	DriverSQLite = "sqlite"
+
+	// DriverPostgres ...
+	DriverPostgres = "postgres"
+)
+
+const (
+	// TLSDisable ...
+	TLSDisable = "disable"
+
+	// TLSRequire encrypts the connection without verifying the server certificate.
+	TLSRequire = "require"
+
+	// TLSVerifyCA verifies that the server certificate is signed by a trusted CA.
+	TLSVerifyCA = "verify-ca"
+
+	// TLSVerifyFull also verifies that the certificate matches the server host name.
+	TLSVerifyFull = "verify-full"
+)
+
+// Options ...
+type Options struct {
+	Driver       string
+	Addr         string
+	Name         string
+	User         string
+	Password     string
+	Path         string
+	QueryTimeout time.Duration
+	Isolation    IsolationLevel
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	StmtCacheSize   int
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	TLSMode   string
+	TLSCAFile string
+
+	Charset   string
+	Collation string
+	Location  string
+
+	Replicas       AddrList
+	ReadYourWrites time.Duration
+}
+
+// AddrList is a comma separated list of addresses.
+type AddrList []string
+
+// String ...
+func (l *AddrList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set ...
+func (l *AddrList) Set(s string) error {
+	*l = nil
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			*l = append(*l, addr)
+		}
+	}
+	return nil
+}
+
+// RegisterFlags registers the command line flags for opt on fs.
+func (opt *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&opt.Driver, "database-driver", DriverMySQL, "specify the driver of a database (mysql, sqlite, postgres)")
+	fs.StringVar(&opt.Addr, "database-addr", "localhost:3306", "specify the address of a database")
+	fs.StringVar(&opt.Name, "database-name", "bbs-sample", "specify the name of a database")
+	fs.StringVar(&opt.User, "database-user", "bbs-sample-user", "specify the username to connect for database")
+	fs.StringVar(&opt.Password, "database-password", "bbs-sample-password", "specify the password to connect for database")
+	fs.StringVar(&opt.Path, "database-path", "bbs.db", "specify the file path of a database (sqlite only)")
+	fs.DurationVar(&opt.QueryTimeout, "database-query-timeout", 5*time.Second, "specify the default timeout of a database query")
+	fs.Var(&opt.Isolation, "database-isolation", "specify the default transaction isolation level (e.g. read-committed, repeatable-read)")
+	fs.IntVar(&opt.MaxOpenConns, "database-max-open-conns", 20, "specify the maximum number of open connections (0 is unlimited)")
+	fs.IntVar(&opt.MaxIdleConns, "database-max-idle-conns", 5, "specify the maximum number of idle connections")
+	fs.DurationVar(&opt.ConnMaxLifetime, "database-conn-max-lifetime", 30*time.Minute, "specify the maximum time a connection may be reused (0 is unlimited)")
+	fs.DurationVar(&opt.ConnMaxIdleTime, "database-conn-max-idle-time", 5*time.Minute, "specify the maximum time a connection may be idle (0 is unlimited)")
+	fs.IntVar(&opt.StmtCacheSize, "database-stmt-cache-size", 100, "specify the number of prepared statements to cache (0 disables the cache)")
+	fs.DurationVar(&opt.DialTimeout, "database-dial-timeout", 10*time.Second, "specify the timeout for establishing a connection")
+	fs.DurationVar(&opt.ReadTimeout, "database-read-timeout", 30*time.Second, "specify the I/O read timeout (mysql only)")
+	fs.DurationVar(&opt.WriteTimeout, "database-write-timeout", 30*time.Second, "specify the I/O write timeout (mysql only)")
+	fs.StringVar(&opt.TLSMode, "database-tls-mode", TLSDisable, "specify the TLS mode (disable, require, verify-ca, verify-full)")
+	fs.StringVar(&opt.TLSCAFile, "database-tls-ca-file", "", "specify the CA certificate file to verify the server with")
+	fs.StringVar(&opt.Charset, "database-charset", "utf8mb4", "specify the connection character set (mysql only)")
+	fs.StringVar(&opt.Collation, "database-collation", "utf8mb4_general_ci", "specify the connection collation (mysql only)")
+	fs.StringVar(&opt.Location, "database-location", "UTC", "specify the time zone of time values")
+	fs.Var(&opt.Replicas, "database-replicas", "specify the comma separated addresses of read replicas")
+	fs.DurationVar(&opt.ReadYourWrites, "database-read-your-writes", 5*time.Second, "specify how long a session reads from the primary after it wrote")
+}
+
+// New returns the Database implementation selected by opt.Driver. Queries
+// are routed to opt.Replicas when they are given.
+func New(opt Options) (Database, error) {
+	if len(opt.Replicas) > 0 {
+		if opt.Driver == DriverSQLite {
+			return nil, fmt.Errorf("database: driver %q does not support replicas", opt.Driver)
+		}
+		return NewReplicated(opt)
+	}
+	return newBackend(opt)
+}
+
+func newBackend(opt Options) (Database, error) {
+	switch opt.Driver {
+	case DriverMySQL, "":
+		return NewMySQL(opt), nil
+	case DriverSQLite:
+		return NewSQLite(opt), nil
+	case DriverPostgres:
+		return NewPostgres(opt), nil
+	default:
+		return nil, fmt.Errorf("database: unknown driver %q", opt.Driver)
+	}
+}
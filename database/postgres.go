@@ -0,0 +1,63 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// Postgres ...
+type Postgres struct {
+	*sqlDB
+}
+
+// NewPostgres ...
+func NewPostgres(opt Options) Database {
+	return &Postgres{
+		sqlDB: newSQLDB("postgres", func() (string, error) { return postgresDSN(opt) }, postgresDialect, opt),
+	}
+}
+
+func postgresDSN(opt Options) (string, error) {
+	params := url.Values{}
+	switch opt.TLSMode {
+	case TLSDisable, "":
+		params.Set("sslmode", "disable")
+	case TLSRequire, TLSVerifyCA, TLSVerifyFull:
+		params.Set("sslmode", opt.TLSMode)
+	default:
+		return "", errors.New("database: unknown TLS mode " + strconv.Quote(opt.TLSMode))
+	}
+	if opt.TLSCAFile != "" {
+		params.Set("sslrootcert", opt.TLSCAFile)
+	}
+	if opt.DialTimeout > 0 {
+		params.Set("connect_timeout", strconv.Itoa(int(math.Ceil(opt.DialTimeout.Seconds()))))
+	}
+	if opt.Location != "" {
+		params.Set("timezone", opt.Location)
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(opt.User, opt.Password),
+		Host:     opt.Addr,
+		Path:     "/" + opt.Name,
+		RawQuery: params.Encode(),
+	}
+	return dsn.String(), nil
+}
+
+var postgresDialect = dialect{
+	bindvar:    true,
+	returning:  true,
+	onConflict: true,
+	isDuplicate: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23505" // unique_violation
+	},
+}
+
+var _ Database = (*Postgres)(nil)
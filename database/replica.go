@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+
+	"github.com/inconshreveable/log15"
+)
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose queries are sent to the primary even
+// when replicas are configured, e.g. to read the caller's own writes.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+// Replicated sends writes and transactions to the primary and load-balances
+// queries across the healthy replicas.
+type Replicated struct {
+	primary  Database
+	replicas []*replica
+	next     uint32
+	logger   log15.Logger
+}
+
+type replica struct {
+	db      Database
+	addr    string
+	healthy int32
+
+	// mu guards connected and serializes connecting and disconnecting db,
+	// which the health checks and Disconnect do from different goroutines.
+	mu        sync.Mutex
+	connected bool
+}
+
+// NewReplicated connects to opt.Addr as the primary and to each of
+// opt.Replicas with otherwise the same options.
+func NewReplicated(opt Options) (*Replicated, error) {
+	primary, err := newBackend(opt)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replicated{
+		primary: primary,
+		logger:  log15.New("module", "database", "database", "replicated"),
+	}
+	for _, addr := range opt.Replicas {
+		replicaOpt := opt
+		replicaOpt.Addr = addr
+		db, err := newBackend(replicaOpt)
+		if err != nil {
+			return nil, err
+		}
+		r.replicas = append(r.replicas, &replica{db: db, addr: addr})
+	}
+	return r, nil
+}
+
+// Connect connects the primary. Replicas that cannot be connected stay out
+// of rotation until a later Ping succeeds.
+func (r *Replicated) Connect() error {
+	if err := r.primary.Connect(); err != nil {
+		return err
+	}
+	for _, rep := range r.replicas {
+		r.connectReplica(rep)
+	}
+	return nil
+}
+
+func (r *Replicated) connectReplica(rep *replica) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.connected {
+		return
+	}
+	if err := rep.db.Connect(); err != nil {
+		r.logger.Warn("Connect replica error", "addr", rep.addr, "err", err)
+		return
+	}
+	rep.connected = true
+	r.setHealthy(rep, true)
+}
+
+func (rep *replica) isConnected() bool {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	return rep.connected
+}
+
+func (r *Replicated) setHealthy(rep *replica, healthy bool) {
+	var v int32
+	if healthy {
+		v = 1
+	}
+	if old := atomic.SwapInt32(&rep.healthy, v); old == v {
+		return
+	}
+	if healthy {
+		r.logger.Info("Replica added to rotation", "addr", rep.addr)
+		metrics.Add("replicas_healthy", 1)
+	} else {
+		r.logger.Warn("Replica ejected from rotation", "addr", rep.addr)
+		metrics.Add("replicas_healthy", -1)
+	}
+}
+
+// pick returns the next healthy replica, or nil when there is none.
+func (r *Replicated) pick() *replica {
+	n := len(r.replicas)
+	start := atomic.AddUint32(&r.next, 1)
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(int(start)+i)%n]
+		if atomic.LoadInt32(&rep.healthy) == 1 {
+			return rep
+		}
+	}
+	return nil
+}
+
+// Query ...
+func (r *Replicated) Query(query string, args ...interface{}) (*Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext ...
+func (r *Replicated) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	if usePrimary(ctx) {
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	rep := r.pick()
+	if rep == nil {
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	rows, err := rep.db.QueryContext(ctx, query, args...)
+	if err == nil || ctx.Err() != nil {
+		return rows, err
+	}
+	if pingErr := rep.db.PingContext(ctx); pingErr != nil {
+		r.setHealthy(rep, false)
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	return nil, err
+}
+
+// Execute ...
+func (r *Replicated) Execute(query string, args ...interface{}) (sql.Result, error) {
+	return r.primary.Execute(query, args...)
+}
+
+// ExecuteContext ...
+func (r *Replicated) ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.primary.ExecuteContext(ctx, query, args...)
+}
+
+// InsertContext ...
+func (r *Replicated) InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return r.primary.InsertContext(ctx, query, args...)
+}
+
+// Disconnect ...
+func (r *Replicated) Disconnect() error {
+	for _, rep := range r.replicas {
+		rep.mu.Lock()
+		if rep.connected {
+			rep.db.Disconnect()
+			rep.connected = false
+			r.setHealthy(rep, false)
+		}
+		rep.mu.Unlock()
+	}
+	return r.primary.Disconnect()
+}
+
+// Ping ...
+func (r *Replicated) Ping() error {
+	return r.PingContext(context.Background())
+}
+
+// PingContext pings the primary and updates the rotation of the replicas.
+func (r *Replicated) PingContext(ctx context.Context) error {
+	for _, rep := range r.replicas {
+		if !rep.isConnected() {
+			r.connectReplica(rep)
+			continue
+		}
+		if err := rep.db.PingContext(ctx); err != nil {
+			if ctx.Err() == nil {
+				r.setHealthy(rep, false)
+			}
+			continue
+		}
+		r.setHealthy(rep, true)
+	}
+	return r.primary.PingContext(ctx)
+}
+
+// BeginTx ...
+func (r *Replicated) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	return r.primary.BeginTx(ctx, opts)
+}
+
+// WithTx ...
+func (r *Replicated) WithTx(ctx context.Context, fn func(Tx) error) error {
+	return r.primary.WithTx(ctx, fn)
+}
+
+var _ Database = (*Replicated)(nil)
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// sqlDB implements Database on top of database/sql for a given driver.
+type sqlDB struct {
+	driverName   string
+	dataSource   func() (string, error)
+	dialect      dialect
+	pool         poolOptions
+	stmts        *stmtCache
+	queryTimeout time.Duration
+	isolation    IsolationLevel
+
+	// mu guards conn, which Connect and Disconnect replace while queries
+	// run, e.g. when a Supervisor reconnects.
+	mu   sync.RWMutex
+	conn *sql.DB
+}
+
+type poolOptions struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+}
+
+func newSQLDB(driverName string, dataSource func() (string, error), dialect dialect, opt Options) *sqlDB {
+	return &sqlDB{
+		driverName: driverName,
+		dataSource: dataSource,
+		dialect:    dialect,
+		pool: poolOptions{
+			maxOpenConns:    opt.MaxOpenConns,
+			maxIdleConns:    opt.MaxIdleConns,
+			connMaxLifetime: opt.ConnMaxLifetime,
+			connMaxIdleTime: opt.ConnMaxIdleTime,
+		},
+		stmts:        newStmtCache(opt.StmtCacheSize),
+		queryTimeout: opt.QueryTimeout,
+		isolation:    opt.Isolation,
+	}
+}
+
+// Connect opens the connection pool and pings the database. A pool which
+// cannot ping is closed again, and when the pool is already open it is only
+// pinged, so failed and repeated connects leave no pools behind.
+func (d *sqlDB) Connect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil {
+		return d.ping(context.Background(), d.conn)
+	}
+	dsn, err := d.dataSource()
+	if err != nil {
+		return err
+	}
+	conn, err := sql.Open(d.driverName, dsn)
+	if err != nil {
+		return err
+	}
+	conn.SetMaxOpenConns(d.pool.maxOpenConns)
+	conn.SetMaxIdleConns(d.pool.maxIdleConns)
+	conn.SetConnMaxLifetime(d.pool.connMaxLifetime)
+	conn.SetConnMaxIdleTime(d.pool.connMaxIdleTime)
+	if err := d.ping(context.Background(), conn); err != nil {
+		conn.Close()
+		return err
+	}
+	d.conn = conn
+	return nil
+}
+
+// getConn returns the connection pool, or nil when it is not connected.
+func (d *sqlDB) getConn() *sql.DB {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.conn
+}
+
+// Query ...
+func (d *sqlDB) Query(query string, args ...interface{}) (*Rows, error) {
+	return d.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext ...
+func (d *sqlDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	conn := d.getConn()
+	if conn == nil {
+		return nil, ErrConnNotExist
+	}
+	ctx, cancel := withTimeout(ctx, d.queryTimeout)
+	stmt, releaseStmt, err := d.stmts.prepare(ctx, conn, d.dialect.rewrite(query))
+	if err != nil {
+		cancel()
+		return nil, d.dialect.convertError(ctx, err)
+	}
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		releaseStmt()
+		cancel()
+		return nil, d.dialect.convertError(ctx, err)
+	}
+	release := func() {
+		releaseStmt()
+		cancel()
+	}
+	return &Rows{Rows: rows, ctx: ctx, release: release}, nil
+}
+
+// Execute ...
+func (d *sqlDB) Execute(query string, args ...interface{}) (sql.Result, error) {
+	return d.ExecuteContext(context.Background(), query, args...)
+}
+
+// ExecuteContext ...
+func (d *sqlDB) ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn := d.getConn()
+	if conn == nil {
+		return nil, ErrConnNotExist
+	}
+	ctx, cancel := withTimeout(ctx, d.queryTimeout)
+	defer cancel()
+	stmt, releaseStmt, err := d.stmts.prepare(ctx, conn, d.dialect.rewrite(query))
+	if err != nil {
+		return nil, d.dialect.convertError(ctx, err)
+	}
+	defer releaseStmt()
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return nil, d.dialect.convertError(ctx, err)
+	}
+	return result, nil
+}
+
+// InsertContext ...
+func (d *sqlDB) InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if d.dialect.returning {
+		rows, err := d.QueryContext(ctx, query+" RETURNING id", args...)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		return scanID(rows)
+	}
+	result, err := d.ExecuteContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Disconnect ...
+func (d *sqlDB) Disconnect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == nil {
+		return ErrConnNotExist
+	}
+	d.stmts.close()
+	if err := d.conn.Close(); err != nil {
+		return err
+	}
+	d.conn = nil
+	return nil
+}
+
+// Ping ...
+func (d *sqlDB) Ping() error {
+	return d.PingContext(context.Background())
+}
+
+// PingContext ...
+func (d *sqlDB) PingContext(ctx context.Context) error {
+	conn := d.getConn()
+	if conn == nil {
+		return ErrConnNotExist
+	}
+	return d.ping(ctx, conn)
+}
+
+func (d *sqlDB) ping(ctx context.Context, conn *sql.DB) error {
+	ctx, cancel := withTimeout(ctx, d.queryTimeout)
+	defer cancel()
+	return d.dialect.convertError(ctx, conn.PingContext(ctx))
+}
+
+// BeginTx ...
+func (d *sqlDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	conn := d.getConn()
+	if conn == nil {
+		return nil, ErrConnNotExist
+	}
+	if opts == nil {
+		opts = d.isolation.TxOptions()
+	}
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, d.dialect.convertError(ctx, err)
+	}
+	return &sqlTx{tx: tx, dialect: d.dialect, queryTimeout: d.queryTimeout}, nil
+}
+
+// WithTx ...
+func (d *sqlDB) WithTx(ctx context.Context, fn func(Tx) error) error {
+	return runTx(ctx, d, fn)
+}
+
+// scanID reads the id returned by `INSERT ... RETURNING id`.
+func scanID(rows *Rows) (int64, error) {
+	var id int64
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, sql.ErrNoRows
+	}
+	if err := rows.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, rows.Close()
+}
+
+var _ Database = (*sqlDB)(nil)
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/inconshreveable/log15"
+)
+
+// fakeDriver is a database/sql driver whose servers, named by the DSN, fail
+// their pings a number of times. It counts the pools which are open.
+type fakeDriver struct {
+	mu      sync.Mutex
+	servers map[string]*fakeServer
+}
+
+type fakeServer struct {
+	// failures is the number of the next pings which fail.
+	failures int
+	// pools is the number of open pools of the server.
+	pools int
+}
+
+var testDriver = &fakeDriver{servers: map[string]*fakeServer{}}
+
+func init() {
+	sql.Register("fake", testDriver)
+}
+
+// server returns the server name, which fails its first failures pings.
+func (d *fakeDriver) server(name string, failures int) *fakeServer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := &fakeServer{failures: failures}
+	d.servers[name] = s
+	return s
+}
+
+func (d *fakeDriver) openPools(s *fakeServer) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return s.pools
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("fake: use OpenConnector")
+}
+
+// OpenConnector is called by sql.Open, once per pool.
+func (d *fakeDriver) OpenConnector(name string) (driver.Connector, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.servers[name]
+	s.pools++
+	return &fakeConnector{driver: d, server: s}, nil
+}
+
+type fakeConnector struct {
+	driver *fakeDriver
+	server *fakeServer
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver { return c.driver }
+
+// Close is called when the pool is closed.
+func (c *fakeConnector) Close() error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.server.pools--
+	return nil
+}
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	d := c.connector.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s := c.connector.server; s.failures > 0 {
+		s.failures--
+		return errors.New("fake: connection refused")
+	}
+	return nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake: not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("fake: not supported") }
+
+func newFakeDB(name string) *sqlDB {
+	return newSQLDB("fake", func() (string, error) { return name, nil }, sqliteDialect, Options{})
+}
+
+func TestSQLDBConnect(t *testing.T) {
+	const failures = 3
+	server := testDriver.server(t.Name(), failures)
+	db := newFakeDB(t.Name())
+	for i := 0; i < failures; i++ {
+		if err := db.Connect(); err == nil {
+			t.Fatalf("Connect() #%d succeeded, want the ping to fail", i+1)
+		}
+		if n := testDriver.openPools(server); n != 0 {
+			t.Fatalf("%d pools open after a failed Connect(), want 0", n)
+		}
+		if err := db.PingContext(context.Background()); err != ErrConnNotExist {
+			t.Errorf("PingContext() after a failed Connect() error = %v, want ErrConnNotExist", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		if n := testDriver.openPools(server); n != 1 {
+			t.Errorf("%d pools open after Connect() #%d, want 1", n, i+1)
+		}
+	}
+	if err := db.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if n := testDriver.openPools(server); n != 0 {
+		t.Errorf("%d pools open after Disconnect(), want 0", n)
+	}
+}
+
+func TestReplicatedConnect(t *testing.T) {
+	const failures = 3
+	primary := testDriver.server(t.Name()+"/primary", 0)
+	replicaServer := testDriver.server(t.Name()+"/replica", failures)
+	rep := &replica{db: newFakeDB(t.Name() + "/replica"), addr: "replica"}
+	r := &Replicated{
+		primary:  newFakeDB(t.Name() + "/primary"),
+		replicas: []*replica{rep},
+		logger:   log15.New(),
+	}
+	r.logger.SetHandler(log15.DiscardHandler())
+	if err := r.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	// Every health check retries the replica until it connects, and once it
+	// is connected its pool is pinged instead of opened again.
+	for i := 0; i < failures+2; i++ {
+		if n := testDriver.openPools(replicaServer); n > 1 {
+			t.Fatalf("%d replica pools open, want at most 1", n)
+		}
+		if err := r.PingContext(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !rep.isConnected() || r.pick() != rep {
+		t.Error("replica not in rotation after it connected")
+	}
+	if n := testDriver.openPools(replicaServer); n != 1 {
+		t.Errorf("%d replica pools open, want 1", n)
+	}
+	if err := r.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if n, m := testDriver.openPools(primary), testDriver.openPools(replicaServer); n != 0 || m != 0 {
+		t.Errorf("%d primary and %d replica pools open after Disconnect(), want none", n, m)
+	}
+}
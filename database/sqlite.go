@@ -0,0 +1,41 @@
+package database
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/driver" // for sql.Open
+	_ "github.com/ncruces/go-sqlite3/embed"  // for the SQLite binary
+)
+
+// SQLite ...
+type SQLite struct {
+	*sqlDB
+}
+
+// NewSQLite ...
+func NewSQLite(opt Options) Database {
+	params := url.Values{}
+	params.Add("_txlock", "immediate")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "foreign_keys(1)")
+	dsn := "file:" + opt.Path + "?" + params.Encode()
+	return &SQLite{
+		sqlDB: newSQLDB("sqlite3", func() (string, error) { return dsn, nil }, sqliteDialect, opt),
+	}
+}
+
+var sqliteDialect = dialect{
+	onConflict: true,
+	isDuplicate: func(err error) bool {
+		var liteErr *sqlite3.Error
+		if !errors.As(err, &liteErr) {
+			return false
+		}
+		code := liteErr.ExtendedCode()
+		return code == sqlite3.CONSTRAINT_UNIQUE || code == sqlite3.CONSTRAINT_PRIMARYKEY
+	},
+}
+
+var _ Database = (*SQLite)(nil)
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestSQLite returns a connected SQLite database in a temporary file with
+// a notes table.
+func newTestSQLite(t *testing.T) Database {
+	db := NewSQLite(Options{Path: filepath.Join(t.TempDir(), "bbs.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	if _, err := db.Execute(`CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, body TEXT NOT NULL UNIQUE)`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// countNotes returns the number of rows in notes.
+func countNotes(t *testing.T, db Database) int {
+	rows, err := db.QueryContext(context.Background(), `SELECT COUNT(*) FROM notes`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	for rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return n
+}
+
+func TestSQLite(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	if _, err := db.ExecuteContext(ctx, `INSERT INTO notes(body) VALUES (?)`, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT id, body FROM notes WHERE body=?`, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal("the inserted note was not found")
+	}
+	var id int
+	var body string
+	if err := rows.Scan(&id, &body); err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 || body != "hello" {
+		t.Errorf("note = %d, %q, want 1, hello", id, body)
+	}
+}
+
+func TestSQLiteWithTx(t *testing.T) {
+	errFail := errors.New("fail")
+	tests := []struct {
+		name    string
+		fn      func(Tx) error
+		panics  bool
+		wantErr error
+		want    int
+	}{
+		{"commit", func(Tx) error { return nil }, false, nil, 1},
+		{"rollback on error", func(Tx) error { return errFail }, false, errFail, 0},
+		{"rollback on panic", func(Tx) error { panic(errFail) }, true, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestSQLite(t)
+			ctx := context.Background()
+			defer func() {
+				if p := recover(); (p != nil) != tt.panics {
+					t.Errorf("WithTx() panic = %v, want panic %v", p, tt.panics)
+				}
+				if got := countNotes(t, db); got != tt.want {
+					t.Errorf("%d notes after WithTx(), want %d", got, tt.want)
+				}
+			}()
+			err := db.WithTx(ctx, func(tx Tx) error {
+				if _, err := tx.ExecuteContext(ctx, `INSERT INTO notes(body) VALUES (?)`, "hello"); err != nil {
+					return err
+				}
+				return tt.fn(tx)
+			})
+			if err != tt.wantErr {
+				t.Errorf("WithTx() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSQLiteDuplicate(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	if _, err := db.InsertContext(ctx, `INSERT INTO notes(body) VALUES (?)`, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.InsertContext(ctx, `INSERT INTO notes(body) VALUES (?)`, "hello")
+	if !IsDuplicate(err) {
+		t.Errorf("InsertContext() of a duplicate error = %v, want a DuplicateError", err)
+	}
+	err = db.WithTx(ctx, func(tx Tx) error {
+		_, err := tx.InsertContext(ctx, `INSERT INTO notes(body) VALUES (?)`, "hello")
+		return err
+	})
+	if !IsDuplicate(err) {
+		t.Errorf("InsertContext() of a duplicate in a transaction error = %v, want a DuplicateError", err)
+	}
+}
+
+func TestSQLiteTimeout(t *testing.T) {
+	// count never ends, so it runs until its deadline.
+	const count = `WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM n) SELECT x FROM n`
+	tests := []struct {
+		name  string
+		query func(ctx context.Context, db Database) (*Rows, error)
+	}{
+		{"query", func(ctx context.Context, db Database) (*Rows, error) {
+			return db.QueryContext(ctx, count)
+		}},
+		{"transaction", func(ctx context.Context, db Database) (*Rows, error) {
+			tx, err := db.BeginTx(context.Background(), nil)
+			if err != nil {
+				return nil, err
+			}
+			t.Cleanup(func() { tx.Rollback() })
+			return tx.QueryContext(ctx, count)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestSQLite(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			rows, err := tt.query(ctx, db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			// The rows are closed at the deadline, so either Scan or Next
+			// fails first.
+			var n int
+			for err == nil && rows.Next() {
+				err = rows.Scan(&n)
+			}
+			if err == nil {
+				err = rows.Err()
+			}
+			if err != ErrTimeout {
+				t.Errorf("error at the deadline = %v, want ErrTimeout", err)
+			}
+			if n == 0 {
+				t.Error("no rows read before the deadline")
+			}
+			if err := rows.Scan(&n); err != ErrTimeout {
+				t.Errorf("Scan() after the deadline error = %v, want ErrTimeout", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtCache is a bounded LRU cache of prepared statements keyed by SQL text.
+// A statement evicted while it is still in use is closed by the last user.
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		lru:   list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// prepare returns a prepared statement for query and the function to call
+// once the statement is no longer used.
+func (c *stmtCache) prepare(ctx context.Context, conn *sql.DB, query string) (*sql.Stmt, func(), error) {
+	if c.size <= 0 {
+		metrics.Add("stmt_cache_misses", 1)
+		stmt, err := conn.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stmt, func() { stmt.Close() }, nil
+	}
+	c.mu.Lock()
+	if elem, ok := c.items[query]; ok {
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*cachedStmt)
+		entry.refs++
+		c.mu.Unlock()
+		metrics.Add("stmt_cache_hits", 1)
+		return entry.stmt, c.releaseFunc(entry), nil
+	}
+	c.mu.Unlock()
+
+	metrics.Add("stmt_cache_misses", 1)
+	stmt, err := conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[query]; ok {
+		// Another goroutine prepared the same query in the meantime.
+		stmt.Close()
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*cachedStmt)
+		entry.refs++
+		return entry.stmt, c.releaseFunc(entry), nil
+	}
+	entry := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		metrics.Add("stmt_cache_evictions", 1)
+	}
+	return stmt, c.releaseFunc(entry), nil
+}
+
+// releaseFunc returns the function releasing one use of entry. Calling it
+// again does nothing, so that it cannot release the use of another caller.
+func (c *stmtCache) releaseFunc(entry *cachedStmt) func() {
+	released := false
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if released {
+			return
+		}
+		released = true
+		entry.refs--
+		if entry.evicted && entry.refs == 0 {
+			entry.stmt.Close()
+		}
+	}
+}
+
+// remove must be called with c.mu held.
+func (c *stmtCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cachedStmt)
+	delete(c.items, entry.query)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// close removes every statement from the cache.
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// stmtOpen reports whether stmt, a SELECT of one number, can still be run.
+func stmtOpen(stmt *sql.Stmt) bool {
+	var n int
+	return stmt.QueryRow().Scan(&n) == nil
+}
+
+func TestStmtCache(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		// wantLen is the number of cached statements after both are
+		// prepared, and wantFirstOpen whether the first one stays open once
+		// released.
+		wantLen       int
+		wantFirstOpen bool
+	}{
+		{"disabled", 0, 0, false},
+		{"first evicted while in use", 1, 1, false},
+		{"both cached", 2, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestSQLite(t).(*SQLite).conn
+			ctx := context.Background()
+			c := newStmtCache(tt.size)
+			first, releaseFirst, err := c.prepare(ctx, conn, "SELECT 1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, releaseSecond, err := c.prepare(ctx, conn, "SELECT 2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.lru.Len(); got != tt.wantLen {
+				t.Errorf("%d statements cached, want %d", got, tt.wantLen)
+			}
+			if !stmtOpen(first) || !stmtOpen(second) {
+				t.Fatal("a statement in use was closed")
+			}
+			releaseFirst()
+			releaseSecond()
+			if got := stmtOpen(first); got != tt.wantFirstOpen {
+				t.Errorf("first statement open after release = %v, want %v", got, tt.wantFirstOpen)
+			}
+			again, releaseAgain, err := c.prepare(ctx, conn, "SELECT 2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer releaseAgain()
+			if cached := again == second; cached != (tt.size > 0) {
+				t.Errorf("second statement reused = %v, want %v", cached, tt.size > 0)
+			}
+			c.close()
+			if tt.size > 0 && !stmtOpen(again) {
+				t.Error("a statement in use was closed with the cache")
+			}
+		})
+	}
+}
+
+func TestStmtCacheRows(t *testing.T) {
+	db := NewSQLite(Options{Path: filepath.Join(t.TempDir(), "bbs.db"), StmtCacheSize: 1})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Disconnect()
+	ctx := context.Background()
+	rows, err := db.QueryContext(ctx, `SELECT 1 UNION ALL SELECT 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	// The other query evicts the statement of rows, which must stay open
+	// until rows are closed.
+	other, err := db.QueryContext(ctx, `SELECT 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+	var sum int
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		sum += n
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 3 {
+		t.Errorf("sum of the rows = %d, want 3", sum)
+	}
+	rows.Close()
+	rows.Close()
+}
+
+func TestStmtCacheDoubleRelease(t *testing.T) {
+	conn := newTestSQLite(t).(*SQLite).conn
+	ctx := context.Background()
+	c := newStmtCache(1)
+	stmt, releaseFirst, err := c.prepare(ctx, conn, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, releaseSecond, err := c.prepare(ctx, conn, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Evict the statement while both still use it.
+	_, releaseOther, err := c.prepare(ctx, conn, "SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseOther()
+	releaseFirst()
+	releaseFirst()
+	if !stmtOpen(stmt) {
+		t.Fatal("a statement in use was closed by a double release")
+	}
+	releaseSecond()
+	if stmtOpen(stmt) {
+		t.Error("an evicted statement stays open after its last release")
+	}
+}
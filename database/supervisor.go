@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/inconshreveable/log15"
+)
+
+// ErrUnavailable is returned by a Supervisor while the database is down.
+var ErrUnavailable = errors.New("database: temporarily unavailable")
+
+// State is the connection state of a Supervisor.
+type State int32
+
+const (
+	// StateConnecting ...
+	StateConnecting State = iota
+	// StateUp ...
+	StateUp
+	// StateDown ...
+	StateDown
+)
+
+// String ...
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateUp:
+		return "up"
+	case StateDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// Status is a snapshot of the state of a Supervisor.
+type Status struct {
+	State State
+	Since time.Time
+	Err   error
+}
+
+// SupervisorOptions ...
+type SupervisorOptions struct {
+	// CheckInterval is the interval of the health checks while the database is up.
+	CheckInterval time.Duration
+	// MinBackoff and MaxBackoff bound the delay between reconnect attempts.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Prepare runs once the database is first connected, before it is
+	// reported up, e.g. to migrate the schema. While it fails the database
+	// is reported down and it is retried with the reconnect backoff, unless
+	// it returns a PermanentError.
+	Prepare func(ctx context.Context) error
+}
+
+// PermanentError is returned by Prepare for errors which retrying cannot
+// fix, such as a schema this binary does not match. Run stops with it.
+type PermanentError struct {
+	Err error
+}
+
+// Error ...
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap ...
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Supervisor is a Database which watches the connection of another Database
+// and reconnects it with exponential backoff. While the database is down,
+// operations fail fast with ErrUnavailable.
+type Supervisor struct {
+	db      Database
+	opt     SupervisorOptions
+	state   int32
+	checkCh chan struct{}
+	logger  log15.Logger
+	// prepared is only used by Run.
+	prepared bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewSupervisor ...
+func NewSupervisor(db Database, opt SupervisorOptions) *Supervisor {
+	if opt.CheckInterval <= 0 {
+		opt.CheckInterval = 10 * time.Second
+	}
+	if opt.MinBackoff <= 0 {
+		opt.MinBackoff = 500 * time.Millisecond
+	}
+	if opt.MaxBackoff < opt.MinBackoff {
+		opt.MaxBackoff = opt.MinBackoff
+	}
+	s := &Supervisor{
+		db:      db,
+		opt:     opt,
+		checkCh: make(chan struct{}, 1),
+		logger:  log15.New("module", "database", "database", "supervisor"),
+		status:  Status{State: StateConnecting, Since: time.Now()},
+	}
+	metrics.Set("state", expvar.Func(func() interface{} {
+		return s.Status().State.String()
+	}))
+	return s
+}
+
+// Status ...
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Supervisor) setState(state State, err error) {
+	s.mu.Lock()
+	prev := s.status.State
+	if prev == state {
+		s.status.Err = err
+		s.mu.Unlock()
+		return
+	}
+	s.status = Status{State: state, Since: time.Now(), Err: err}
+	atomic.StoreInt32(&s.state, int32(state))
+	s.mu.Unlock()
+
+	metrics.Add("state_transitions", 1)
+	if err != nil {
+		s.logger.Warn("Database state changed", "from", prev, "to", state, "err", err)
+		return
+	}
+	s.logger.Info("Database state changed", "from", prev, "to", state)
+}
+
+func (s *Supervisor) up() bool {
+	return State(atomic.LoadInt32(&s.state)) == StateUp
+}
+
+// Run connects the database and watches it until ctx is done, or until
+// Prepare fails with a PermanentError, and then disconnects it.
+func (s *Supervisor) Run(ctx context.Context) error {
+	backoff := s.opt.MinBackoff
+	// The first attempt is made right away.
+	var wait time.Duration
+	for {
+		select {
+		case <-time.After(wait):
+		case <-s.checkCh:
+		case <-ctx.Done():
+			if err := s.db.Disconnect(); err != nil && err != ErrConnNotExist {
+				return err
+			}
+			return ctx.Err()
+		}
+		wasUp := s.up()
+		if err := s.check(ctx); err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			var permanent *PermanentError
+			if errors.As(err, &permanent) {
+				s.setState(StateDown, err)
+				if err := s.db.Disconnect(); err != nil && err != ErrConnNotExist {
+					s.logger.Warn("Disconnect error", "err", err)
+				}
+				return err
+			}
+			if !wasUp {
+				metrics.Add("reconnect_attempts", 1)
+				backoff *= 2
+				if backoff > s.opt.MaxBackoff {
+					backoff = s.opt.MaxBackoff
+				}
+			}
+			s.setState(StateDown, err)
+			wait = jitter(backoff)
+			continue
+		}
+		backoff = s.opt.MinBackoff
+		s.setState(StateUp, nil)
+		wait = s.opt.CheckInterval
+	}
+}
+
+// check pings the database, connects it when it has never been connected and
+// prepares it when it was not yet.
+func (s *Supervisor) check(ctx context.Context) error {
+	err := s.db.PingContext(ctx)
+	if err == ErrConnNotExist {
+		err = s.db.Connect()
+	}
+	if err != nil || s.prepared {
+		return err
+	}
+	if s.opt.Prepare != nil {
+		if err := s.opt.Prepare(ctx); err != nil {
+			return err
+		}
+	}
+	s.prepared = true
+	return nil
+}
+
+// jitter returns a random duration in [d/2, d).
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// failed asks Run for an immediate health check after an operation failed.
+func (s *Supervisor) failed(err error) {
+	if err == nil || err == ErrTimeout || IsDuplicate(err) {
+		return
+	}
+	select {
+	case s.checkCh <- struct{}{}:
+	default:
+	}
+}
+
+// Connect ...
+func (s *Supervisor) Connect() error {
+	if err := s.db.Connect(); err != nil {
+		s.setState(StateDown, err)
+		return err
+	}
+	s.setState(StateUp, nil)
+	return nil
+}
+
+// Query ...
+func (s *Supervisor) Query(query string, args ...interface{}) (*Rows, error) {
+	return s.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext ...
+func (s *Supervisor) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	if !s.up() {
+		return nil, ErrUnavailable
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	s.failed(err)
+	return rows, err
+}
+
+// Execute ...
+func (s *Supervisor) Execute(query string, args ...interface{}) (sql.Result, error) {
+	return s.ExecuteContext(context.Background(), query, args...)
+}
+
+// ExecuteContext ...
+func (s *Supervisor) ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !s.up() {
+		return nil, ErrUnavailable
+	}
+	result, err := s.db.ExecuteContext(ctx, query, args...)
+	s.failed(err)
+	return result, err
+}
+
+// InsertContext ...
+func (s *Supervisor) InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if !s.up() {
+		return 0, ErrUnavailable
+	}
+	id, err := s.db.InsertContext(ctx, query, args...)
+	s.failed(err)
+	return id, err
+}
+
+// Disconnect ...
+func (s *Supervisor) Disconnect() error {
+	return s.db.Disconnect()
+}
+
+// Ping ...
+func (s *Supervisor) Ping() error {
+	return s.PingContext(context.Background())
+}
+
+// PingContext ...
+func (s *Supervisor) PingContext(ctx context.Context) error {
+	if !s.up() {
+		return ErrUnavailable
+	}
+	err := s.db.PingContext(ctx)
+	s.failed(err)
+	return err
+}
+
+// BeginTx ...
+func (s *Supervisor) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	if !s.up() {
+		return nil, ErrUnavailable
+	}
+	tx, err := s.db.BeginTx(ctx, opts)
+	s.failed(err)
+	return tx, err
+}
+
+// WithTx ...
+func (s *Supervisor) WithTx(ctx context.Context, fn func(Tx) error) error {
+	return runTx(ctx, s, fn)
+}
+
+var _ Database = (*Supervisor)(nil)
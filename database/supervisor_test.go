@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("down")
+
+// fakeDB is a Database whose connection fails while down. Only the methods
+// the Supervisor tests use are implemented.
+type fakeDB struct {
+	Database
+
+	mu        sync.Mutex
+	down      bool
+	connected bool
+	// failures is the number of the next connects which fail.
+	failures     int
+	connects     int
+	disconnected bool
+}
+
+func (f *fakeDB) Connect() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connects++
+	if f.down || f.failures > 0 {
+		f.failures--
+		return errDown
+	}
+	f.connected = true
+	return nil
+}
+
+func (f *fakeDB) PingContext(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case !f.connected:
+		return ErrConnNotExist
+	case f.down:
+		return errDown
+	}
+	return nil
+}
+
+func (f *fakeDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, errDown
+	}
+	return &Rows{}, nil
+}
+
+func (f *fakeDB) Disconnect() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.disconnected = true
+	return nil
+}
+
+func (f *fakeDB) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// waitState waits until s reaches state.
+func waitState(t *testing.T, s *Supervisor, state State) {
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Status().State != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %v, want %v", s.Status().State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	const failures = 3
+	db := &fakeDB{failures: failures}
+	s := NewSupervisor(db, SupervisorOptions{
+		CheckInterval: time.Hour,
+		MinBackoff:    time.Millisecond,
+		MaxBackoff:    4 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	if _, err := s.QueryContext(ctx, "SELECT 1"); err != ErrUnavailable {
+		t.Errorf("QueryContext() while connecting error = %v, want ErrUnavailable", err)
+	}
+	waitState(t, s, StateUp)
+	db.mu.Lock()
+	if db.connects != failures+1 {
+		t.Errorf("%d connects, want %d", db.connects, failures+1)
+	}
+	db.mu.Unlock()
+	if _, err := s.QueryContext(ctx, "SELECT 1"); err != nil {
+		t.Errorf("QueryContext() while up error = %v", err)
+	}
+
+	// A failed query makes Run check the database at once, rather than
+	// after the hour long CheckInterval.
+	db.setDown(true)
+	if _, err := s.QueryContext(ctx, "SELECT 1"); err != errDown {
+		t.Errorf("QueryContext() of a failing database error = %v, want %v", err, errDown)
+	}
+	waitState(t, s, StateDown)
+	if status := s.Status(); status.Err != errDown {
+		t.Errorf("Status().Err = %v, want %v", status.Err, errDown)
+	}
+	if _, err := s.QueryContext(ctx, "SELECT 1"); err != ErrUnavailable {
+		t.Errorf("QueryContext() while down error = %v, want ErrUnavailable", err)
+	}
+	if err := s.PingContext(ctx); err != ErrUnavailable {
+		t.Errorf("PingContext() while down error = %v, want ErrUnavailable", err)
+	}
+
+	db.setDown(false)
+	waitState(t, s, StateUp)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+	if !db.disconnected {
+		t.Error("Run() did not disconnect the database")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []time.Duration{0, time.Nanosecond, time.Millisecond, time.Second}
+	for _, d := range tests {
+		for i := 0; i < 100; i++ {
+			if got := jitter(d); got < d/2 || got > d {
+				t.Fatalf("jitter(%v) = %v, want within [%v, %v]", d, got, d/2, d)
+			}
+		}
+	}
+}
+
+func TestSupervisorPrepare(t *testing.T) {
+	const failures = 2
+	errPrepare := errors.New("prepare")
+	db := &fakeDB{}
+	var mu sync.Mutex
+	calls, succeeded := 0, 0
+	s := NewSupervisor(db, SupervisorOptions{
+		CheckInterval: time.Hour,
+		MinBackoff:    time.Millisecond,
+		MaxBackoff:    4 * time.Millisecond,
+		Prepare: func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			if calls <= failures {
+				return errPrepare
+			}
+			succeeded++
+			return nil
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	waitState(t, s, StateUp)
+	// Losing and regaining the connection does not prepare the database
+	// again.
+	db.setDown(true)
+	s.QueryContext(ctx, "SELECT 1")
+	waitState(t, s, StateDown)
+	db.setDown(false)
+	waitState(t, s, StateUp)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != failures+1 || succeeded != 1 {
+		t.Errorf("Prepare called %d times and succeeded %d times, want %d and once", calls, succeeded, failures+1)
+	}
+}
+
+func TestSupervisorPermanentError(t *testing.T) {
+	errSchema := errors.New("schema is behind")
+	db := &fakeDB{}
+	calls := 0
+	s := NewSupervisor(db, SupervisorOptions{
+		CheckInterval: time.Hour,
+		MinBackoff:    time.Millisecond,
+		MaxBackoff:    4 * time.Millisecond,
+		Prepare: func(ctx context.Context) error {
+			calls++
+			return &PermanentError{Err: errSchema}
+		},
+	})
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errSchema) {
+			t.Errorf("Run() error = %v, want %v", err, errSchema)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() retried a permanent error")
+	}
+	if calls != 1 {
+		t.Errorf("Prepare called %d times, want once", calls)
+	}
+	if status := s.Status(); status.State != StateDown || !errors.Is(status.Err, errSchema) {
+		t.Errorf("Status() = %v, %v, want down with %v", status.State, status.Err, errSchema)
+	}
+	if !db.disconnected {
+		t.Error("Run() did not disconnect the database")
+	}
+	if _, err := s.QueryContext(context.Background(), "SELECT 1"); err != ErrUnavailable {
+		t.Errorf("QueryContext() after Run() error = %v, want ErrUnavailable", err)
+	}
+}
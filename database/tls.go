@@ -0,0 +1,72 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// tlsConfig builds the client TLS configuration for mode. It returns nil
+// when TLS is disabled.
+func tlsConfig(mode, caFile, addr string) (*tls.Config, error) {
+	var roots *x509.CertPool
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("database: no certificate found in %s", caFile)
+		}
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	switch mode {
+	case TLSDisable, "":
+		return nil, nil
+	case TLSRequire:
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	case TLSVerifyCA:
+		// Verify the chain ourselves since the host name check cannot be
+		// turned off separately.
+		return &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+				return verifyChain(raw, roots)
+			},
+		}, nil
+	case TLSVerifyFull:
+		return &tls.Config{RootCAs: roots, ServerName: host}, nil
+	default:
+		return nil, fmt.Errorf("database: unknown TLS mode %q", mode)
+	}
+}
+
+func verifyChain(raw [][]byte, roots *x509.CertPool) error {
+	if len(raw) == 0 {
+		return errors.New("database: server sent no certificate")
+	}
+	certs := make([]*x509.Certificate, len(raw))
+	for i, der := range raw {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
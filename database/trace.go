@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/inconshreveable/log15"
+)
+
+// Tracer creates spans, e.g. backed by a distributed tracing system.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span ...
+type Span interface {
+	SetAttribute(key string, value interface{})
+	SetError(err error)
+	End()
+}
+
+// LogTracer is a Tracer which logs every finished span at debug level.
+type LogTracer struct {
+	logger log15.Logger
+}
+
+// NewLogTracer ...
+func NewLogTracer(logger log15.Logger) *LogTracer {
+	return &LogTracer{
+		logger: logger,
+	}
+}
+
+type spanKey struct{}
+
+// StartSpan ...
+func (t *LogTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &logSpan{
+		logger: t.logger,
+		name:   name,
+		id:     newSpanID(),
+		start:  time.Now(),
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*logSpan); ok {
+		span.parentID = parent.id
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type logSpan struct {
+	logger   log15.Logger
+	name     string
+	id       string
+	parentID string
+	start    time.Time
+	attrs    []interface{}
+	err      error
+}
+
+// SetAttribute ...
+func (s *logSpan) SetAttribute(key string, value interface{}) {
+	s.attrs = append(s.attrs, key, value)
+}
+
+// SetError ...
+func (s *logSpan) SetError(err error) {
+	s.err = err
+}
+
+// End ...
+func (s *logSpan) End() {
+	ctx := []interface{}{"span", s.name, "id", s.id, "parent", s.parentID, "elapsed", time.Since(s.start)}
+	ctx = append(ctx, s.attrs...)
+	if s.err != nil {
+		ctx = append(ctx, "err", s.err)
+	}
+	s.logger.Debug("Span", ctx...)
+}
+
+func newSpanID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
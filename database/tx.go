@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type sqlTx struct {
+	tx           *sql.Tx
+	dialect      dialect
+	queryTimeout time.Duration
+}
+
+// QueryContext ...
+func (t *sqlTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	ctx, cancel := withTimeout(ctx, t.queryTimeout)
+	rows, err := t.tx.QueryContext(ctx, t.dialect.rewrite(query), args...)
+	if err != nil {
+		cancel()
+		return nil, t.dialect.convertError(ctx, err)
+	}
+	return &Rows{Rows: rows, ctx: ctx, release: cancel}, nil
+}
+
+// ExecuteContext ...
+func (t *sqlTx) ExecuteContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, t.queryTimeout)
+	defer cancel()
+	result, err := t.tx.ExecContext(ctx, t.dialect.rewrite(query), args...)
+	if err != nil {
+		return nil, t.dialect.convertError(ctx, err)
+	}
+	return result, nil
+}
+
+// InsertContext ...
+func (t *sqlTx) InsertContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if t.dialect.returning {
+		rows, err := t.QueryContext(ctx, query+" RETURNING id", args...)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		return scanID(rows)
+	}
+	result, err := t.ExecuteContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Commit ...
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback ...
+func (t *sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+var _ Tx = (*sqlTx)(nil)
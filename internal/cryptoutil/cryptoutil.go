@@ -1,6 +1,7 @@
 package cryptoutil
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"fmt"
 )
@@ -9,8 +10,9 @@ const (
 	stretchingCount = 10
 )
 
-// GenerateHash ...
-func GenerateHash(passwd string) string {
+// legacyHash is the unsalted hash passwords were stored as before
+// PasswordHasher. It is only used to verify them until they are rehashed.
+func legacyHash(passwd string) string {
 	data := []byte(passwd)
 	hashed := sha256.Sum256(data)
 	for i := 0; i < stretchingCount-1; i++ {
@@ -18,3 +20,11 @@ func GenerateHash(passwd string) string {
 	}
 	return fmt.Sprint(hashed)
 }
+
+// DeriveKey returns the key of secret for purpose, so that one secret keys
+// several uses and a key of one use is worthless for another.
+func DeriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,180 @@
+package cryptoutil
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// AlgorithmArgon2id hashes passwords with argon2id, the default.
+	AlgorithmArgon2id = "argon2id"
+	// AlgorithmBcrypt hashes passwords with bcrypt.
+	AlgorithmBcrypt = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+
+	// maxArgon2Memory, maxArgon2Time, maxArgon2Threads and maxArgon2KeyLength
+	// bound the parameters of stored hashes, so that a tampered hash cannot
+	// make a sign in take gigabytes or minutes.
+	maxArgon2Memory    = 1 << 20 // 1 GiB in KiB
+	maxArgon2Time      = 16
+	maxArgon2Threads   = 64
+	maxArgon2KeyLength = 128
+)
+
+// ErrPasswordTooLong is returned by bcrypt for passwords over 72 bytes.
+var ErrPasswordTooLong = bcrypt.ErrPasswordTooLong
+
+// errInvalidHash is returned for stored hashes which cannot be parsed.
+var errInvalidHash = errors.New("cryptoutil: invalid password hash")
+
+// PasswordHasher hashes passwords into PHC strings, which carry their
+// algorithm, parameters and salt.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether encoded was made by another algorithm or
+	// with other parameters than the hasher's.
+	NeedsRehash(encoded string) bool
+}
+
+// PasswordOptions ...
+type PasswordOptions struct {
+	Algorithm string
+	// Argon2Memory is in KiB.
+	Argon2Memory  uint
+	Argon2Time    uint
+	Argon2Threads uint
+	BcryptCost    int
+}
+
+// RegisterFlags registers the command line flags for opt on fs.
+func (opt *PasswordOptions) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&opt.Algorithm, "password-hash", AlgorithmArgon2id, "specify the password hash algorithm of new hashes: argon2id or bcrypt")
+	fs.UintVar(&opt.Argon2Memory, "argon2-memory", 64*1024, "specify the memory argon2id uses per hash in KiB")
+	fs.UintVar(&opt.Argon2Time, "argon2-time", 3, "specify the number of argon2id passes")
+	fs.UintVar(&opt.Argon2Threads, "argon2-threads", 4, "specify the argon2id parallelism")
+	fs.IntVar(&opt.BcryptCost, "bcrypt-cost", 12, "specify the bcrypt cost")
+}
+
+// NewPasswordHasher returns the hasher selected by opt.
+func NewPasswordHasher(opt PasswordOptions) (PasswordHasher, error) {
+	switch opt.Algorithm {
+	case AlgorithmArgon2id, "":
+		if opt.Argon2Memory == 0 || opt.Argon2Memory > maxArgon2Memory || opt.Argon2Time == 0 || opt.Argon2Time > maxArgon2Time ||
+			opt.Argon2Threads == 0 || opt.Argon2Threads > maxArgon2Threads {
+			return nil, fmt.Errorf("cryptoutil: invalid argon2id parameters m=%d t=%d p=%d", opt.Argon2Memory, opt.Argon2Time, opt.Argon2Threads)
+		}
+		return &Argon2id{Memory: uint32(opt.Argon2Memory), Time: uint32(opt.Argon2Time), Threads: uint8(opt.Argon2Threads)}, nil
+	case AlgorithmBcrypt:
+		if opt.BcryptCost < bcrypt.MinCost || opt.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("cryptoutil: bcrypt cost %d out of range", opt.BcryptCost)
+		}
+		return &Bcrypt{Cost: opt.BcryptCost}, nil
+	}
+	return nil, fmt.Errorf("cryptoutil: unknown password hash algorithm %q", opt.Algorithm)
+}
+
+// VerifyPassword reports in constant time whether password matches encoded,
+// which may be an argon2id, a bcrypt or a legacy hash. Hashes of no known
+// format, like the empty ones of restored archives without personal data,
+// match no password.
+func VerifyPassword(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		h, salt, key, err := parseArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		derived := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(derived, key) == 1, nil
+	case strings.HasPrefix(encoded, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(encoded, "["):
+		return subtle.ConstantTimeCompare([]byte(legacyHash(password)), []byte(encoded)) == 1, nil
+	}
+	return false, nil
+}
+
+// Argon2id ...
+type Argon2id struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+// Hash returns a hash like "$argon2id$v=19$m=65536,t=3,p=4$salt$key".
+func (h *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// NeedsRehash ...
+func (h *Argon2id) NeedsRehash(encoded string) bool {
+	p, _, key, err := parseArgon2id(encoded)
+	return err != nil || *p != *h || len(key) != argon2KeyLength
+}
+
+// parseArgon2id returns the parameters, the salt and the key of encoded, or
+// errInvalidHash when it is malformed or its parameters are out of bounds.
+func parseArgon2id(encoded string) (*Argon2id, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, nil, nil, errInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errInvalidHash
+	}
+	h := &Argon2id{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.Memory, &h.Time, &h.Threads); err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > maxArgon2KeyLength {
+		return nil, nil, nil, errInvalidHash
+	}
+	if h.Memory == 0 || h.Memory > maxArgon2Memory || h.Time == 0 || h.Time > maxArgon2Time || h.Threads == 0 || h.Threads > maxArgon2Threads {
+		return nil, nil, nil, errInvalidHash
+	}
+	return h, salt, key, nil
+}
+
+// Bcrypt ...
+type Bcrypt struct {
+	Cost int
+}
+
+// Hash returns a hash like "$2a$12$saltkey". It returns ErrPasswordTooLong
+// for passwords over 72 bytes.
+func (h *Bcrypt) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(b), err
+}
+
+// NeedsRehash ...
+func (h *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
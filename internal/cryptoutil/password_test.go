@@ -0,0 +1,74 @@
+package cryptoutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	h := &Argon2id{Memory: 64, Time: 1, Threads: 1}
+	encoded, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// withParams replaces the m=,t=,p= parameters of encoded.
+	withParams := func(params string) string {
+		parts := strings.Split(encoded, "$")
+		parts[3] = params
+		return strings.Join(parts, "$")
+	}
+	tests := []struct {
+		name     string
+		password string
+		encoded  string
+		want     bool
+		wantErr  bool
+	}{
+		{"argon2id", "secret", encoded, true, false},
+		{"argon2id wrong password", "wrong", encoded, false, false},
+		{"argon2id other parameters", "secret", withParams("m=128,t=1,p=1"), false, false},
+		{"argon2id memory too large", "secret", withParams("m=4294967295,t=1,p=1"), false, true},
+		{"argon2id time too large", "secret", withParams("m=64,t=1000000,p=1"), false, true},
+		{"argon2id threads too large", "secret", withParams("m=64,t=1,p=255"), false, true},
+		{"argon2id zero time", "secret", withParams("m=64,t=0,p=1"), false, true},
+		{"argon2id malformed", "secret", "$argon2id$v=19$m=64$salt$key", false, true},
+		{"legacy", "secret", legacyHash("secret"), true, false},
+		{"legacy wrong password", "wrong", legacyHash("secret"), false, false},
+		{"empty", "secret", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyPassword(tt.password, tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     PasswordOptions
+		wantErr bool
+	}{
+		{"argon2id", PasswordOptions{Algorithm: AlgorithmArgon2id, Argon2Memory: 64 * 1024, Argon2Time: 3, Argon2Threads: 4}, false},
+		{"argon2id memory too large", PasswordOptions{Algorithm: AlgorithmArgon2id, Argon2Memory: maxArgon2Memory + 1, Argon2Time: 3, Argon2Threads: 4}, true},
+		{"argon2id time too large", PasswordOptions{Algorithm: AlgorithmArgon2id, Argon2Memory: 64 * 1024, Argon2Time: maxArgon2Time + 1, Argon2Threads: 4}, true},
+		{"argon2id no threads", PasswordOptions{Algorithm: AlgorithmArgon2id, Argon2Memory: 64 * 1024, Argon2Time: 3}, true},
+		{"bcrypt", PasswordOptions{Algorithm: AlgorithmBcrypt, BcryptCost: 10}, false},
+		{"bcrypt cost too large", PasswordOptions{Algorithm: AlgorithmBcrypt, BcryptCost: 40}, true},
+		{"unknown", PasswordOptions{Algorithm: "md5"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPasswordHasher(tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPasswordHasher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
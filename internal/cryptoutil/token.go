@@ -0,0 +1,67 @@
+package cryptoutil
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for tokens which are malformed, expired or
+// signed for another purpose or state.
+var ErrInvalidToken = errors.New("cryptoutil: invalid token")
+
+// SignToken returns a URL-safe token for purpose and the user userID which is
+// valid until expiresAt. The token is also signed with state, such as the
+// password hash of the user, so it stops being valid once state changes; this
+// makes tokens single-use without storing them.
+func SignToken(secret []byte, purpose string, userID int, expiresAt time.Time, state string) string {
+	payload := strconv.Itoa(userID) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(secret, purpose, payload, state))
+}
+
+// TokenUser returns the user ID of token without verifying it, so that the
+// state of the user can be read for VerifyToken.
+func TokenUser(token string) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidToken
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+// VerifyToken returns nil when token was signed for purpose and state and
+// has not expired at now, and ErrInvalidToken otherwise.
+func VerifyToken(secret []byte, token, purpose, state string, now time.Time) error {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return ErrInvalidToken
+	}
+	payload := token[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(mac, tokenMAC(secret, purpose, payload, state)) {
+		return ErrInvalidToken
+	}
+	parts := strings.Split(payload, ".")
+	if len(parts) != 2 {
+		return ErrInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func tokenMAC(secret []byte, purpose, payload, state string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(purpose + "\x00" + payload + "\x00" + state))
+	return h.Sum(nil)
+}
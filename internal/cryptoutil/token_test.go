@@ -0,0 +1,78 @@
+package cryptoutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := SignToken(secret, "reset", 42, now.Add(time.Hour), "hash1")
+	// tamper returns token with the character at i replaced.
+	tamper := func(i int) string {
+		b := []byte(token)
+		if b[i] == 'A' {
+			b[i] = 'B'
+		} else {
+			b[i] = 'A'
+		}
+		return string(b)
+	}
+	tests := []struct {
+		name    string
+		secret  []byte
+		token   string
+		purpose string
+		state   string
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", secret, token, "reset", "hash1", now, false},
+		{"just before expiry", secret, token, "reset", "hash1", now.Add(time.Hour - time.Second), false},
+		{"expired", secret, token, "reset", "hash1", now.Add(time.Hour), true},
+		{"wrong purpose", secret, token, "verify", "hash1", now, true},
+		// The token was used, so the state it was signed with changed.
+		{"state changed", secret, token, "reset", "hash2", now, true},
+		{"other secret", []byte("other"), token, "reset", "hash1", now, true},
+		{"tampered mac", secret, tamper(len(token) - 1), "reset", "hash1", now, true},
+		{"tampered user", secret, "43" + strings.TrimPrefix(token, "42"), "reset", "hash1", now, true},
+		{"extended expiry", secret, tamper(len("42.")), "reset", "hash1", now, true},
+		{"empty", secret, "", "reset", "hash1", now, true},
+		{"no mac", secret, "42.1704070800", "reset", "hash1", now, true},
+		{"mac not base64", secret, "42.1704070800.!!!", "reset", "hash1", now, true},
+		{"too many parts", secret, "1." + token, "reset", "hash1", now, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyToken(tt.secret, tt.token, tt.purpose, tt.state, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && err != ErrInvalidToken {
+				t.Errorf("VerifyToken() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestTokenUser(t *testing.T) {
+	token := SignToken([]byte("secret"), "reset", 42, time.Now().Add(time.Hour), "hash")
+	tests := []struct {
+		token   string
+		want    int
+		wantErr bool
+	}{
+		{token, 42, false},
+		{"", 0, true},
+		{"42.1704070800", 0, true},
+		{"x.1704070800.mac", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := TokenUser(tt.token)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("TokenUser(%q) = %d, %v, want %d, wantErr %v", tt.token, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package cryptoutil
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPPeriod is the time step of the TOTP codes, the default of RFC 6238
+	// which authenticator apps assume.
+	TOTPPeriod = 30 * time.Second
+
+	// totpDigits is the length of the TOTP codes.
+	totpDigits = 6
+
+	// totpSkew is the number of steps before and after the current one whose
+	// codes are accepted, for clocks which drift.
+	totpSkew = 1
+
+	// recoveryCodeAlphabet leaves out the letters and digits which are easily
+	// mistaken for each other.
+	recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random 160-bit TOTP secret in base32, as
+// authenticator apps take it.
+func NewTOTPSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(key), nil
+}
+
+// TOTPStep returns the time step of t.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode returns the code of secret at step, as RFC 6238 makes it with
+// HMAC-SHA1 and six digits.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// VerifyTOTP returns the step whose code of secret is code, looking one step
+// around now, or false when there is none. Callers must reject steps which
+// were used already, so that a code works once.
+func VerifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.Replace(strings.TrimSpace(code), " ", "", -1)
+	if secret == "" || len(code) != totpDigits {
+		return 0, false
+	}
+	current := TOTPStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		want, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth URI of secret, which authenticator apps read
+// from a QR code.
+func TOTPURI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// NewRecoveryCodes returns n random recovery codes like "k3mz-8qpa-x2d7".
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		var b strings.Builder
+		for b.Len() < 14 {
+			if b.Len() == 4 || b.Len() == 9 {
+				b.WriteByte('-')
+			}
+			c, err := randomChar(recoveryCodeAlphabet)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(c)
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// randomChar returns a uniformly random character of alphabet.
+func randomChar(alphabet string) (byte, error) {
+	// Bytes past the last multiple of len(alphabet) are dropped, so that no
+	// character is likelier than another.
+	limit := 256 - 256%len(alphabet)
+	var buf [1]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+		if int(buf[0]) < limit {
+			return alphabet[int(buf[0])%len(alphabet)], nil
+		}
+	}
+}
+
+// HashRecoveryCode returns the hash recovery codes are stored as. The codes
+// are random, so a fast hash suffices; case, spaces and dashes are ignored.
+func HashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,136 @@
+package cryptoutil
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC 6238 vectors have eight digits; the codes are their last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode() at %d = %q, want %q", tt.unix, got, tt.want)
+		}
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode() of a malformed secret succeeded")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current", rfc6238Secret, code(step), step, true},
+		{"previous step", rfc6238Secret, code(step - 1), step - 1, true},
+		{"next step", rfc6238Secret, code(step + 1), step + 1, true},
+		{"two steps ago", rfc6238Secret, code(step - 2), 0, false},
+		{"spaces", rfc6238Secret, " 050 471 ", step, true},
+		{"lower case secret", strings.ToLower(rfc6238Secret), "050471", step, true},
+		{"wrong code", rfc6238Secret, "000000", 0, false},
+		{"too short", rfc6238Secret, "05047", 0, false},
+		{"too long", rfc6238Secret, "0504710", 0, false},
+		{"no secret", "", "050471", 0, false},
+		{"malformed secret", "not base32!", "050471", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := VerifyTOTP(tt.secret, tt.code, now)
+			if gotStep != tt.wantStep || ok != tt.wantOK {
+				t.Errorf("VerifyTOTP(%q) = %d, %v, want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 160 bits are 32 base32 characters without padding.
+	if len(secret) != 32 {
+		t.Errorf("NewTOTPSecret() = %q, want 32 characters", secret)
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Errorf("TOTPCode() of a new secret error = %v", err)
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	format := regexp.MustCompile(`^[` + recoveryCodeAlphabet + `]{4}-[` + recoveryCodeAlphabet + `]{4}-[` + recoveryCodeAlphabet + `]{4}$`)
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("NewRecoveryCodes(10) returned %d codes", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("recovery code %q is not like xxxx-xxxx-xxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q returned twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("k3mz-8qpa-x2d7")
+	tests := []struct {
+		name string
+		code string
+		same bool
+	}{
+		{"same", "k3mz-8qpa-x2d7", true},
+		{"upper case", "K3MZ-8QPA-X2D7", true},
+		{"without dashes", "k3mz8qpax2d7", true},
+		{"spaces", " k3mz 8qpa x2d7 ", true},
+		{"other code", "k3mz-8qpa-x2d8", false},
+		{"prefix", "k3mz-8qpa", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashRecoveryCode(tt.code); (got == want) != tt.same {
+				t.Errorf("HashRecoveryCode(%q) == HashRecoveryCode(%q) is %v, want %v", tt.code, "k3mz-8qpa-x2d7", got == want, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package flagutil
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvName returns the environment variable for the flag name, e.g.
+// "database-addr" with the prefix "BBS" is BBS_DATABASE_ADDR.
+func EnvName(prefix, name string) string {
+	return prefix + "_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// SetFromEnv sets the flags which were not given on the command line from
+// the environment variables named by EnvName.
+func SetFromEnv(fs *flag.FlagSet, prefix string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		key := EnvName(prefix, f.Name)
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return
+		}
+		if e := fs.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, key, e)
+		}
+	})
+	return err
+}
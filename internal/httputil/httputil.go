@@ -0,0 +1,22 @@
+package httputil
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address r was sent from, which is the first address
+// of X-Forwarded-For when trustForwardedFor is set behind a proxy.
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
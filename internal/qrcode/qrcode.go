@@ -0,0 +1,486 @@
+// Package qrcode encodes bytes as QR codes (ISO/IEC 18004) for the otpauth
+// URIs of two-factor enrolment. It only makes what those need: byte mode,
+// error correction level M and versions 1 to 10, which hold 213 bytes.
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ErrTooLong is returned for data which does not fit in version 10.
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// maxVersion is the largest version Encode makes.
+const maxVersion = 10
+
+// blocks describes the error correction blocks of a version at level M.
+type blocks struct {
+	ecLen int // error correction codewords per block
+	// short blocks of shortLen data codewords, then long blocks of one more.
+	short, shortLen, long int
+}
+
+func (b blocks) dataLen() int {
+	return b.short*b.shortLen + b.long*(b.shortLen+1)
+}
+
+// levelM are the blocks of versions 1 to 10 at level M.
+var levelM = [maxVersion + 1]blocks{
+	1:  {10, 1, 16, 0},
+	2:  {16, 1, 28, 0},
+	3:  {26, 1, 44, 0},
+	4:  {18, 2, 32, 0},
+	5:  {24, 2, 43, 0},
+	6:  {16, 4, 27, 0},
+	7:  {18, 4, 31, 0},
+	8:  {22, 2, 38, 2},
+	9:  {22, 3, 36, 2},
+	10: {26, 4, 43, 1},
+}
+
+// alignment are the centre coordinates of the alignment patterns.
+var alignment = [maxVersion + 1][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// Code is an encoded QR code.
+type Code struct {
+	// Size is the number of modules per side, without the quiet zone.
+	Size       int
+	version    int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// Encode returns the QR code of data in the smallest version which holds it.
+func Encode(data []byte) (*Code, error) {
+	version := 1
+	for ; version <= maxVersion; version++ {
+		if 4+countBits(version)+8*len(data) <= levelM[version].dataLen()*8 {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(version, dataCodewords(version, data)))
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // masks are their own inverse
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c, nil
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	c := &Code{Size: size, version: version}
+	c.modules = make([][]bool, size)
+	c.isFunction = make([][]bool, size)
+	for y := range c.modules {
+		c.modules[y] = make([]bool, size)
+		c.isFunction[y] = make([]bool, size)
+	}
+	return c
+}
+
+// Black reports whether the module at x, y is dark. Modules outside the code
+// are light, like the quiet zone.
+func (c *Code) Black(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// Image returns the code with a quiet zone of border modules, scale pixels
+// per module.
+func (c *Code) Image(scale, border int) image.Image {
+	side := (c.Size + 2*border) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			if c.Black(x/scale-border, y/scale-border) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
+}
+
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// dataCodewords returns data in byte mode, padded to the data capacity of
+// version.
+func dataCodewords(version int, data []byte) []byte {
+	var bits bitBuffer
+	bits.append(0x4, 4) // byte mode
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := levelM[version].dataLen() * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return codewords
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>uint(i)&1 != 0)
+	}
+}
+
+// interleave splits data into the blocks of version, adds their error
+// correction codewords and interleaves them.
+func interleave(version int, data []byte) []byte {
+	spec := levelM[version]
+	divisor := rsGenerator(spec.ecLen)
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < spec.short+spec.long; i++ {
+		n := spec.shortLen
+		if i >= spec.short {
+			n++
+		}
+		block := data[:n]
+		data = data[n:]
+		dataBlocks = append(dataBlocks, block)
+		ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+	}
+	var result []byte
+	for i := 0; i <= spec.shortLen; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ecLen; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// setFunction sets a module of a function pattern, which the data and the
+// masks leave alone.
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+	pos := alignment[c.version]
+	for i := range pos {
+		for j := range pos {
+			last := len(pos) - 1
+			// The corners hold the finder patterns.
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			c.drawAlignment(pos[i], pos[j])
+		}
+	}
+	// Reserve the format areas until the mask is chosen.
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// drawFinder draws a finder pattern and its separator around x, y.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the format information of level M and
+// mask.
+func (c *Code) drawFormatBits(mask int) {
+	data := 0<<3 | mask // level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true) // the dark module
+}
+
+// drawVersion draws both copies of the version information of versions 7 and
+// up.
+func (c *Code) drawVersion() {
+	if c.version < 7 {
+		return
+	}
+	rem := c.version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := c.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 != 0
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places data in the zigzag of two-module columns from the
+// bottom right, skipping the function patterns. Modules left over stay light.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 { // the vertical timing pattern
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.isFunction[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y][x] = data[i/8]>>uint(7-i%8)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules which mask selects.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// finderLike are the runs which look like a finder pattern, whose penalty
+// keeps scanners from mistaking them for one.
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores the current masking by the rules of the standard; the mask
+// with the lowest score is used.
+func (c *Code) penalty() int {
+	n := c.Size
+	p := 0
+	for _, vertical := range []bool{false, true} {
+		at := func(i, j int) bool {
+			if vertical {
+				return c.modules[j][i]
+			}
+			return c.modules[i][j]
+		}
+		for i := 0; i < n; i++ {
+			run := 1
+			for j := 1; j < n; j++ {
+				if at(i, j) == at(i, j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				p += run - 2
+			}
+			for j := 0; j+11 <= n; j++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if at(i, j+k) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				v := c.modules[y][x]
+				if c.modules[y-1][x] == v && c.modules[y][x-1] == v && c.modules[y-1][x-1] == v {
+					p += 3
+				}
+			}
+		}
+	}
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	p += k * 10
+	return p
+}
+
+// rsGenerator returns the Reed-Solomon generator polynomial of degree, highest
+// coefficient first without the leading 1.
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+// Package tripcode makes 2ch-compatible tripcodes, which let anonymous
+// posters prove they wrote several posts by giving a secret in the name field.
+package tripcode
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Invalid is the tripcode of keys which cannot make one, like the reserved
+// "$" keys.
+const Invalid = "???"
+
+// rawKey matches the "##" keys which give the DES key in hex and optionally
+// the salt.
+var rawKey = regexp.MustCompile(`^#([0-9A-Fa-f]{16})([./0-9A-Za-z]{0,2})$`)
+
+// saltReplacer maps the characters between '9' and 'A' and between 'Z' and
+// 'a' to letters, as 2ch does.
+var saltReplacer = strings.NewReplacer(
+	":", "A", ";", "B", "<", "C", "=", "D", ">", "E", "?", "F", "@", "G",
+	"[", "a", `\`, "b", "]", "c", "^", "d", "_", "e", "`", "f",
+)
+
+// Parse splits a name field into the name and the tripcode of the key after
+// the first '#' or '＃'. The tripcode is empty when there is no key. Marks
+// which would fake a tripcode or a capcode are replaced in the name.
+func Parse(field string) (string, string) {
+	name, key, ok := field, "", false
+	if i := strings.IndexAny(field, "#＃"); i >= 0 {
+		name = field[:i]
+		key = strings.TrimPrefix(strings.TrimPrefix(field[i:], "#"), "＃")
+		ok = true
+	}
+	name = strings.NewReplacer("◆", "◇", "★", "☆").Replace(strings.TrimSpace(name))
+	if !ok {
+		return name, ""
+	}
+	return name, Make(key)
+}
+
+// Make returns the tripcode of key: 10 characters made with crypt(3) for keys
+// shorter than 12 bytes in Shift_JIS and 12 characters made with SHA-1 for
+// longer ones.
+func Make(key string) string {
+	b, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(key))
+	if err != nil {
+		// Keys Shift_JIS cannot encode are used as UTF-8.
+		b = []byte(key)
+	}
+	if len(b) < 12 {
+		return classic(b)
+	}
+	switch b[0] {
+	case '#':
+		m := rawKey.FindSubmatch(b)
+		if m == nil {
+			return Invalid
+		}
+		raw := make([]byte, 8)
+		hex.Decode(raw, m[1])
+		salt := append(m[2], '.', '.')
+		return crypt(raw, [2]byte{salt[0], salt[1]})[3:]
+	case '$':
+		return Invalid
+	}
+	sum := sha1.Sum(b)
+	return strings.Replace(base64.StdEncoding.EncodeToString(sum[:])[:12], "+", ".", -1)
+}
+
+// classic returns the crypt(3) tripcode of key, salted with its second and
+// third characters.
+func classic(key []byte) string {
+	salt := []byte(string(key) + "H.")[1:3]
+	for i, c := range salt {
+		if c < '.' || c > 'z' {
+			salt[i] = '.'
+		}
+	}
+	s := saltReplacer.Replace(string(salt))
+	return crypt(key, [2]byte{s[0], s[1]})[3:]
+}
@@ -0,0 +1,56 @@
+package tripcode
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{"classic", "test", ".CzKQna1OU"},
+		{"classic istrip", "istrip", "/WG5qp963c"},
+		{"short key salted with H.", "a", "ZnBI2EKkq."},
+		{"salt replaced", "a:b", "5G6R5bcZ.A"},
+		{"eleven bytes", "abcdefghijk", "/Pbzx9FKd2"},
+		{"twelve bytes", "123456789012", "jZk8zfYo4m4X"},
+		{"long", "abcdefghijklmnop", "FPOZUois0Ynm"},
+		{"raw key", "#7465737474657374", "Fvh4dplHjg"},
+		{"raw key with salt", "#7465737474657374ab", "qU0xf8M452"},
+		{"raw key malformed", "#746573747465737g", Invalid},
+		{"reserved", "$abcdefghijkl", Invalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Make(tt.key); got != tt.want {
+				t.Errorf("Make(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		field        string
+		wantName     string
+		wantTripcode string
+	}{
+		{"name only", "nanashi", "nanashi", ""},
+		{"tripcode", "nanashi#test", "nanashi", ".CzKQna1OU"},
+		{"full width mark", "nanashi＃test", "nanashi", ".CzKQna1OU"},
+		{"key only", "#istrip", "", "/WG5qp963c"},
+		{"empty key", "nanashi#", "nanashi", Make("")},
+		{"spaces trimmed", " nanashi #test", "nanashi", ".CzKQna1OU"},
+		{"fake tripcode", "◆abc★", "◇abc☆", ""},
+		{"japanese", "名無し#テスト", "名無し", Make("テスト")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, tripcode := Parse(tt.field)
+			if name != tt.wantName || tripcode != tt.wantTripcode {
+				t.Errorf("Parse(%q) = %q, %q, want %q, %q", tt.field, name, tripcode, tt.wantName, tt.wantTripcode)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Maildir is a Mailer which delivers the messages into a maildir, which mail
+// clients can open, or whose files can be read as they are.
+type Maildir struct {
+	from *mail.Address
+	dir  string
+}
+
+// NewMaildir returns a Maildir delivering into dir, which is created if
+// needed.
+func NewMaildir(from *mail.Address, dir string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &Maildir{from: from, dir: dir}, nil
+}
+
+// Send writes msg into tmp and moves it into new once it is complete, as the
+// maildir format requires.
+func (m *Maildir) Send(ctx context.Context, msg *Message) error {
+	now := time.Now()
+	b, err := msg.Bytes(m.from, now)
+	if err != nil {
+		return err
+	}
+	r := make([]byte, 8)
+	if _, err := rand.Read(r); err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.%d_%s.%s", now.Unix(), os.Getpid(), hex.EncodeToString(r), host)
+	tmp := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.dir, "new", name))
+}
@@ -0,0 +1,70 @@
+// Package mailer sends the emails of the application through SMTP, or writes
+// them to the log or to a maildir to try them out without a mail server.
+package mailer
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/mail"
+	"time"
+)
+
+const (
+	// DriverLog writes the messages to the log, the default.
+	DriverLog = "log"
+
+	// DriverSMTP sends the messages to an SMTP server.
+	DriverSMTP = "smtp"
+
+	// DriverMaildir writes every message as a file into a maildir.
+	DriverMaildir = "maildir"
+)
+
+// Mailer ...
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Options ...
+type Options struct {
+	Driver string
+	// From is the sender of every message, like "bbs <bbs@example.com>".
+	From string
+
+	SMTPAddr     string
+	SMTPUser     string
+	SMTPPassword string
+	SMTPTimeout  time.Duration
+
+	// Dir is the maildir of DriverMaildir.
+	Dir string
+}
+
+// RegisterFlags registers the command line flags for opt on fs.
+func (opt *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&opt.Driver, "mail-driver", DriverLog, "specify how emails are sent (log, smtp, maildir)")
+	fs.StringVar(&opt.From, "mail-from", "bbs-sample <bbs-sample@localhost>", "specify the sender of emails")
+	fs.StringVar(&opt.SMTPAddr, "mail-smtp-addr", "localhost:25", "specify the address of the SMTP server")
+	fs.StringVar(&opt.SMTPUser, "mail-smtp-user", "", "specify the user to authenticate to the SMTP server with (no authentication when empty)")
+	fs.StringVar(&opt.SMTPPassword, "mail-smtp-password", "", "specify the password of the SMTP user")
+	fs.DurationVar(&opt.SMTPTimeout, "mail-smtp-timeout", 30*time.Second, "specify the timeout of sending an email over SMTP")
+	fs.StringVar(&opt.Dir, "mail-dir", "mail", "specify the maildir emails are written into (maildir only)")
+}
+
+// New returns the Mailer selected by opt.Driver.
+func New(opt Options) (Mailer, error) {
+	from, err := mail.ParseAddress(opt.From)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid sender %q: %v", opt.From, err)
+	}
+	switch opt.Driver {
+	case DriverLog, "":
+		return NewLog(from), nil
+	case DriverSMTP:
+		return NewSMTP(from, opt), nil
+	case DriverMaildir:
+		return NewMaildir(from, opt.Dir)
+	}
+	return nil, fmt.Errorf("mailer: unknown driver %q", opt.Driver)
+}
@@ -0,0 +1,134 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	htemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	ttemplate "text/template"
+	"time"
+)
+
+// Message is an email with a plain text and an optional HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// NewMessage renders the message to to from the templates name.txt, whose
+// "subject" template is the subject, and name.html in dir, which may be
+// missing.
+func NewMessage(dir, name, to string, data interface{}) (*Message, error) {
+	text, err := ttemplate.ParseFiles(filepath.Join(dir, name+".txt"))
+	if err != nil {
+		return nil, err
+	}
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    body.String(),
+	}
+	html, err := htemplate.ParseFiles(filepath.Join(dir, name+".html"))
+	if os.IsNotExist(err) {
+		return msg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	body.Reset()
+	if err := html.Execute(&body, data); err != nil {
+		return nil, err
+	}
+	msg.HTML = body.String()
+	return msg, nil
+}
+
+// Bytes returns msg from from as a MIME message, multipart/alternative when
+// it has an HTML body.
+func (msg *Message) Bytes(from *mail.Address, date time.Time) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		buf.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/alternative; boundary="`+mw.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{`text/plain; charset="utf-8"`, msg.Text},
+		{`text/html; charset="utf-8"`, msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.Replace(s, "\n", "\r\n", -1))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the domain of from.
+func messageID(from *mail.Address) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
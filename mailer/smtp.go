@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTP is a Mailer which sends the messages to an SMTP server, upgrading the
+// connection with STARTTLS when the server offers it.
+type SMTP struct {
+	from     *mail.Address
+	addr     string
+	user     string
+	password string
+	timeout  time.Duration
+}
+
+// NewSMTP ...
+func NewSMTP(from *mail.Address, opt Options) *SMTP {
+	return &SMTP{
+		from:     from,
+		addr:     opt.SMTPAddr,
+		user:     opt.SMTPUser,
+		password: opt.SMTPPassword,
+		timeout:  opt.SMTPTimeout,
+	}
+}
+
+// Send ...
+func (s *SMTP) Send(ctx context.Context, msg *Message) error {
+	b, err := msg.Bytes(s.from, time.Now())
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.user != "" {
+		// PlainAuth refuses to send the password without TLS, except to
+		// localhost.
+		if err := c.Auth(smtp.PlainAuth("", s.user, s.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"strings"
+)
+
+// maxAnchorRange is the number of posts an anchor like >>1-1000 refers to at
+// most.
+const maxAnchorRange = 100
+
+// maxPostNoDigits keeps the post numbers of anchors well within int.
+const maxPostNoDigits = 6
+
+// anchorPrefixes start an anchor, in ASCII and in full width.
+var anchorPrefixes = []string{">>", "＞＞"}
+
+// Anchor is a reference written as >>From or >>From-To in a message to posts
+// of the same thread.
+type Anchor struct {
+	// Start and End are the byte offsets of the anchor in the message.
+	Start, End int
+	From, To   int
+}
+
+// PostNos returns the post numbers a refers to.
+func (a Anchor) PostNos() []int {
+	to := a.To
+	if to-a.From >= maxAnchorRange {
+		to = a.From + maxAnchorRange - 1
+	}
+	postNos := make([]int, 0, to-a.From+1)
+	for n := a.From; n <= to; n++ {
+		postNos = append(postNos, n)
+	}
+	return postNos
+}
+
+// ParseAnchors returns the anchors in message in the order they appear.
+func ParseAnchors(message string) []Anchor {
+	var anchors []Anchor
+	for i := 0; i < len(message); {
+		prefix := anchorPrefixAt(message, i)
+		if prefix == 0 {
+			i++
+			continue
+		}
+		from, end := parsePostNo(message, i+prefix)
+		if from == 0 {
+			i += prefix
+			continue
+		}
+		to := from
+		if end < len(message) && message[end] == '-' {
+			if n, rangeEnd := parsePostNo(message, end+1); n != 0 {
+				to, end = n, rangeEnd
+			}
+		}
+		if to < from {
+			from, to = to, from
+		}
+		anchors = append(anchors, Anchor{Start: i, End: end, From: from, To: to})
+		i = end
+	}
+	return anchors
+}
+
+func anchorPrefixAt(s string, i int) int {
+	for _, prefix := range anchorPrefixes {
+		if strings.HasPrefix(s[i:], prefix) {
+			return len(prefix)
+		}
+	}
+	return 0
+}
+
+// parsePostNo parses the post number at s[i:] and returns it with the offset
+// after it, or 0 when there is none.
+func parsePostNo(s string, i int) (int, int) {
+	n, end := 0, i
+	for end < len(s) && end-i < maxPostNoDigits && '0' <= s[end] && s[end] <= '9' {
+		n = n*10 + int(s[end]-'0')
+		end++
+	}
+	if end < len(s) && '0' <= s[end] && s[end] <= '9' {
+		// Too long to be a post number.
+		return 0, i
+	}
+	return n, end
+}
+
+// Backlinks returns the post numbers of the messages quoting each post of
+// msgs, keyed by the post number quoted.
+func Backlinks(msgs []*Message) map[int][]int {
+	backlinks := map[int][]int{}
+	for _, msg := range msgs {
+		quoted := map[int]bool{}
+		for _, anchor := range ParseAnchors(msg.Message) {
+			for _, n := range anchor.PostNos() {
+				if n == msg.PostNo || quoted[n] {
+					continue
+				}
+				quoted[n] = true
+				backlinks[n] = append(backlinks[n], msg.PostNo)
+			}
+		}
+	}
+	return backlinks
+}
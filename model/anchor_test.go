@@ -0,0 +1,67 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAnchors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []Anchor
+	}{
+		{"none", "hello", nil},
+		{"single", ">>12", []Anchor{{Start: 0, End: 4, From: 12, To: 12}}},
+		{"in text", "see >>3 ok", []Anchor{{Start: 4, End: 7, From: 3, To: 3}}},
+		{"range", ">>12-15", []Anchor{{Start: 0, End: 7, From: 12, To: 15}}},
+		{"reversed range", ">>15-12", []Anchor{{Start: 0, End: 7, From: 12, To: 15}}},
+		{"dash without end", ">>12-", []Anchor{{Start: 0, End: 4, From: 12, To: 12}}},
+		{"full width", "＞＞5", []Anchor{{Start: 0, End: 7, From: 5, To: 5}}},
+		{"several", ">>1 >>2-3", []Anchor{{Start: 0, End: 3, From: 1, To: 1}, {Start: 4, End: 9, From: 2, To: 3}}},
+		{"zero", ">>0", nil},
+		{"no number", ">>abc", nil},
+		{"too long", ">>1234567", nil},
+		{"longest", ">>123456", []Anchor{{Start: 0, End: 8, From: 123456, To: 123456}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAnchors(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAnchors(%q) = %+v, want %+v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnchorPostNos(t *testing.T) {
+	tests := []struct {
+		name    string
+		anchor  Anchor
+		wantLen int
+		wantEnd int
+	}{
+		{"single", Anchor{From: 3, To: 3}, 1, 3},
+		{"range", Anchor{From: 3, To: 5}, 3, 5},
+		{"capped", Anchor{From: 1, To: 1000}, maxAnchorRange, maxAnchorRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.anchor.PostNos()
+			if len(got) != tt.wantLen || got[0] != tt.anchor.From || got[len(got)-1] != tt.wantEnd {
+				t.Errorf("PostNos() = %v, want %d numbers from %d to %d", got, tt.wantLen, tt.anchor.From, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	msgs := []*Message{
+		{PostNo: 1, Message: "first"},
+		{PostNo: 2, Message: ">>1"},
+		{PostNo: 3, Message: ">>1 >>1-2 >>3"},
+	}
+	want := map[int][]int{1: {2, 3}, 2: {3}}
+	if got := Backlinks(msgs); !reflect.DeepEqual(got, want) {
+		t.Errorf("Backlinks() = %v, want %v", got, want)
+	}
+}
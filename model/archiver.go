@@ -0,0 +1,65 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/inconshreveable/log15"
+)
+
+// ArchiverOptions ...
+type ArchiverOptions struct {
+	// After is how long a thread may have no posts before it is archived,
+	// 0 disables archival.
+	After    time.Duration
+	Interval time.Duration
+}
+
+// Archiver periodically archives the inactive threads. Threads are archived
+// by a conditional update, so every instance may run an Archiver.
+type Archiver struct {
+	threads ThreadRepository
+	opt     ArchiverOptions
+	logger  log15.Logger
+}
+
+// NewArchiver ...
+func NewArchiver(store Store, opt ArchiverOptions) *Archiver {
+	return &Archiver{
+		threads: store.Threads(),
+		opt:     opt,
+		logger:  log15.New("module", "archiver"),
+	}
+}
+
+// Run archives the inactive threads every Interval until ctx is done.
+func (a *Archiver) Run(ctx context.Context) error {
+	if a.opt.After <= 0 || a.opt.Interval <= 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(a.opt.Interval)
+	defer ticker.Stop()
+	for {
+		a.archive(ctx, time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (a *Archiver) archive(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, a.opt.Interval)
+	defer cancel()
+	inactiveSince := now.Add(-a.opt.After).Format(TimeFormat)
+	n, err := a.threads.Archive(ctx, inactiveSince, now.Format(TimeFormat))
+	if err != nil {
+		a.logger.Warn("Archive threads error", "err", err)
+		return
+	}
+	if n > 0 {
+		a.logger.Info("Archived threads", "threads", n, "inactive_since", inactiveSince)
+	}
+}
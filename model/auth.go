@@ -0,0 +1,73 @@
+package model
+
+import (
+	"context"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+)
+
+// Authenticate returns the user with email whose password is password, or
+// ErrNotFound. A password hash which hasher would not make, such as a legacy
+// hash, is replaced by a new one once the password is verified.
+func Authenticate(ctx context.Context, store Store, hasher cryptoutil.PasswordHasher, email, password string) (*User, error) {
+	user, err := store.Users().FindByEmail(ctx, email)
+	if err == ErrNotFound {
+		// Hash anyway, so unknown emails take as long as wrong passwords.
+		hasher.Hash(password)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := cryptoutil.VerifyPassword(password, user.Password)
+	if err != nil {
+		// A corrupt hash is reported like a wrong password, so that the
+		// response does not tell which accounts have one.
+		log15.New("module", "model").Error("Verify password error", "user_id", user.ID, "err", err)
+		return nil, ErrNotFound
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if hasher.NeedsRehash(user.Password) {
+		rehash(ctx, store, hasher, user, password)
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// rehash replaces the password hash of user. Failures only delay the upgrade
+// to the next sign in, so they are logged.
+func rehash(ctx context.Context, store Store, hasher cryptoutil.PasswordHasher, user *User, password string) {
+	logger := log15.New("module", "model")
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		logger.Warn("Rehash password error", "user_id", user.ID, "err", err)
+		return
+	}
+	if err := store.Users().UpdatePassword(ctx, user.ID, hash); err != nil {
+		logger.Warn("Update password hash error", "user_id", user.ID, "err", err)
+		return
+	}
+	logger.Info("Rehashed password", "user_id", user.ID)
+}
+
+// ResetPassword replaces the password hash of user with hash and signs out its
+// sessions, all or nothing. The email of user counts as verified at now, since
+// the reset link was sent to it.
+func ResetPassword(ctx context.Context, store Store, user *User, hash, now string) error {
+	return store.WithTx(database.WithQueryName(ctx, "users.reset_password"), func(store Store) error {
+		if err := store.Users().UpdatePassword(ctx, user.ID, hash); err != nil {
+			return err
+		}
+		if !user.EmailVerified() {
+			if err := store.Users().VerifyEmail(ctx, user.ID, now); err != nil {
+				return err
+			}
+		}
+		return store.Sessions().DeleteByUserID(ctx, user.ID)
+	})
+}
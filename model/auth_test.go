@@ -0,0 +1,118 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+)
+
+func TestAuthenticate(t *testing.T) {
+	hasher := &cryptoutil.Argon2id{Memory: 64, Time: 1, Threads: 1}
+	hash, err := hasher.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, user := range []*User{
+				{Name: "alice", Email: "alice@example.com", Password: hash},
+				{Name: "bob", Email: "bob@example.com", Password: "$argon2id$v=19$m=64$salt$key"},
+			} {
+				if err := store.Users().Save(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tests := []struct {
+				name     string
+				email    string
+				password string
+				wantErr  error
+			}{
+				{"signed in", "alice@example.com", "secret", nil},
+				{"wrong password", "alice@example.com", "wrong", ErrNotFound},
+				{"unknown email", "carol@example.com", "secret", ErrNotFound},
+				{"corrupt hash", "bob@example.com", "secret", ErrNotFound},
+			}
+			for _, tt := range tests {
+				user, err := Authenticate(ctx, store, hasher, tt.email, tt.password)
+				if err != tt.wantErr {
+					t.Errorf("%s: Authenticate() error = %v, want %v", tt.name, err, tt.wantErr)
+					continue
+				}
+				if err == nil && (user.Email != tt.email || user.Password != "") {
+					t.Errorf("%s: Authenticate() = %+v, want the user without the password hash", tt.name, user)
+				}
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	errFail := errors.New("fail")
+	tests := []struct {
+		name string
+		// then runs after ResetPassword in the same transaction.
+		then       func() error
+		wantErr    error
+		wantPanic  bool
+		wantCommit bool
+	}{
+		{"commit", func() error { return nil }, nil, false, true},
+		{"rollback on error", func() error { return errFail }, errFail, false, false},
+		{"rollback on panic", func() error { panic(errFail) }, nil, true, false},
+	}
+	for _, tt := range tests {
+		for name, store := range testStores(t) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+				user := &User{Name: "alice", Email: "alice@example.com", Password: "old"}
+				if err := store.Users().Save(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+				session := &Session{ID: "session", UserID: user.ID, CreatedAt: "2024-01-01 00:00:00", LastSeenAt: "2024-01-01 00:00:00", ExpiresAt: "2099-01-01 00:00:00"}
+				if err := store.Sessions().Save(ctx, session); err != nil {
+					t.Fatal(err)
+				}
+
+				func() {
+					defer func() {
+						if p := recover(); (p != nil) != tt.wantPanic {
+							t.Errorf("WithTx() panic = %v, want panic %v", p, tt.wantPanic)
+						}
+					}()
+					err := store.WithTx(ctx, func(store Store) error {
+						if err := ResetPassword(ctx, store, user, "new", "2024-01-02 00:00:00"); err != nil {
+							return err
+						}
+						return tt.then()
+					})
+					if err != tt.wantErr {
+						t.Errorf("WithTx() error = %v, want %v", err, tt.wantErr)
+					}
+				}()
+
+				got, err := store.Users().Find(ctx, user.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = store.Sessions().Find(ctx, session.ID)
+				signedOut := err == ErrNotFound
+				if err != nil && !signedOut {
+					t.Fatal(err)
+				}
+				if reset := got.Password == "new"; reset != tt.wantCommit {
+					t.Errorf("password reset = %v, want %v", reset, tt.wantCommit)
+				}
+				if got.EmailVerified() != tt.wantCommit {
+					t.Errorf("email verified = %v, want %v", got.EmailVerified(), tt.wantCommit)
+				}
+				if signedOut != tt.wantCommit {
+					t.Errorf("signed out = %v, want %v", signedOut, tt.wantCommit)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// ErrNotFound ...
+var ErrNotFound = errors.New("model: not found")
+
+// DefaultBoardID is the board created by the migrations, which holds the
+// messages written before boards existed.
+const DefaultBoardID = 1
+
+// DefaultPosterName is the name of the anonymous posters who give none.
+const DefaultPosterName = "Anonymous"
+
+const (
+	// PostPolicyMembers lets every signed in user post.
+	PostPolicyMembers = "members"
+
+	// PostPolicyAdmins lets only admins post.
+	PostPolicyAdmins = "admins"
+)
+
+// Board ...
+type Board struct {
+	ID               int
+	Slug             string
+	Title            string
+	Description      string
+	SortOrder        int
+	PostPolicy       string
+	MaxMessageLength int
+	// MaxPostsPerThread closes the threads reaching it, 0 is unlimited.
+	MaxPostsPerThread int
+	ReadOnly          bool
+	// Anonymous boards show the name given with each post, or DefaultName,
+	// instead of the account name.
+	Anonymous bool
+	// AllowGuests lets visitors without an account read and post on an
+	// anonymous board.
+	AllowGuests bool
+	DefaultName string
+}
+
+// CanPost reports whether a user with role may post on b.
+func (b *Board) CanPost(role string) bool {
+	if b.ReadOnly {
+		return false
+	}
+	if role == RoleGuest && !b.GuestsAllowed() {
+		return false
+	}
+	if b.PostPolicy == PostPolicyAdmins {
+		return role == RoleAdmin
+	}
+	return true
+}
+
+// GuestsAllowed reports whether visitors without an account may read b.
+func (b *Board) GuestsAllowed() bool {
+	return b.Anonymous && b.AllowGuests
+}
+
+// BoardModel ...
+type BoardModel struct {
+	db database.Querier
+}
+
+// NewBoardModel ...
+func NewBoardModel(db database.Database) *BoardModel {
+	return &BoardModel{
+		db: db,
+	}
+}
+
+// WithTx returns a BoardModel which runs its queries in tx.
+func (b *BoardModel) WithTx(tx database.Tx) *BoardModel {
+	return &BoardModel{
+		db: tx,
+	}
+}
+
+const boardColumns = `id, slug, title, description, sort_order, post_policy, max_message_length, max_posts_per_thread, read_only, anonymous, allow_guests, default_name`
+
+// FindAll ...
+func (b *BoardModel) FindAll(ctx context.Context) ([]*Board, error) {
+	ctx = database.WithQueryName(ctx, "boards.find_all")
+	rows, err := b.db.QueryContext(ctx, `SELECT `+boardColumns+` FROM boards ORDER BY sort_order, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	boards := []*Board{}
+	for rows.Next() {
+		board, err := scanBoard(rows)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, board)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return boards, nil
+}
+
+// FindBySlug ...
+func (b *BoardModel) FindBySlug(ctx context.Context, slug string) (*Board, error) {
+	ctx = database.WithQueryName(ctx, "boards.find_by_slug")
+	return b.findOne(ctx, `SELECT `+boardColumns+` FROM boards WHERE slug=?`, slug)
+}
+
+// FindByID ...
+func (b *BoardModel) FindByID(ctx context.Context, id int) (*Board, error) {
+	ctx = database.WithQueryName(ctx, "boards.find_by_id")
+	return b.findOne(ctx, `SELECT `+boardColumns+` FROM boards WHERE id=?`, id)
+}
+
+func (b *BoardModel) findOne(ctx context.Context, query string, args ...interface{}) (*Board, error) {
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	return scanBoard(rows)
+}
+
+// Save saves board, whose DefaultName is DefaultPosterName unless set.
+func (b *BoardModel) Save(ctx context.Context, board *Board) error {
+	ctx = database.WithQueryName(ctx, "boards.save")
+	if board.DefaultName == "" {
+		board.DefaultName = DefaultPosterName
+	}
+	query := `
+	INSERT INTO boards(slug, title, description, sort_order, post_policy, max_message_length, max_posts_per_thread, read_only, anonymous, allow_guests, default_name)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	id, err := b.db.InsertContext(ctx, query, board.Slug, board.Title, board.Description, board.SortOrder, board.PostPolicy, board.MaxMessageLength, board.MaxPostsPerThread, board.ReadOnly, board.Anonymous, board.AllowGuests, board.DefaultName)
+	if err != nil {
+		return err
+	}
+	board.ID = int(id)
+	return nil
+}
+
+func scanBoard(rows *database.Rows) (*Board, error) {
+	board := &Board{}
+	err := rows.Scan(&board.ID, &board.Slug, &board.Title, &board.Description, &board.SortOrder, &board.PostPolicy, &board.MaxMessageLength, &board.MaxPostsPerThread, &board.ReadOnly, &board.Anonymous, &board.AllowGuests, &board.DefaultName)
+	if err != nil {
+		return nil, err
+	}
+	return board, nil
+}
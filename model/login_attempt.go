@@ -0,0 +1,109 @@
+package model
+
+import (
+	"context"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// LoginAttempt counts the failed sign ins of an account or an address.
+type LoginAttempt struct {
+	ID           string
+	Failures     int
+	LastFailedAt string
+	// LockedUntil is empty unless sign ins were locked.
+	LockedUntil string
+}
+
+// LoginAttemptModel ...
+type LoginAttemptModel struct {
+	db database.Querier
+}
+
+// NewLoginAttemptModel ...
+func NewLoginAttemptModel(db database.Database) *LoginAttemptModel {
+	return &LoginAttemptModel{
+		db: db,
+	}
+}
+
+// WithTx returns a LoginAttemptModel which runs its queries in tx.
+func (l *LoginAttemptModel) WithTx(tx database.Tx) *LoginAttemptModel {
+	return &LoginAttemptModel{
+		db: tx,
+	}
+}
+
+// Find returns ErrNotFound when id has no failures.
+func (l *LoginAttemptModel) Find(ctx context.Context, id string) (*LoginAttempt, error) {
+	ctx = database.WithQueryName(ctx, "login_attempts.find")
+	query := `SELECT id, failures, last_failed_at, locked_until FROM login_attempts WHERE id = ?`
+	rows, err := l.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	attempt := &LoginAttempt{}
+	if err := rows.Scan(&attempt.ID, &attempt.Failures, (*timeText)(&attempt.LastFailedAt), (*timeText)(&attempt.LockedUntil)); err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// Fail counts a failure of id at now. The count restarts when the previous
+// failure was before resetBefore.
+func (l *LoginAttemptModel) Fail(ctx context.Context, id, now, resetBefore string) error {
+	ctx = database.WithQueryName(ctx, "login_attempts.fail")
+	// An upsert, since a failed INSERT would abort the transaction on
+	// PostgreSQL when another instance counted the first failure meanwhile.
+	query := `
+	INSERT INTO login_attempts(id, failures, last_failed_at) VALUES (?, 1, ?)
+	ON DUPLICATE KEY UPDATE failures = CASE WHEN last_failed_at < ? THEN 1 ELSE failures + 1 END, last_failed_at = ?
+	`
+	if _, err := l.db.ExecuteContext(ctx, query, id, now, resetBefore, now); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Lock rejects the sign ins of id until until.
+func (l *LoginAttemptModel) Lock(ctx context.Context, id, until string) error {
+	ctx = database.WithQueryName(ctx, "login_attempts.lock")
+	query := `UPDATE login_attempts SET locked_until=? WHERE id=?`
+	if _, err := l.db.ExecuteContext(ctx, query, until, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reset forgets the failures of id.
+func (l *LoginAttemptModel) Reset(ctx context.Context, id string) error {
+	ctx = database.WithQueryName(ctx, "login_attempts.reset")
+	query := `DELETE FROM login_attempts WHERE id=?`
+	if _, err := l.db.ExecuteContext(ctx, query, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteStale deletes the attempts which last failed before before and are
+// not locked at now, and returns their number.
+func (l *LoginAttemptModel) DeleteStale(ctx context.Context, before, now string) (int, error) {
+	ctx = database.WithQueryName(ctx, "login_attempts.delete_stale")
+	query := `DELETE FROM login_attempts WHERE last_failed_at < ? AND (locked_until IS NULL OR locked_until <= ?)`
+	result, err := l.db.ExecuteContext(ctx, query, before, now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
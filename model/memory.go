@@ -0,0 +1,958 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// MemoryStore is a Store which keeps everything in memory. It is safe for
+// concurrent use and is meant for tests and the demo mode.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data memoryData
+}
+
+type memoryData struct {
+	boards        []Board
+	threads       []Thread
+	users         []User
+	messages      []Message
+	sessions      []Session
+	loginAttempts []LoginAttempt
+	recoveryCodes []RecoveryCode
+	lastPostNos   map[int]int // by thread ID
+	lastBoardID   int
+	lastThreadID  int
+	lastUserID    int
+	lastMessageID int
+	lastCodeID    int
+}
+
+// clone returns a copy of d which does not share its slices.
+func (d memoryData) clone() memoryData {
+	c := d
+	c.boards = append([]Board(nil), d.boards...)
+	c.threads = append([]Thread(nil), d.threads...)
+	c.users = append([]User(nil), d.users...)
+	c.messages = append([]Message(nil), d.messages...)
+	c.sessions = append([]Session(nil), d.sessions...)
+	c.loginAttempts = append([]LoginAttempt(nil), d.loginAttempts...)
+	c.recoveryCodes = append([]RecoveryCode(nil), d.recoveryCodes...)
+	c.lastPostNos = make(map[int]int, len(d.lastPostNos))
+	for id, postNo := range d.lastPostNos {
+		c.lastPostNos[id] = postNo
+	}
+	return c
+}
+
+// NewMemoryStore returns a MemoryStore with the default board, like a
+// freshly migrated database.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: memoryData{
+			boards: []Board{{
+				ID:                DefaultBoardID,
+				Slug:              "general",
+				Title:             "General",
+				Description:       "Anything goes.",
+				PostPolicy:        PostPolicyMembers,
+				MaxMessageLength:  1000,
+				MaxPostsPerThread: 1000,
+				DefaultName:       DefaultPosterName,
+			}},
+			lastPostNos: map[int]int{},
+			lastBoardID: DefaultBoardID,
+		},
+	}
+}
+
+// Boards ...
+func (s *MemoryStore) Boards() BoardRepository {
+	return &memoryBoards{store: s}
+}
+
+// Threads ...
+func (s *MemoryStore) Threads() ThreadRepository {
+	return &memoryThreads{store: s}
+}
+
+// Messages ...
+func (s *MemoryStore) Messages() MessageRepository {
+	return &memoryMessages{store: s}
+}
+
+// Users ...
+func (s *MemoryStore) Users() UserRepository {
+	return &memoryUsers{store: s}
+}
+
+// Sessions ...
+func (s *MemoryStore) Sessions() SessionRepository {
+	return &memorySessions{store: s}
+}
+
+// LoginAttempts ...
+func (s *MemoryStore) LoginAttempts() LoginAttemptRepository {
+	return &memoryLoginAttempts{store: s}
+}
+
+// RecoveryCodes ...
+func (s *MemoryStore) RecoveryCodes() RecoveryCodeRepository {
+	return &memoryRecoveryCodes{store: s}
+}
+
+// WithTx holds the store exclusively while fn runs and restores its previous
+// state unless fn returns nil.
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(Store) error) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := s.data.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			s.data = snapshot
+			panic(p)
+		}
+		if err != nil {
+			s.data = snapshot
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(&memoryTxStore{store: s})
+}
+
+// read runs fn with the read lock held unless locked is true.
+func (s *MemoryStore) read(locked bool, fn func(d *memoryData)) {
+	if !locked {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	fn(&s.data)
+}
+
+// write runs fn with the write lock held unless locked is true.
+func (s *MemoryStore) write(locked bool, fn func(d *memoryData) error) error {
+	if !locked {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn(&s.data)
+}
+
+// memoryTxStore is the Store passed to the function run by
+// MemoryStore.WithTx, whose repositories rely on the lock held by WithTx.
+type memoryTxStore struct {
+	store *MemoryStore
+}
+
+// Boards ...
+func (s *memoryTxStore) Boards() BoardRepository {
+	return &memoryBoards{store: s.store, locked: true}
+}
+
+// Threads ...
+func (s *memoryTxStore) Threads() ThreadRepository {
+	return &memoryThreads{store: s.store, locked: true}
+}
+
+// Messages ...
+func (s *memoryTxStore) Messages() MessageRepository {
+	return &memoryMessages{store: s.store, locked: true}
+}
+
+// Users ...
+func (s *memoryTxStore) Users() UserRepository {
+	return &memoryUsers{store: s.store, locked: true}
+}
+
+// Sessions ...
+func (s *memoryTxStore) Sessions() SessionRepository {
+	return &memorySessions{store: s.store, locked: true}
+}
+
+// LoginAttempts ...
+func (s *memoryTxStore) LoginAttempts() LoginAttemptRepository {
+	return &memoryLoginAttempts{store: s.store, locked: true}
+}
+
+// RecoveryCodes ...
+func (s *memoryTxStore) RecoveryCodes() RecoveryCodeRepository {
+	return &memoryRecoveryCodes{store: s.store, locked: true}
+}
+
+// WithTx runs fn in the current transaction.
+func (s *memoryTxStore) WithTx(ctx context.Context, fn func(Store) error) error {
+	return fn(s)
+}
+
+type memoryBoards struct {
+	store  *MemoryStore
+	locked bool
+}
+
+// FindAll ...
+func (m *memoryBoards) FindAll(ctx context.Context) ([]*Board, error) {
+	boards := []*Board{}
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, b := range d.boards {
+			b := b
+			boards = append(boards, &b)
+		}
+	})
+	sort.SliceStable(boards, func(i, j int) bool {
+		return boards[i].SortOrder < boards[j].SortOrder
+	})
+	return boards, nil
+}
+
+// FindBySlug ...
+func (m *memoryBoards) FindBySlug(ctx context.Context, slug string) (*Board, error) {
+	return m.find(func(b *Board) bool { return b.Slug == slug })
+}
+
+// FindByID ...
+func (m *memoryBoards) FindByID(ctx context.Context, id int) (*Board, error) {
+	return m.find(func(b *Board) bool { return b.ID == id })
+}
+
+func (m *memoryBoards) find(match func(*Board) bool) (*Board, error) {
+	var board *Board
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, b := range d.boards {
+			if match(&b) {
+				board = &b
+				return
+			}
+		}
+	})
+	if board == nil {
+		return nil, ErrNotFound
+	}
+	return board, nil
+}
+
+// Save ...
+func (m *memoryBoards) Save(ctx context.Context, board *Board) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for _, b := range d.boards {
+			if b.Slug == board.Slug {
+				return &database.DuplicateError{Err: errDuplicateSlug}
+			}
+		}
+		if board.DefaultName == "" {
+			board.DefaultName = DefaultPosterName
+		}
+		d.lastBoardID++
+		board.ID = d.lastBoardID
+		d.boards = append(d.boards, *board)
+		return nil
+	})
+}
+
+type memoryThreads struct {
+	store  *MemoryStore
+	locked bool
+}
+
+// FindByBoard ...
+func (m *memoryThreads) FindByBoard(ctx context.Context, boardID int) ([]*Thread, error) {
+	threads := []*Thread{}
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, t := range d.threads {
+			if t.BoardID == boardID && t.ArchivedAt == "" {
+				threads = append(threads, d.withStats(t))
+			}
+		}
+	})
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].BumpedAt != threads[j].BumpedAt {
+			return threads[i].BumpedAt > threads[j].BumpedAt
+		}
+		return threads[i].ID > threads[j].ID
+	})
+	return threads, nil
+}
+
+// FindArchived ...
+func (m *memoryThreads) FindArchived(ctx context.Context, boardID int, q ArchiveQuery) ([]*Thread, error) {
+	threads := []*Thread{}
+	subject := strings.ToLower(q.Subject)
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, t := range d.threads {
+			switch {
+			case t.BoardID != boardID || t.ArchivedAt == "":
+			case !strings.Contains(strings.ToLower(t.Subject), subject):
+			case q.Since != "" && t.CreatedAt < q.Since:
+			case q.Until != "" && t.CreatedAt >= q.Until:
+			default:
+				threads = append(threads, d.withStats(t))
+			}
+		}
+	})
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].CreatedAt != threads[j].CreatedAt {
+			return threads[i].CreatedAt > threads[j].CreatedAt
+		}
+		return threads[i].ID > threads[j].ID
+	})
+	if len(threads) > maxArchiveResults {
+		threads = threads[:maxArchiveResults]
+	}
+	return threads, nil
+}
+
+// Find ...
+func (m *memoryThreads) Find(ctx context.Context, id int) (*Thread, error) {
+	var thread *Thread
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, t := range d.threads {
+			if t.ID == id {
+				thread = d.withStats(t)
+				return
+			}
+		}
+	})
+	if thread == nil {
+		return nil, ErrNotFound
+	}
+	return thread, nil
+}
+
+// LatestOpenThread ...
+func (m *memoryThreads) LatestOpenThread(ctx context.Context, boardID int) (*Thread, error) {
+	var thread *Thread
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, t := range d.threads {
+			if t.BoardID == boardID && t.Open() && (thread == nil || t.ID > thread.ID) {
+				thread = d.withStats(t)
+			}
+		}
+	})
+	if thread == nil {
+		return nil, ErrNotFound
+	}
+	return thread, nil
+}
+
+// Save ...
+func (m *memoryThreads) Save(ctx context.Context, thread *Thread) error {
+	if thread.BumpedAt == "" {
+		thread.BumpedAt = thread.CreatedAt
+	}
+	return m.store.write(m.locked, func(d *memoryData) error {
+		d.lastThreadID++
+		thread.ID = d.lastThreadID
+		d.threads = append(d.threads, Thread{
+			ID:        thread.ID,
+			BoardID:   thread.BoardID,
+			Subject:   thread.Subject,
+			CreatedAt: thread.CreatedAt,
+			BumpedAt:  thread.BumpedAt,
+		})
+		return nil
+	})
+}
+
+// Bump ...
+func (m *memoryThreads) Bump(ctx context.Context, id int, bumpedAt string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.threads {
+			if d.threads[i].ID == id {
+				d.threads[i].BumpedAt = bumpedAt
+			}
+		}
+		return nil
+	})
+}
+
+// NextPostNo ...
+func (m *memoryThreads) NextPostNo(ctx context.Context, id int) (int, error) {
+	postNo := 0
+	err := m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.threads {
+			if d.threads[i].ID == id {
+				if !d.threads[i].Open() {
+					return ErrThreadClosed
+				}
+				d.lastPostNos[id]++
+				postNo = d.lastPostNos[id]
+				return nil
+			}
+		}
+		return ErrThreadClosed
+	})
+	return postNo, err
+}
+
+// Close ...
+func (m *memoryThreads) Close(ctx context.Context, id int, closedAt string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.threads {
+			if d.threads[i].ID == id && d.threads[i].ClosedAt == "" {
+				d.threads[i].ClosedAt = closedAt
+			}
+		}
+		return nil
+	})
+}
+
+// Archive ...
+func (m *memoryThreads) Archive(ctx context.Context, inactiveSince, archivedAt string) (int, error) {
+	archived := 0
+	err := m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.threads {
+			t := &d.threads[i]
+			if t.ArchivedAt != "" || t.CreatedAt >= inactiveSince || d.withStats(*t).LastPostedAt >= inactiveSince {
+				continue
+			}
+			t.ArchivedAt = archivedAt
+			archived++
+		}
+		return nil
+	})
+	return archived, err
+}
+
+// withStats returns a copy of t with the statistics of its messages.
+func (d *memoryData) withStats(t Thread) *Thread {
+	t.PostCount, t.LastPostedAt = 0, t.CreatedAt
+	for _, msg := range d.messages {
+		if msg.ThreadID == t.ID {
+			t.PostCount++
+			t.LastPostedAt = msg.CreatedAt
+		}
+	}
+	return &t
+}
+
+type memoryMessages struct {
+	store  *MemoryStore
+	locked bool
+}
+
+// FindPage ...
+func (m *memoryMessages) FindPage(ctx context.Context, q PageQuery) (*Page, error) {
+	all := m.all()
+	msgs := []*Message{}
+	switch {
+	case q.After != 0:
+		for _, msg := range all {
+			if msg.ID > q.After && len(msgs) <= q.limit() {
+				msgs = append(msgs, msg)
+			}
+		}
+	default:
+		for i := len(all) - 1; i >= 0 && len(msgs) <= q.limit(); i-- {
+			if q.Before == 0 || all[i].ID < q.Before {
+				msgs = append(msgs, all[i])
+			}
+		}
+	}
+	return newPage(q, msgs), nil
+}
+
+// all returns every message whose user exists in the order they were saved.
+func (m *memoryMessages) all() []*Message {
+	messages := []*Message{}
+	m.store.read(m.locked, func(d *memoryData) {
+		names := make(map[int]string, len(d.users))
+		for _, u := range d.users {
+			names[u.ID] = u.Name
+		}
+		slugs := make(map[int]string, len(d.boards))
+		for _, b := range d.boards {
+			slugs[b.ID] = b.Slug
+		}
+		for _, msg := range d.messages {
+			name, ok := names[msg.UserID]
+			if !ok && msg.UserID != 0 {
+				continue
+			}
+			msg := msg
+			msg.UserName, msg.BoardSlug = name, slugs[msg.BoardID]
+			messages = append(messages, &msg)
+		}
+	})
+	return messages
+}
+
+// FindByBoard ...
+func (m *memoryMessages) FindByBoard(ctx context.Context, boardID int) ([]*Message, error) {
+	messages := []*Message{}
+	for _, msg := range m.all() {
+		if msg.BoardID == boardID {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+// FindByThread ...
+func (m *memoryMessages) FindByThread(ctx context.Context, threadID int) ([]*Message, error) {
+	messages := []*Message{}
+	for _, msg := range m.all() {
+		if msg.ThreadID == threadID {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+// Save ...
+func (m *memoryMessages) Save(ctx context.Context, msg *Message) error {
+	if msg.BoardID == 0 {
+		msg.BoardID = DefaultBoardID
+	}
+	return m.store.write(m.locked, func(d *memoryData) error {
+		d.lastMessageID++
+		msg.ID = d.lastMessageID
+		d.messages = append(d.messages, Message{
+			ID:        msg.ID,
+			BoardID:   msg.BoardID,
+			ThreadID:  msg.ThreadID,
+			PostNo:    msg.PostNo,
+			UserID:    msg.UserID,
+			Name:      msg.Name,
+			Tripcode:  msg.Tripcode,
+			PosterID:  msg.PosterID,
+			IPHash:    msg.IPHash,
+			Sage:      msg.Sage,
+			Message:   msg.Message,
+			CreatedAt: msg.CreatedAt,
+		})
+		return nil
+	})
+}
+
+type memoryUsers struct {
+	store  *MemoryStore
+	locked bool
+}
+
+// errDuplicateEmail, errDuplicateSlug and errDuplicateSession mirror the
+// unique constraints on users.email, boards.slug and sessions.id.
+var (
+	errDuplicateEmail   = errors.New("model: duplicate email")
+	errDuplicateSlug    = errors.New("model: duplicate slug")
+	errDuplicateSession = errors.New("model: duplicate session")
+)
+
+// FindByEmail ...
+func (m *memoryUsers) FindByEmail(ctx context.Context, email string) (*User, error) {
+	return m.find(func(u *User) bool { return u.Email == email })
+}
+
+// Find ...
+func (m *memoryUsers) Find(ctx context.Context, id int) (*User, error) {
+	return m.find(func(u *User) bool { return u.ID == id })
+}
+
+func (m *memoryUsers) find(match func(*User) bool) (*User, error) {
+	var user *User
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, u := range d.users {
+			if match(&u) {
+				u := u
+				user = &u
+				return
+			}
+		}
+	})
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// FindAll ...
+func (m *memoryUsers) FindAll(ctx context.Context) ([]*User, error) {
+	users := []*User{}
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, u := range d.users {
+			users = append(users, &User{ID: u.ID, Name: u.Name, Email: u.Email, Role: u.Role})
+		}
+	})
+	return users, nil
+}
+
+// Save ...
+func (m *memoryUsers) Save(ctx context.Context, user *User) error {
+	if user.Role == "" {
+		user.Role = RoleMember
+	}
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for _, u := range d.users {
+			if u.Email == user.Email {
+				return &database.DuplicateError{Err: errDuplicateEmail}
+			}
+		}
+		d.lastUserID++
+		user.ID = d.lastUserID
+		d.users = append(d.users, *user)
+		return nil
+	})
+}
+
+// UpdatePassword ...
+func (m *memoryUsers) UpdatePassword(ctx context.Context, id int, hash string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.users {
+			if d.users[i].ID == id {
+				d.users[i].Password = hash
+			}
+		}
+		return nil
+	})
+}
+
+// VerifyEmail ...
+func (m *memoryUsers) VerifyEmail(ctx context.Context, id int, verifiedAt string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.users {
+			if d.users[i].ID == id {
+				d.users[i].EmailVerifiedAt = verifiedAt
+			}
+		}
+		return nil
+	})
+}
+
+// SetTOTPSecret ...
+func (m *memoryUsers) SetTOTPSecret(ctx context.Context, id int, secret string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.users {
+			if d.users[i].ID == id {
+				d.users[i].TOTPSecret = secret
+				d.users[i].TOTPLastStep = 0
+			}
+		}
+		return nil
+	})
+}
+
+// UseTOTPStep ...
+func (m *memoryUsers) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	used := false
+	err := m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.users {
+			if u := &d.users[i]; u.ID == id && u.TOTPLastStep < step {
+				u.TOTPLastStep = step
+				used = true
+			}
+		}
+		return nil
+	})
+	return used, err
+}
+
+// Exists ...
+func (m *memoryUsers) Exists(ctx context.Context, user *User) bool {
+	exists := false
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, u := range d.users {
+			if u.Email == user.Email {
+				exists = true
+				return
+			}
+		}
+	})
+	return exists
+}
+
+type memorySessions struct {
+	store  *MemoryStore
+	locked bool
+}
+
+// Find ...
+func (m *memorySessions) Find(ctx context.Context, id string) (*Session, error) {
+	var session *Session
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, s := range d.sessions {
+			if s.ID == id {
+				session = d.withUser(s)
+				return
+			}
+		}
+	})
+	if session == nil {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+// FindByUser ...
+func (m *memorySessions) FindByUser(ctx context.Context, userID int, now string) ([]*Session, error) {
+	sessions := []*Session{}
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, s := range d.sessions {
+			if s.UserID == userID && s.ExpiresAt > now {
+				if session := d.withUser(s); session != nil {
+					sessions = append(sessions, session)
+				}
+			}
+		}
+	})
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].LastSeenAt != sessions[j].LastSeenAt {
+			return sessions[i].LastSeenAt > sessions[j].LastSeenAt
+		}
+		return sessions[i].CreatedAt > sessions[j].CreatedAt
+	})
+	return sessions, nil
+}
+
+// withUser returns a copy of s with the name and role of its user, or nil
+// when the user does not exist, like the join of SessionModel.
+func (d *memoryData) withUser(s Session) *Session {
+	for _, u := range d.users {
+		if u.ID == s.UserID {
+			s.UserName, s.UserRole, s.UserEmailVerified = u.Name, u.Role, u.EmailVerified()
+			return &s
+		}
+	}
+	return nil
+}
+
+// Save ...
+func (m *memorySessions) Save(ctx context.Context, session *Session) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for _, s := range d.sessions {
+			if s.ID == session.ID {
+				return &database.DuplicateError{Err: errDuplicateSession}
+			}
+		}
+		s := *session
+		s.UserName, s.UserRole, s.UserEmailVerified = "", "", false
+		d.sessions = append(d.sessions, s)
+		return nil
+	})
+}
+
+// Touch ...
+func (m *memorySessions) Touch(ctx context.Context, id, lastSeenAt, ip, userAgent string) error {
+	return m.update(id, func(s *Session) {
+		s.LastSeenAt, s.IP, s.UserAgent = lastSeenAt, ip, userAgent
+	})
+}
+
+// UpdateData ...
+func (m *memorySessions) UpdateData(ctx context.Context, id, data string) error {
+	return m.update(id, func(s *Session) {
+		s.Data = data
+	})
+}
+
+func (m *memorySessions) update(id string, fn func(*Session)) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.sessions {
+			if d.sessions[i].ID == id {
+				fn(&d.sessions[i])
+			}
+		}
+		return nil
+	})
+}
+
+// Delete ...
+func (m *memorySessions) Delete(ctx context.Context, userID int, id string) error {
+	return m.deleteWhere(func(s *Session) bool { return s.ID == id && s.UserID == userID })
+}
+
+// DeleteByUserID ...
+func (m *memorySessions) DeleteByUserID(ctx context.Context, userID int) error {
+	return m.deleteWhere(func(s *Session) bool { return s.UserID == userID })
+}
+
+// DeleteExpired ...
+func (m *memorySessions) DeleteExpired(ctx context.Context, now string) (int, error) {
+	n := 0
+	err := m.deleteWhere(func(s *Session) bool {
+		if s.ExpiresAt <= now {
+			n++
+			return true
+		}
+		return false
+	})
+	return n, err
+}
+
+func (m *memorySessions) deleteWhere(match func(*Session) bool) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		sessions := d.sessions[:0]
+		for i := range d.sessions {
+			if !match(&d.sessions[i]) {
+				sessions = append(sessions, d.sessions[i])
+			}
+		}
+		d.sessions = sessions
+		return nil
+	})
+}
+
+type memoryLoginAttempts struct {
+	store  *MemoryStore
+	locked bool
+}
+
+// Find ...
+func (m *memoryLoginAttempts) Find(ctx context.Context, id string) (*LoginAttempt, error) {
+	var attempt *LoginAttempt
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, a := range d.loginAttempts {
+			if a.ID == id {
+				a := a
+				attempt = &a
+				return
+			}
+		}
+	})
+	if attempt == nil {
+		return nil, ErrNotFound
+	}
+	return attempt, nil
+}
+
+// Fail ...
+func (m *memoryLoginAttempts) Fail(ctx context.Context, id, now, resetBefore string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.loginAttempts {
+			if a := &d.loginAttempts[i]; a.ID == id {
+				if a.LastFailedAt < resetBefore {
+					a.Failures = 0
+				}
+				a.Failures++
+				a.LastFailedAt = now
+				return nil
+			}
+		}
+		d.loginAttempts = append(d.loginAttempts, LoginAttempt{ID: id, Failures: 1, LastFailedAt: now})
+		return nil
+	})
+}
+
+// Lock ...
+func (m *memoryLoginAttempts) Lock(ctx context.Context, id, until string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		for i := range d.loginAttempts {
+			if d.loginAttempts[i].ID == id {
+				d.loginAttempts[i].LockedUntil = until
+			}
+		}
+		return nil
+	})
+}
+
+// Reset ...
+func (m *memoryLoginAttempts) Reset(ctx context.Context, id string) error {
+	_, err := m.deleteWhere(func(a *LoginAttempt) bool { return a.ID == id })
+	return err
+}
+
+// DeleteStale ...
+func (m *memoryLoginAttempts) DeleteStale(ctx context.Context, before, now string) (int, error) {
+	return m.deleteWhere(func(a *LoginAttempt) bool {
+		return a.LastFailedAt < before && (a.LockedUntil == "" || a.LockedUntil <= now)
+	})
+}
+
+func (m *memoryLoginAttempts) deleteWhere(match func(*LoginAttempt) bool) (int, error) {
+	n := 0
+	err := m.store.write(m.locked, func(d *memoryData) error {
+		attempts := d.loginAttempts[:0]
+		for i := range d.loginAttempts {
+			if match(&d.loginAttempts[i]) {
+				n++
+				continue
+			}
+			attempts = append(attempts, d.loginAttempts[i])
+		}
+		d.loginAttempts = attempts
+		return nil
+	})
+	return n, err
+}
+
+type memoryRecoveryCodes struct {
+	store  *MemoryStore
+	locked bool
+}
+
+// Replace ...
+func (m *memoryRecoveryCodes) Replace(ctx context.Context, userID int, hashes []string) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		d.recoveryCodes = deleteRecoveryCodes(d.recoveryCodes, func(c *RecoveryCode) bool { return c.UserID == userID })
+		for _, hash := range hashes {
+			d.lastCodeID++
+			d.recoveryCodes = append(d.recoveryCodes, RecoveryCode{ID: d.lastCodeID, UserID: userID, CodeHash: hash})
+		}
+		return nil
+	})
+}
+
+// Use ...
+func (m *memoryRecoveryCodes) Use(ctx context.Context, userID int, hash string) (bool, error) {
+	used := false
+	err := m.store.write(m.locked, func(d *memoryData) error {
+		d.recoveryCodes = deleteRecoveryCodes(d.recoveryCodes, func(c *RecoveryCode) bool {
+			if c.UserID == userID && c.CodeHash == hash {
+				used = true
+				return true
+			}
+			return false
+		})
+		return nil
+	})
+	return used, err
+}
+
+// Count ...
+func (m *memoryRecoveryCodes) Count(ctx context.Context, userID int) (int, error) {
+	count := 0
+	m.store.read(m.locked, func(d *memoryData) {
+		for _, c := range d.recoveryCodes {
+			if c.UserID == userID {
+				count++
+			}
+		}
+	})
+	return count, nil
+}
+
+// DeleteByUserID ...
+func (m *memoryRecoveryCodes) DeleteByUserID(ctx context.Context, userID int) error {
+	return m.store.write(m.locked, func(d *memoryData) error {
+		d.recoveryCodes = deleteRecoveryCodes(d.recoveryCodes, func(c *RecoveryCode) bool { return c.UserID == userID })
+		return nil
+	})
+}
+
+// deleteRecoveryCodes returns codes without the ones which match, reusing its
+// array.
+func deleteRecoveryCodes(codes []RecoveryCode, match func(*RecoveryCode) bool) []RecoveryCode {
+	kept := codes[:0]
+	for i := range codes {
+		if !match(&codes[i]) {
+			kept = append(kept, codes[i])
+		}
+	}
+	return kept
+}
+
+var (
+	_ Store                  = (*MemoryStore)(nil)
+	_ Store                  = (*memoryTxStore)(nil)
+	_ BoardRepository        = (*memoryBoards)(nil)
+	_ ThreadRepository       = (*memoryThreads)(nil)
+	_ MessageRepository      = (*memoryMessages)(nil)
+	_ UserRepository         = (*memoryUsers)(nil)
+	_ SessionRepository      = (*memorySessions)(nil)
+	_ LoginAttemptRepository = (*memoryLoginAttempts)(nil)
+	_ RecoveryCodeRepository = (*memoryRecoveryCodes)(nil)
+)
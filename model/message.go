@@ -1,21 +1,44 @@
 package model
 
 import (
+	"context"
+
 	"github.com/seka/bbs-sample/database"
 )
 
 // Message ...
 type Message struct {
-	ID        int
+	ID       int
+	BoardID  int
+	ThreadID int
+	// PostNo numbers the messages of a thread from 1 and is 0 for messages
+	// without a thread.
+	PostNo int
+	// UserID is 0 for system notices and for guests.
 	UserID    int
 	UserName  string
-	Message   string
+	BoardSlug string
+	// Name is the name given on an anonymous board, or empty when the
+	// account name is shown.
+	Name     string
+	Tripcode string
+	// PosterID identifies the poster within the thread for the day.
+	PosterID string
+	// IPHash is a keyed hash of the poster's address, which lets moderators
+	// link posts without storing the address.
+	IPHash  string
+	Message string
+	// Sage is set on replies which did not bump their thread.
+	Sage bool
+	// PosterKey is the secret the PosterID is made from, which is not
+	// stored. Messages without one get no PosterID.
+	PosterKey []byte
 	CreatedAt string
 }
 
 // MessageModel ...
 type MessageModel struct {
-	db database.Database
+	db database.Querier
 }
 
 // NewMessageModel ....
@@ -25,22 +48,128 @@ func NewMessageModel(db database.Database) *MessageModel {
 	}
 }
 
-// FindAll ...
-func (m *MessageModel) FindAll() ([]*Message, error) {
+// WithTx returns a MessageModel which runs its queries in tx.
+func (m *MessageModel) WithTx(tx database.Tx) *MessageModel {
+	return &MessageModel{
+		db: tx,
+	}
+}
+
+const (
+	// DefaultPageSize is the number of messages on a page without a limit.
+	DefaultPageSize = 50
+	// MaxPageSize is the most messages a page may have.
+	MaxPageSize = 200
+)
+
+// PageQuery selects a page of messages by their IDs: the messages before
+// Before, the messages after After, or the newest ones when both are 0.
+type PageQuery struct {
+	Before int
+	After  int
+	// Limit is DefaultPageSize when 0 and at most MaxPageSize.
+	Limit int
+}
+
+// limit returns the number of messages on the page.
+func (q PageQuery) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultPageSize
+	case q.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return q.Limit
+}
+
+// Page is a page of messages in the order they were posted. HasOlder and
+// HasNewer tell whether there are messages before and after the page.
+type Page struct {
+	Messages []*Message
+	HasOlder bool
+	HasNewer bool
+}
+
+// newPage returns the page of msgs, which were fetched one past the limit of
+// q away from its cursor to learn whether there are more without a COUNT.
+func newPage(q PageQuery, msgs []*Message) *Page {
+	page := &Page{}
+	more := len(msgs) > q.limit()
+	if more {
+		msgs = msgs[:q.limit()]
+	}
+	if q.After != 0 {
+		page.HasOlder, page.HasNewer = true, more
+	} else {
+		page.HasOlder, page.HasNewer = more, q.Before != 0
+		// Pages going back in time are fetched the newest first.
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+	}
+	page.Messages = msgs
+	return page
+}
+
+// FindPage returns a page of the messages of every board.
+func (m *MessageModel) FindPage(ctx context.Context, q PageQuery) (*Page, error) {
+	ctx = database.WithQueryName(ctx, "messages.find_page")
 	query := `
-	SELECT m.message message, m.created_at created_at, u.name name
+	SELECT m.id id, m.board_id board_id, COALESCE(m.thread_id, 0) thread_id, COALESCE(m.post_no, 0) post_no, COALESCE(m.user_id, 0) user_id, m.message message, m.created_at created_at, COALESCE(u.name, '') user_name, b.slug slug, m.name name, m.tripcode tripcode, m.poster_id poster_id, m.ip_hash ip_hash, m.sage sage
 	FROM messages m
-	INNER JOIN users u ON m.user_id = u.id
+	LEFT JOIN users u ON m.user_id = u.id
+	INNER JOIN boards b ON m.board_id = b.id
 	`
-	rows, err := m.db.Query(query)
+	var args []interface{}
+	switch {
+	case q.After != 0:
+		query += `WHERE m.id > ? ORDER BY m.id LIMIT ?`
+		args = append(args, q.After)
+	case q.Before != 0:
+		query += `WHERE m.id < ? ORDER BY m.id DESC LIMIT ?`
+		args = append(args, q.Before)
+	default:
+		query += `ORDER BY m.id DESC LIMIT ?`
+	}
+	rows, err := m.db.QueryContext(ctx, query, append(args, q.limit()+1)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	messages := []*Message{}
 	for rows.Next() {
 		m := &Message{}
-		if err := rows.Scan(&m.Message, &m.CreatedAt, &m.UserName); err != nil {
-			break
+		if err := rows.Scan(&m.ID, &m.BoardID, &m.ThreadID, &m.PostNo, &m.UserID, &m.Message, (*timeText)(&m.CreatedAt), &m.UserName, &m.BoardSlug, &m.Name, &m.Tripcode, &m.PosterID, &m.IPHash, &m.Sage); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newPage(q, messages), nil
+}
+
+// FindByBoard ...
+func (m *MessageModel) FindByBoard(ctx context.Context, boardID int) ([]*Message, error) {
+	ctx = database.WithQueryName(ctx, "messages.find_by_board")
+	query := `
+	SELECT m.id id, m.board_id board_id, COALESCE(m.user_id, 0) user_id, m.message message, m.created_at created_at, COALESCE(u.name, '') user_name, m.name name, m.tripcode tripcode, m.poster_id poster_id, m.ip_hash ip_hash, m.sage sage
+	FROM messages m
+	LEFT JOIN users u ON m.user_id = u.id
+	WHERE m.board_id = ?
+	ORDER BY m.id
+	`
+	rows, err := m.db.QueryContext(ctx, query, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []*Message{}
+	for rows.Next() {
+		m := &Message{}
+		if err := rows.Scan(&m.ID, &m.BoardID, &m.UserID, &m.Message, (*timeText)(&m.CreatedAt), &m.UserName, &m.Name, &m.Tripcode, &m.PosterID, &m.IPHash, &m.Sage); err != nil {
+			return nil, err
 		}
 		messages = append(messages, m)
 	}
@@ -50,12 +179,74 @@ func (m *MessageModel) FindAll() ([]*Message, error) {
 	return messages, nil
 }
 
-// Save ...
-func (m *MessageModel) Save(msg *Message) error {
-	query := `INSERT INTO messages(user_id, message, created_at) VALUES (?, ?, ?)`
-	_, err := m.db.Execute(query, msg.UserID, msg.Message, msg.CreatedAt)
+// FindByThread returns the messages of a thread in the order they were posted.
+func (m *MessageModel) FindByThread(ctx context.Context, threadID int) ([]*Message, error) {
+	ctx = database.WithQueryName(ctx, "messages.find_by_thread")
+	query := `
+	SELECT m.id id, m.board_id board_id, m.thread_id thread_id, m.post_no post_no, COALESCE(m.user_id, 0) user_id, m.message message, m.created_at created_at, COALESCE(u.name, '') user_name, m.name name, m.tripcode tripcode, m.poster_id poster_id, m.ip_hash ip_hash, m.sage sage
+	FROM messages m
+	LEFT JOIN users u ON m.user_id = u.id
+	WHERE m.thread_id = ?
+	ORDER BY m.post_no
+	`
+	rows, err := m.db.QueryContext(ctx, query, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []*Message{}
+	for rows.Next() {
+		m := &Message{}
+		if err := rows.Scan(&m.ID, &m.BoardID, &m.ThreadID, &m.PostNo, &m.UserID, &m.Message, (*timeText)(&m.CreatedAt), &m.UserName, &m.Name, &m.Tripcode, &m.PosterID, &m.IPHash, &m.Sage); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Save saves msg on its board, or on the default board when BoardID is 0.
+func (m *MessageModel) Save(ctx context.Context, msg *Message) error {
+	ctx = database.WithQueryName(ctx, "messages.save")
+	if msg.BoardID == 0 {
+		msg.BoardID = DefaultBoardID
+	}
+	var threadID, postNo, userID interface{}
+	if msg.ThreadID != 0 {
+		threadID, postNo = msg.ThreadID, msg.PostNo
+	}
+	if msg.UserID != 0 {
+		userID = msg.UserID
+	}
+	query := `
+	INSERT INTO messages(board_id, thread_id, post_no, user_id, name, tripcode, poster_id, ip_hash, sage, message, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	id, err := m.db.InsertContext(ctx, query, msg.BoardID, threadID, postNo, userID, msg.Name, msg.Tripcode, msg.PosterID, msg.IPHash, msg.Sage, msg.Message, msg.CreatedAt)
 	if err != nil {
 		return err
 	}
+	msg.ID = int(id)
 	return nil
 }
+
+// System reports whether msg is a notice posted by the bbs itself.
+func (m *Message) System() bool {
+	return m.UserID == 0 && m.IPHash == ""
+}
+
+// Guest reports whether msg was posted by a visitor without an account.
+func (m *Message) Guest() bool {
+	return m.UserID == 0 && m.IPHash != ""
+}
+
+// DisplayName returns the name msg is shown with.
+func (m *Message) DisplayName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.UserName
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewPage(t *testing.T) {
+	// messages returns messages with ids, in the order of ids.
+	messages := func(ids ...int) []*Message {
+		msgs := make([]*Message, len(ids))
+		for i, id := range ids {
+			msgs[i] = &Message{ID: id}
+		}
+		return msgs
+	}
+	tests := []struct {
+		name         string
+		q            PageQuery
+		msgs         []*Message
+		wantIDs      []int
+		wantHasOlder bool
+		wantHasNewer bool
+	}{
+		{"empty", PageQuery{Limit: 3}, nil, []int{}, false, false},
+		{"newest", PageQuery{Limit: 3}, messages(9, 8), []int{8, 9}, false, false},
+		{"newest with older", PageQuery{Limit: 3}, messages(9, 8, 7, 6), []int{7, 8, 9}, true, false},
+		{"before", PageQuery{Before: 7, Limit: 3}, messages(6, 5, 4), []int{4, 5, 6}, false, true},
+		{"before with older", PageQuery{Before: 7, Limit: 3}, messages(6, 5, 4, 3), []int{4, 5, 6}, true, true},
+		{"after", PageQuery{After: 3, Limit: 3}, messages(4, 5), []int{4, 5}, true, false},
+		{"after with newer", PageQuery{After: 3, Limit: 3}, messages(4, 5, 6, 7), []int{4, 5, 6}, true, true},
+		{"default limit", PageQuery{}, messages(make([]int, DefaultPageSize+1)...), make([]int, DefaultPageSize), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := newPage(tt.q, tt.msgs)
+			ids := make([]int, len(page.Messages))
+			for i, msg := range page.Messages {
+				ids[i] = msg.ID
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("newPage() messages = %v, want %v", ids, tt.wantIDs)
+			}
+			if page.HasOlder != tt.wantHasOlder || page.HasNewer != tt.wantHasNewer {
+				t.Errorf("newPage() HasOlder, HasNewer = %v, %v, want %v, %v", page.HasOlder, page.HasNewer, tt.wantHasOlder, tt.wantHasNewer)
+			}
+		})
+	}
+}
+
+func TestPageQueryLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, DefaultPageSize},
+		{-1, DefaultPageSize},
+		{10, 10},
+		{MaxPageSize, MaxPageSize},
+		{MaxPageSize + 1, MaxPageSize},
+	}
+	for _, tt := range tests {
+		if got := (PageQuery{Limit: tt.limit}).limit(); got != tt.want {
+			t.Errorf("PageQuery{Limit: %d}.limit() = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFindPage(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var ids []int
+			for i := 0; i < 5; i++ {
+				msg := &Message{Message: "hello", CreatedAt: "2024-01-01 00:00:00"}
+				if err := store.Messages().Save(ctx, msg); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, msg.ID)
+			}
+			tests := []struct {
+				name         string
+				q            PageQuery
+				wantIDs      []int
+				wantHasOlder bool
+				wantHasNewer bool
+			}{
+				{"newest", PageQuery{Limit: 2}, ids[3:], true, false},
+				{"before", PageQuery{Before: ids[3], Limit: 2}, ids[1:3], true, true},
+				{"oldest", PageQuery{Before: ids[1], Limit: 2}, ids[:1], false, true},
+				{"after", PageQuery{After: ids[0], Limit: 2}, ids[1:3], true, true},
+				{"newer", PageQuery{After: ids[2], Limit: 2}, ids[3:], true, false},
+			}
+			for _, tt := range tests {
+				page, err := store.Messages().FindPage(ctx, tt.q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := make([]int, len(page.Messages))
+				for i, msg := range page.Messages {
+					got[i] = msg.ID
+				}
+				if !reflect.DeepEqual(got, tt.wantIDs) || page.HasOlder != tt.wantHasOlder || page.HasNewer != tt.wantHasNewer {
+					t.Errorf("%s: FindPage() = %v older %v newer %v, want %v older %v newer %v", tt.name, got, page.HasOlder, page.HasNewer, tt.wantIDs, tt.wantHasOlder, tt.wantHasNewer)
+				}
+			}
+		})
+	}
+}
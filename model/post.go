@@ -0,0 +1,87 @@
+package model
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+// StartThread saves thread and msg as its opening post.
+func StartThread(ctx context.Context, store Store, thread *Thread, msg *Message) error {
+	return store.WithTx(ctx, func(store Store) error {
+		if thread.CreatedAt == "" {
+			thread.CreatedAt = msg.CreatedAt
+		}
+		if err := store.Threads().Save(ctx, thread); err != nil {
+			return err
+		}
+		msg.BoardID, msg.ThreadID = thread.BoardID, thread.ID
+		return savePost(ctx, store, msg)
+	})
+}
+
+// Reply saves msg in the thread msg.ThreadID and bumps the thread unless
+// sage is set, which is saved with msg. The thread is closed with a system notice when msg reaches the
+// maximum posts of its board. Closed threads return ErrThreadClosed.
+func Reply(ctx context.Context, store Store, msg *Message, sage bool) error {
+	return store.WithTx(ctx, func(store Store) error {
+		thread, err := store.Threads().Find(ctx, msg.ThreadID)
+		if err != nil {
+			return err
+		}
+		board, err := store.Boards().FindByID(ctx, thread.BoardID)
+		if err != nil {
+			return err
+		}
+		msg.BoardID, msg.Sage = thread.BoardID, sage
+		if err := savePost(ctx, store, msg); err != nil {
+			return err
+		}
+		if board.MaxPostsPerThread > 0 && msg.PostNo >= board.MaxPostsPerThread {
+			if err := closeThread(ctx, store, thread, board.MaxPostsPerThread, msg.CreatedAt); err != nil {
+				return err
+			}
+		}
+		if sage {
+			return nil
+		}
+		return store.Threads().Bump(ctx, thread.ID, msg.CreatedAt)
+	})
+}
+
+// closeThread closes a thread which has reached maxPosts with a system notice.
+func closeThread(ctx context.Context, store Store, thread *Thread, maxPosts int, closedAt string) error {
+	notice := &Message{
+		BoardID:   thread.BoardID,
+		ThreadID:  thread.ID,
+		Message:   fmt.Sprintf("This thread has reached %d posts and is closed. Please start a new thread.", maxPosts),
+		CreatedAt: closedAt,
+	}
+	if err := savePost(ctx, store, notice); err != nil {
+		return err
+	}
+	return store.Threads().Close(ctx, thread.ID, closedAt)
+}
+
+// savePost numbers msg within its thread, gives it a poster ID and saves it.
+func savePost(ctx context.Context, store Store, msg *Message) error {
+	postNo, err := store.Threads().NextPostNo(ctx, msg.ThreadID)
+	if err != nil {
+		return err
+	}
+	msg.PostNo = postNo
+	if msg.PosterKey != nil {
+		msg.PosterID = posterID(msg.PosterKey, msg.ThreadID)
+	}
+	return store.Messages().Save(ctx, msg)
+}
+
+// posterID returns the 8 character ID of the poster with key in a thread.
+func posterID(key []byte, threadID int) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.Itoa(threadID)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))[:8]
+}
@@ -0,0 +1,320 @@
+package model
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/database/migration"
+)
+
+// testStores returns the stores the model tests run against, none of which
+// needs a database server.
+func testStores(t *testing.T) map[string]Store {
+	db := database.NewSQLite(database.Options{Path: filepath.Join(t.TempDir(), "bbs.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	m, err := migration.New(db, database.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": NewSQLStore(db),
+	}
+}
+
+// saveTestUser saves a member to post with.
+func saveTestUser(t *testing.T, store Store) *User {
+	user := &User{Name: "alice", Email: "alice@example.com", Password: "hash"}
+	if err := store.Users().Save(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestPostThread(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user := saveTestUser(t, store)
+			board := &Board{Slug: "test", Title: "Test", PostPolicy: PostPolicyMembers}
+			if err := store.Boards().Save(ctx, board); err != nil {
+				t.Fatal(err)
+			}
+			threads := map[string]*Thread{}
+			for _, start := range []struct{ subject, createdAt string }{
+				{"first", "2024-01-01 00:00:00"},
+				{"second", "2024-01-01 00:01:00"},
+			} {
+				threads[start.subject] = &Thread{BoardID: board.ID, Subject: start.subject}
+				msg := &Message{UserID: user.ID, Message: start.subject, CreatedAt: start.createdAt}
+				if err := StartThread(ctx, store, threads[start.subject], msg); err != nil {
+					t.Fatal(err)
+				}
+				if msg.PostNo != 1 {
+					t.Errorf("StartThread() post number = %d, want 1", msg.PostNo)
+				}
+			}
+
+			steps := []struct {
+				name       string
+				thread     string
+				sage       bool
+				createdAt  string
+				wantPostNo int
+				want       []string
+			}{
+				{"bump", "first", false, "2024-01-01 00:10:00", 2, []string{"first", "second"}},
+				{"sage", "second", true, "2024-01-01 00:11:00", 2, []string{"first", "second"}},
+				{"bump again", "second", false, "2024-01-01 00:12:00", 3, []string{"second", "first"}},
+			}
+			for _, step := range steps {
+				msg := &Message{UserID: user.ID, ThreadID: threads[step.thread].ID, Message: step.name, CreatedAt: step.createdAt}
+				if err := Reply(ctx, store, msg, step.sage); err != nil {
+					t.Fatalf("%s: Reply() error = %v", step.name, err)
+				}
+				if msg.BoardID != board.ID || msg.PostNo != step.wantPostNo {
+					t.Errorf("%s: reply board, post number = %d, %d, want %d, %d", step.name, msg.BoardID, msg.PostNo, board.ID, step.wantPostNo)
+				}
+				got, err := store.Threads().FindByBoard(ctx, board.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var subjects []string
+				for _, thread := range got {
+					subjects = append(subjects, thread.Subject)
+				}
+				if !reflect.DeepEqual(subjects, step.want) {
+					t.Errorf("%s: FindByBoard() = %v, want %v", step.name, subjects, step.want)
+				}
+			}
+
+			msgs, err := store.Messages().FindByThread(ctx, threads["second"].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for _, msg := range msgs {
+				if msg.Sage != (msg.Message == "sage") {
+					t.Errorf("FindByThread() message %q sage = %v", msg.Message, msg.Sage)
+				}
+				texts = append(texts, msg.Message)
+			}
+			if want := []string{"second", "sage", "bump again"}; !reflect.DeepEqual(texts, want) {
+				t.Errorf("FindByThread() = %v, want %v", texts, want)
+			}
+			thread, err := store.Threads().Find(ctx, threads["second"].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if thread.Replies() != 2 || thread.BumpedAt != "2024-01-01 00:12:00" {
+				t.Errorf("Find() replies = %d, bumped at %q, want 2, 2024-01-01 00:12:00", thread.Replies(), thread.BumpedAt)
+			}
+		})
+	}
+}
+
+// startTestThread saves a board with maxPosts and starts a thread on it.
+func startTestThread(t *testing.T, store Store, maxPosts int, createdAt string) (*User, *Thread) {
+	ctx := context.Background()
+	user := saveTestUser(t, store)
+	board := &Board{Slug: "test", Title: "Test", PostPolicy: PostPolicyMembers, MaxPostsPerThread: maxPosts}
+	if err := store.Boards().Save(ctx, board); err != nil {
+		t.Fatal(err)
+	}
+	thread := &Thread{BoardID: board.ID, Subject: "thread"}
+	if err := StartThread(ctx, store, thread, &Message{UserID: user.ID, Message: "opening", CreatedAt: createdAt}); err != nil {
+		t.Fatal(err)
+	}
+	return user, thread
+}
+
+func TestThreadClose(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user, thread := startTestThread(t, store, 3, "2024-01-01 00:00:00")
+			for _, text := range []string{"second", "third"} {
+				if err := Reply(ctx, store, &Message{UserID: user.ID, ThreadID: thread.ID, Message: text, CreatedAt: "2024-01-01 00:01:00"}, false); err != nil {
+					t.Fatalf("Reply(%s) error = %v", text, err)
+				}
+			}
+			got, err := store.Threads().Find(ctx, thread.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Open() || got.ClosedAt != "2024-01-01 00:01:00" {
+				t.Errorf("thread closed at %q, want closed at the third post", got.ClosedAt)
+			}
+			msgs, err := store.Messages().FindByThread(ctx, thread.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != 4 || msgs[3].PostNo != 4 || msgs[3].UserID != 0 {
+				t.Fatalf("%d messages, want the three posts and a system notice", len(msgs))
+			}
+			err = Reply(ctx, store, &Message{UserID: user.ID, ThreadID: thread.ID, Message: "fourth", CreatedAt: "2024-01-01 00:02:00"}, false)
+			if err != ErrThreadClosed {
+				t.Errorf("Reply() to a closed thread error = %v, want ErrThreadClosed", err)
+			}
+		})
+	}
+}
+
+func TestThreadArchive(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user, inactive := startTestThread(t, store, 0, "2024-01-01 00:00:00")
+			active := &Thread{BoardID: inactive.BoardID, Subject: "active"}
+			if err := StartThread(ctx, store, active, &Message{UserID: user.ID, Message: "opening", CreatedAt: "2024-01-01 00:00:00"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := Reply(ctx, store, &Message{UserID: user.ID, ThreadID: active.ID, Message: "reply", CreatedAt: "2024-02-01 00:00:00"}, false); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, want := range []int{1, 0} {
+				n, err := store.Threads().Archive(ctx, "2024-01-15 00:00:00", "2024-02-15 00:00:00")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != want {
+					t.Errorf("Archive() #%d = %d, want %d", i+1, n, want)
+				}
+			}
+			threads, err := store.Threads().FindByBoard(ctx, inactive.BoardID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(threads) != 1 || threads[0].ID != active.ID {
+				t.Errorf("FindByBoard() = %d threads, want the active one", len(threads))
+			}
+			archived, err := store.Threads().FindArchived(ctx, inactive.BoardID, ArchiveQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archived) != 1 || archived[0].ID != inactive.ID || archived[0].ArchivedAt != "2024-02-15 00:00:00" {
+				t.Errorf("FindArchived() = %d threads, want the inactive one", len(archived))
+			}
+			err = Reply(ctx, store, &Message{UserID: user.ID, ThreadID: inactive.ID, Message: "late", CreatedAt: "2024-02-16 00:00:00"}, false)
+			if err != ErrThreadClosed {
+				t.Errorf("Reply() to an archived thread error = %v, want ErrThreadClosed", err)
+			}
+		})
+	}
+}
+
+func TestLatestOpenThread(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user, first := startTestThread(t, store, 0, "2024-01-01 00:00:00")
+			other := &Board{Slug: "other", Title: "Other", PostPolicy: PostPolicyMembers}
+			if err := store.Boards().Save(ctx, other); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Threads().LatestOpenThread(ctx, other.ID); err != ErrNotFound {
+				t.Errorf("LatestOpenThread() of an empty board error = %v, want ErrNotFound", err)
+			}
+			threads := []*Thread{first}
+			for _, subject := range []string{"second", "third"} {
+				thread := &Thread{BoardID: first.BoardID, Subject: subject}
+				if err := StartThread(ctx, store, thread, &Message{UserID: user.ID, Message: subject, CreatedAt: "2024-01-01 00:01:00"}); err != nil {
+					t.Fatal(err)
+				}
+				threads = append(threads, thread)
+			}
+			// A thread started on another board is not the latest of this one.
+			if err := StartThread(ctx, store, &Thread{BoardID: other.ID, Subject: "other"}, &Message{UserID: user.ID, Message: "other", CreatedAt: "2024-01-01 00:02:00"}); err != nil {
+				t.Fatal(err)
+			}
+			// Bumping an older thread does not make it the latest.
+			if err := store.Threads().Bump(ctx, first.ID, "2024-01-01 00:03:00"); err != nil {
+				t.Fatal(err)
+			}
+
+			latest := func() int {
+				thread, err := store.Threads().LatestOpenThread(ctx, first.BoardID)
+				if err == ErrNotFound {
+					return 0
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				return thread.ID
+			}
+			if got := latest(); got != threads[2].ID {
+				t.Errorf("LatestOpenThread() = %d, want the third thread %d", got, threads[2].ID)
+			}
+			if err := store.Threads().Close(ctx, threads[2].ID, "2024-01-01 00:04:00"); err != nil {
+				t.Fatal(err)
+			}
+			if got := latest(); got != threads[1].ID {
+				t.Errorf("LatestOpenThread() after closing the third = %d, want the second %d", got, threads[1].ID)
+			}
+			if _, err := store.Threads().Archive(ctx, "2024-01-01 00:02:00", "2024-02-01 00:00:00"); err != nil {
+				t.Fatal(err)
+			}
+			if got := latest(); got != 0 {
+				t.Errorf("LatestOpenThread() after archiving every thread = %d, want ErrNotFound", got)
+			}
+		})
+	}
+}
+
+func TestGuestPost(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			board := &Board{Slug: "anon", Title: "Anonymous", PostPolicy: PostPolicyMembers, Anonymous: true, AllowGuests: true}
+			if err := store.Boards().Save(ctx, board); err != nil {
+				t.Fatal(err)
+			}
+			if !board.CanPost(RoleGuest) || board.DefaultName != DefaultPosterName {
+				t.Fatalf("board = %+v, want guests and the default name", board)
+			}
+			thread := &Thread{BoardID: board.ID, Subject: "guests"}
+			posts := []*Message{
+				{Name: "alice", Tripcode: "!abcdefghij", IPHash: "a", PosterKey: []byte("alice"), Message: "first"},
+				{IPHash: "b", PosterKey: []byte("bob"), Message: "second"},
+				{Name: "alice", IPHash: "a", PosterKey: []byte("alice"), Message: "third"},
+			}
+			for i, msg := range posts {
+				msg.CreatedAt = "2024-01-01 00:00:00"
+				var err error
+				if i == 0 {
+					err = StartThread(ctx, store, thread, msg)
+				} else {
+					msg.ThreadID = thread.ID
+					err = Reply(ctx, store, msg, false)
+				}
+				if err != nil {
+					t.Fatalf("post %d: %v", i+1, err)
+				}
+			}
+			msgs, err := store.Messages().FindByThread(ctx, thread.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != 3 {
+				t.Fatalf("%d messages, want 3", len(msgs))
+			}
+			first := msgs[0]
+			if first.UserID != 0 || first.System() || first.Name != "alice" || first.Tripcode != "!abcdefghij" || len(first.PosterID) != 8 {
+				t.Errorf("first post = %+v", first)
+			}
+			if msgs[1].PosterID == first.PosterID || msgs[2].PosterID != first.PosterID {
+				t.Errorf("poster IDs = %q, %q, %q, want the same ID for the same key", first.PosterID, msgs[1].PosterID, msgs[2].PosterID)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"context"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// RecoveryCode is a single-use code which signs in a user who lost the device
+// of its TOTP codes. Only the hash of the code is kept.
+type RecoveryCode struct {
+	ID       int
+	UserID   int
+	CodeHash string
+}
+
+// RecoveryCodeModel ...
+type RecoveryCodeModel struct {
+	db database.Querier
+}
+
+// NewRecoveryCodeModel ...
+func NewRecoveryCodeModel(db database.Database) *RecoveryCodeModel {
+	return &RecoveryCodeModel{
+		db: db,
+	}
+}
+
+// WithTx returns a RecoveryCodeModel which runs its queries in tx.
+func (r *RecoveryCodeModel) WithTx(tx database.Tx) *RecoveryCodeModel {
+	return &RecoveryCodeModel{
+		db: tx,
+	}
+}
+
+// Replace replaces the recovery codes of userID with hashes. It must run in a
+// transaction, so that the old codes stay valid when it fails.
+func (r *RecoveryCodeModel) Replace(ctx context.Context, userID int, hashes []string) error {
+	if err := r.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	ctx = database.WithQueryName(ctx, "recovery_codes.save")
+	query := `INSERT INTO recovery_codes(user_id, code_hash) VALUES (?, ?)`
+	for _, hash := range hashes {
+		if _, err := r.db.InsertContext(ctx, query, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Use deletes the recovery code of userID with hash and returns false when
+// there is none, so that each code works once.
+func (r *RecoveryCodeModel) Use(ctx context.Context, userID int, hash string) (bool, error) {
+	ctx = database.WithQueryName(ctx, "recovery_codes.use")
+	query := `DELETE FROM recovery_codes WHERE user_id=? AND code_hash=?`
+	result, err := r.db.ExecuteContext(ctx, query, userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Count returns the number of unused recovery codes of userID.
+func (r *RecoveryCodeModel) Count(ctx context.Context, userID int) (int, error) {
+	ctx = database.WithQueryName(ctx, "recovery_codes.count")
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id=?`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	count := 0
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return 0, err
+		}
+	}
+	return count, rows.Err()
+}
+
+// DeleteByUserID ...
+func (r *RecoveryCodeModel) DeleteByUserID(ctx context.Context, userID int) error {
+	ctx = database.WithQueryName(ctx, "recovery_codes.delete_by_user_id")
+	query := `DELETE FROM recovery_codes WHERE user_id=?`
+	if _, err := r.db.ExecuteContext(ctx, query, userID); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"context"
+)
+
+// MessageRepository ...
+type MessageRepository interface {
+	FindPage(ctx context.Context, q PageQuery) (*Page, error)
+	FindByBoard(ctx context.Context, boardID int) ([]*Message, error)
+	FindByThread(ctx context.Context, threadID int) ([]*Message, error)
+	Save(ctx context.Context, msg *Message) error
+}
+
+// UserRepository ...
+type UserRepository interface {
+	// FindByEmail returns the user with email, its password hash and its
+	// TOTP secret, or ErrNotFound.
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	// Find returns the user with id, its password hash and its TOTP secret,
+	// or ErrNotFound.
+	Find(ctx context.Context, id int) (*User, error)
+	FindAll(ctx context.Context) ([]*User, error)
+	Save(ctx context.Context, user *User) error
+	UpdatePassword(ctx context.Context, id int, hash string) error
+	VerifyEmail(ctx context.Context, id int, verifiedAt string) error
+	SetTOTPSecret(ctx context.Context, id int, secret string) error
+	// UseTOTPStep returns false when a TOTP code of step or a later one was
+	// used already.
+	UseTOTPStep(ctx context.Context, id int, step int64) (bool, error)
+	Exists(ctx context.Context, user *User) bool
+}
+
+// BoardRepository ...
+type BoardRepository interface {
+	FindAll(ctx context.Context) ([]*Board, error)
+	// FindBySlug returns ErrNotFound when there is no board with slug.
+	FindBySlug(ctx context.Context, slug string) (*Board, error)
+	// FindByID returns ErrNotFound when there is no board with id.
+	FindByID(ctx context.Context, id int) (*Board, error)
+	Save(ctx context.Context, board *Board) error
+}
+
+// ThreadRepository ...
+type ThreadRepository interface {
+	// FindByBoard returns the threads of a board which are not archived, the
+	// last bumped first.
+	FindByBoard(ctx context.Context, boardID int) ([]*Thread, error)
+	// FindArchived returns the archived threads of a board matching q, the
+	// newest first.
+	FindArchived(ctx context.Context, boardID int, q ArchiveQuery) ([]*Thread, error)
+	// Find returns ErrNotFound when there is no thread with id.
+	Find(ctx context.Context, id int) (*Thread, error)
+	// LatestOpenThread returns the newest open thread of a board, or
+	// ErrNotFound when it has none.
+	LatestOpenThread(ctx context.Context, boardID int) (*Thread, error)
+	Save(ctx context.Context, thread *Thread) error
+	Bump(ctx context.Context, id int, bumpedAt string) error
+	// NextPostNo reserves the next post number of an open thread and must be
+	// called in the transaction which saves the message. It returns
+	// ErrThreadClosed when the thread is closed or archived.
+	NextPostNo(ctx context.Context, id int) (int, error)
+	Close(ctx context.Context, id int, closedAt string) error
+	// Archive archives the threads without posts since inactiveSince and
+	// returns their number.
+	Archive(ctx context.Context, inactiveSince, archivedAt string) (int, error)
+}
+
+// SessionRepository ...
+type SessionRepository interface {
+	// Find returns the session with id, expired or not, or ErrNotFound.
+	Find(ctx context.Context, id string) (*Session, error)
+	// FindByUser returns the sessions of a user which expire after now, the
+	// last seen first.
+	FindByUser(ctx context.Context, userID int, now string) ([]*Session, error)
+	Save(ctx context.Context, session *Session) error
+	Touch(ctx context.Context, id, lastSeenAt, ip, userAgent string) error
+	UpdateData(ctx context.Context, id, data string) error
+	Delete(ctx context.Context, userID int, id string) error
+	DeleteByUserID(ctx context.Context, userID int) error
+	// DeleteExpired deletes the sessions which expired at or before now and
+	// returns their number.
+	DeleteExpired(ctx context.Context, now string) (int, error)
+}
+
+// LoginAttemptRepository ...
+type LoginAttemptRepository interface {
+	// Find returns ErrNotFound when id has no failures.
+	Find(ctx context.Context, id string) (*LoginAttempt, error)
+	// Fail counts a failure of id at now. The count restarts when the
+	// previous failure was before resetBefore.
+	Fail(ctx context.Context, id, now, resetBefore string) error
+	Lock(ctx context.Context, id, until string) error
+	Reset(ctx context.Context, id string) error
+	// DeleteStale deletes the attempts which last failed before before and
+	// are not locked at now, and returns their number.
+	DeleteStale(ctx context.Context, before, now string) (int, error)
+}
+
+// RecoveryCodeRepository ...
+type RecoveryCodeRepository interface {
+	// Replace replaces the recovery codes of a user with hashes.
+	Replace(ctx context.Context, userID int, hashes []string) error
+	// Use deletes the recovery code of a user with hash and returns false
+	// when there is none.
+	Use(ctx context.Context, userID int, hash string) (bool, error)
+	Count(ctx context.Context, userID int) (int, error)
+	DeleteByUserID(ctx context.Context, userID int) error
+}
+
+// Store gives access to the repositories.
+type Store interface {
+	Boards() BoardRepository
+	Threads() ThreadRepository
+	Messages() MessageRepository
+	Users() UserRepository
+	Sessions() SessionRepository
+	LoginAttempts() LoginAttemptRepository
+	RecoveryCodes() RecoveryCodeRepository
+	// WithTx runs fn with a Store whose repositories share a transaction,
+	// which is committed when fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(Store) error) error
+}
+
+var (
+	_ BoardRepository        = (*BoardModel)(nil)
+	_ ThreadRepository       = (*ThreadModel)(nil)
+	_ MessageRepository      = (*MessageModel)(nil)
+	_ UserRepository         = (*UserModel)(nil)
+	_ SessionRepository      = (*SessionModel)(nil)
+	_ LoginAttemptRepository = (*LoginAttemptModel)(nil)
+	_ RecoveryCodeRepository = (*RecoveryCodeModel)(nil)
+)
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"context"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// Session is a signed in browser of a user.
+type Session struct {
+	// ID is the SHA-256 of the token in the session cookie, in hex.
+	ID     string
+	UserID int
+	// UserName, UserRole and UserEmailVerified are read from the user.
+	UserName          string
+	UserRole          string
+	UserEmailVerified bool
+	// Data holds the other values of the session, encoded by the session store.
+	Data       string
+	UserAgent  string
+	IP         string
+	CreatedAt  string
+	LastSeenAt string
+	ExpiresAt  string
+}
+
+// SessionModel ...
+type SessionModel struct {
+	db database.Querier
+}
+
+// NewSessionModel ...
+func NewSessionModel(db database.Database) *SessionModel {
+	return &SessionModel{
+		db: db,
+	}
+}
+
+// WithTx returns a SessionModel which runs its queries in tx.
+func (s *SessionModel) WithTx(tx database.Tx) *SessionModel {
+	return &SessionModel{
+		db: tx,
+	}
+}
+
+const sessionQuery = `
+	SELECT s.id, s.user_id, u.name, u.role, u.email_verified_at, s.data, s.user_agent, s.ip, s.created_at, s.last_seen_at, s.expires_at
+	FROM sessions s
+	JOIN users u ON u.id = s.user_id
+	`
+
+// Find returns the session with id, expired or not, or ErrNotFound.
+func (s *SessionModel) Find(ctx context.Context, id string) (*Session, error) {
+	ctx = database.WithQueryName(ctx, "sessions.find")
+	rows, err := s.db.QueryContext(ctx, sessionQuery+`WHERE s.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	return scanSession(rows)
+}
+
+// FindByUser returns the sessions of a user which expire after now, the last
+// seen first.
+func (s *SessionModel) FindByUser(ctx context.Context, userID int, now string) ([]*Session, error) {
+	ctx = database.WithQueryName(ctx, "sessions.find_by_user")
+	query := sessionQuery + `WHERE s.user_id = ? AND s.expires_at > ? ORDER BY s.last_seen_at DESC, s.created_at DESC`
+	rows, err := s.db.QueryContext(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func scanSession(rows *database.Rows) (*Session, error) {
+	session := &Session{}
+	var verifiedAt string
+	err := rows.Scan(&session.ID, &session.UserID, &session.UserName, &session.UserRole, (*timeText)(&verifiedAt), &session.Data, &session.UserAgent, &session.IP,
+		(*timeText)(&session.CreatedAt), (*timeText)(&session.LastSeenAt), (*timeText)(&session.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+	session.UserEmailVerified = verifiedAt != ""
+	return session, nil
+}
+
+// Save ...
+func (s *SessionModel) Save(ctx context.Context, session *Session) error {
+	ctx = database.WithQueryName(ctx, "sessions.save")
+	query := `INSERT INTO sessions(id, user_id, data, user_agent, ip, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err := s.db.ExecuteContext(ctx, query, session.ID, session.UserID, session.Data, session.UserAgent,
+		session.IP, session.CreatedAt, session.LastSeenAt, session.ExpiresAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Touch records that the session id was last seen at lastSeenAt from ip
+// with userAgent.
+func (s *SessionModel) Touch(ctx context.Context, id, lastSeenAt, ip, userAgent string) error {
+	ctx = database.WithQueryName(ctx, "sessions.touch")
+	query := `UPDATE sessions SET last_seen_at=?, ip=?, user_agent=? WHERE id=?`
+	if _, err := s.db.ExecuteContext(ctx, query, lastSeenAt, ip, userAgent, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateData ...
+func (s *SessionModel) UpdateData(ctx context.Context, id, data string) error {
+	ctx = database.WithQueryName(ctx, "sessions.update_data")
+	query := `UPDATE sessions SET data=? WHERE id=?`
+	if _, err := s.db.ExecuteContext(ctx, query, data, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the session id of a user.
+func (s *SessionModel) Delete(ctx context.Context, userID int, id string) error {
+	ctx = database.WithQueryName(ctx, "sessions.delete")
+	query := `DELETE FROM sessions WHERE id=? AND user_id=?`
+	if _, err := s.db.ExecuteContext(ctx, query, id, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteByUserID ...
+func (s *SessionModel) DeleteByUserID(ctx context.Context, userID int) error {
+	ctx = database.WithQueryName(ctx, "sessions.delete_by_user_id")
+	query := `DELETE FROM sessions WHERE user_id=?`
+	if _, err := s.db.ExecuteContext(ctx, query, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired deletes the sessions which expired at or before now and
+// returns their number.
+func (s *SessionModel) DeleteExpired(ctx context.Context, now string) (int, error) {
+	ctx = database.WithQueryName(ctx, "sessions.delete_expired")
+	result, err := s.db.ExecuteContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
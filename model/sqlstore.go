@@ -0,0 +1,139 @@
+package model
+
+import (
+	"context"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// SQLStore is a Store backed by a database.Database.
+type SQLStore struct {
+	db       database.Database
+	boards   *BoardModel
+	threads  *ThreadModel
+	messages *MessageModel
+	users    *UserModel
+	sessions *SessionModel
+	attempts *LoginAttemptModel
+	recovery *RecoveryCodeModel
+}
+
+// NewSQLStore ...
+func NewSQLStore(db database.Database) *SQLStore {
+	return &SQLStore{
+		db:       db,
+		boards:   NewBoardModel(db),
+		threads:  NewThreadModel(db),
+		messages: NewMessageModel(db),
+		users:    NewUserModel(db),
+		sessions: NewSessionModel(db),
+		attempts: NewLoginAttemptModel(db),
+		recovery: NewRecoveryCodeModel(db),
+	}
+}
+
+// Boards ...
+func (s *SQLStore) Boards() BoardRepository {
+	return s.boards
+}
+
+// Threads ...
+func (s *SQLStore) Threads() ThreadRepository {
+	return s.threads
+}
+
+// Messages ...
+func (s *SQLStore) Messages() MessageRepository {
+	return s.messages
+}
+
+// Users ...
+func (s *SQLStore) Users() UserRepository {
+	return s.users
+}
+
+// Sessions ...
+func (s *SQLStore) Sessions() SessionRepository {
+	return s.sessions
+}
+
+// LoginAttempts ...
+func (s *SQLStore) LoginAttempts() LoginAttemptRepository {
+	return s.attempts
+}
+
+// RecoveryCodes ...
+func (s *SQLStore) RecoveryCodes() RecoveryCodeRepository {
+	return s.recovery
+}
+
+// WithTx ...
+func (s *SQLStore) WithTx(ctx context.Context, fn func(Store) error) error {
+	return s.db.WithTx(ctx, func(tx database.Tx) error {
+		return fn(&sqlTxStore{
+			boards:   s.boards.WithTx(tx),
+			threads:  s.threads.WithTx(tx),
+			messages: s.messages.WithTx(tx),
+			users:    s.users.WithTx(tx),
+			sessions: s.sessions.WithTx(tx),
+			attempts: s.attempts.WithTx(tx),
+			recovery: s.recovery.WithTx(tx),
+		})
+	})
+}
+
+// sqlTxStore is the Store passed to the function run by SQLStore.WithTx.
+type sqlTxStore struct {
+	boards   *BoardModel
+	threads  *ThreadModel
+	messages *MessageModel
+	users    *UserModel
+	sessions *SessionModel
+	attempts *LoginAttemptModel
+	recovery *RecoveryCodeModel
+}
+
+// Boards ...
+func (s *sqlTxStore) Boards() BoardRepository {
+	return s.boards
+}
+
+// Threads ...
+func (s *sqlTxStore) Threads() ThreadRepository {
+	return s.threads
+}
+
+// Messages ...
+func (s *sqlTxStore) Messages() MessageRepository {
+	return s.messages
+}
+
+// Users ...
+func (s *sqlTxStore) Users() UserRepository {
+	return s.users
+}
+
+// Sessions ...
+func (s *sqlTxStore) Sessions() SessionRepository {
+	return s.sessions
+}
+
+// LoginAttempts ...
+func (s *sqlTxStore) LoginAttempts() LoginAttemptRepository {
+	return s.attempts
+}
+
+// RecoveryCodes ...
+func (s *sqlTxStore) RecoveryCodes() RecoveryCodeRepository {
+	return s.recovery
+}
+
+// WithTx runs fn in the current transaction.
+func (s *sqlTxStore) WithTx(ctx context.Context, fn func(Store) error) error {
+	return fn(s)
+}
+
+var (
+	_ Store = (*SQLStore)(nil)
+	_ Store = (*sqlTxStore)(nil)
+)
@@ -0,0 +1,272 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// ErrThreadClosed is returned when posting to a closed or archived thread.
+var ErrThreadClosed = errors.New("model: thread is closed")
+
+// maxArchiveResults is the number of threads returned by FindArchived.
+const maxArchiveResults = 100
+
+// Thread is a topic on a board. Its first message is the opening post.
+type Thread struct {
+	ID        int
+	BoardID   int
+	Subject   string
+	CreatedAt string
+	BumpedAt  string
+	// ClosedAt and ArchivedAt are empty while the thread is open.
+	ClosedAt   string
+	ArchivedAt string
+
+	// PostCount and LastPostedAt are computed from the messages.
+	PostCount    int
+	LastPostedAt string
+}
+
+// Replies returns the number of messages after the opening post.
+func (t *Thread) Replies() int {
+	if t.PostCount == 0 {
+		return 0
+	}
+	return t.PostCount - 1
+}
+
+// Open reports whether t accepts replies.
+func (t *Thread) Open() bool {
+	return t.ClosedAt == "" && t.ArchivedAt == ""
+}
+
+// ArchiveQuery selects archived threads. Empty fields match every thread.
+type ArchiveQuery struct {
+	// Subject is matched case insensitively against a part of the subject.
+	Subject string
+	// Since and Until bound the creation time, in TimeFormat.
+	Since string
+	Until string
+}
+
+// ThreadModel ...
+type ThreadModel struct {
+	db database.Querier
+}
+
+// NewThreadModel ...
+func NewThreadModel(db database.Database) *ThreadModel {
+	return &ThreadModel{
+		db: db,
+	}
+}
+
+// WithTx returns a ThreadModel which runs its queries in tx.
+func (t *ThreadModel) WithTx(tx database.Tx) *ThreadModel {
+	return &ThreadModel{
+		db: tx,
+	}
+}
+
+const threadQuery = `
+	SELECT t.id, t.board_id, t.subject, t.created_at, t.bumped_at, t.closed_at, t.archived_at, COUNT(m.id), COALESCE(MAX(m.created_at), t.created_at)
+	FROM threads t
+	LEFT JOIN messages m ON m.thread_id = t.id
+	`
+
+const threadGroupBy = `GROUP BY t.id, t.board_id, t.subject, t.created_at, t.bumped_at, t.closed_at, t.archived_at`
+
+// FindByBoard returns the threads of a board which are not archived, the last
+// bumped first.
+func (t *ThreadModel) FindByBoard(ctx context.Context, boardID int) ([]*Thread, error) {
+	ctx = database.WithQueryName(ctx, "threads.find_by_board")
+	query := threadQuery + `WHERE t.board_id = ? AND t.archived_at IS NULL ` + threadGroupBy + ` ORDER BY t.bumped_at DESC, t.id DESC`
+	return t.findAll(ctx, query, boardID)
+}
+
+// FindArchived returns the archived threads of a board matching q, the
+// newest first.
+func (t *ThreadModel) FindArchived(ctx context.Context, boardID int, q ArchiveQuery) ([]*Thread, error) {
+	ctx = database.WithQueryName(ctx, "threads.find_archived")
+	where := []string{`t.board_id = ?`, `t.archived_at IS NOT NULL`}
+	args := []interface{}{boardID}
+	if q.Subject != "" {
+		where = append(where, `LOWER(t.subject) LIKE ? ESCAPE '!'`)
+		args = append(args, "%"+escapeLike(strings.ToLower(q.Subject))+"%")
+	}
+	if q.Since != "" {
+		where = append(where, `t.created_at >= ?`)
+		args = append(args, q.Since)
+	}
+	if q.Until != "" {
+		where = append(where, `t.created_at < ?`)
+		args = append(args, q.Until)
+	}
+	query := threadQuery + `WHERE ` + strings.Join(where, ` AND `) + ` ` + threadGroupBy + ` ORDER BY t.created_at DESC, t.id DESC LIMIT ?`
+	return t.findAll(ctx, query, append(args, maxArchiveResults)...)
+}
+
+func (t *ThreadModel) findAll(ctx context.Context, query string, args ...interface{}) ([]*Thread, error) {
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	threads := []*Thread{}
+	for rows.Next() {
+		thread, err := scanThread(rows)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+// Find ...
+func (t *ThreadModel) Find(ctx context.Context, id int) (*Thread, error) {
+	ctx = database.WithQueryName(ctx, "threads.find")
+	rows, err := t.db.QueryContext(ctx, threadQuery+`WHERE t.id = ? `+threadGroupBy, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	return scanThread(rows)
+}
+
+// LatestOpenThread returns the newest thread of a board which is neither
+// closed nor archived, or ErrNotFound.
+func (t *ThreadModel) LatestOpenThread(ctx context.Context, boardID int) (*Thread, error) {
+	ctx = database.WithQueryName(ctx, "threads.latest_open")
+	query := threadQuery + `WHERE t.board_id = ? AND t.closed_at IS NULL AND t.archived_at IS NULL ` + threadGroupBy + ` ORDER BY t.id DESC LIMIT 1`
+	rows, err := t.db.QueryContext(ctx, query, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	return scanThread(rows)
+}
+
+// Save ...
+func (t *ThreadModel) Save(ctx context.Context, thread *Thread) error {
+	ctx = database.WithQueryName(ctx, "threads.save")
+	if thread.BumpedAt == "" {
+		thread.BumpedAt = thread.CreatedAt
+	}
+	query := `INSERT INTO threads(board_id, subject, created_at, bumped_at) VALUES (?, ?, ?, ?)`
+	id, err := t.db.InsertContext(ctx, query, thread.BoardID, thread.Subject, thread.CreatedAt, thread.BumpedAt)
+	if err != nil {
+		return err
+	}
+	thread.ID = int(id)
+	return nil
+}
+
+// Bump moves the thread to the top of its board.
+func (t *ThreadModel) Bump(ctx context.Context, id int, bumpedAt string) error {
+	ctx = database.WithQueryName(ctx, "threads.bump")
+	query := `UPDATE threads SET bumped_at=? WHERE id=?`
+	if _, err := t.db.ExecuteContext(ctx, query, bumpedAt, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NextPostNo reserves the next post number of an open thread, or returns
+// ErrThreadClosed. The row stays locked until the transaction ends, so
+// concurrent posts are numbered one after another.
+func (t *ThreadModel) NextPostNo(ctx context.Context, id int) (int, error) {
+	ctx = database.WithQueryName(ctx, "threads.next_post_no")
+	query := `UPDATE threads SET last_post_no=last_post_no+1 WHERE id=? AND closed_at IS NULL AND archived_at IS NULL`
+	result, err := t.db.ExecuteContext(ctx, query, id)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrThreadClosed
+	}
+	rows, err := t.db.QueryContext(ctx, `SELECT last_post_no FROM threads WHERE id=?`, id)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, ErrNotFound
+	}
+	var postNo int
+	if err := rows.Scan(&postNo); err != nil {
+		return 0, err
+	}
+	return postNo, nil
+}
+
+// Close closes an open thread.
+func (t *ThreadModel) Close(ctx context.Context, id int, closedAt string) error {
+	ctx = database.WithQueryName(ctx, "threads.close")
+	query := `UPDATE threads SET closed_at=? WHERE id=? AND closed_at IS NULL`
+	if _, err := t.db.ExecuteContext(ctx, query, closedAt, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Archive archives the threads of every board which have had no posts since
+// inactiveSince and returns their number. Archived threads are skipped, so
+// several instances may archive at once.
+func (t *ThreadModel) Archive(ctx context.Context, inactiveSince, archivedAt string) (int, error) {
+	ctx = database.WithQueryName(ctx, "threads.archive")
+	query := `
+	UPDATE threads SET archived_at=?
+	WHERE archived_at IS NULL AND created_at < ?
+	AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.thread_id = threads.id AND m.created_at >= ?)
+	`
+	result, err := t.db.ExecuteContext(ctx, query, archivedAt, inactiveSince, inactiveSince)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern with '!'.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func scanThread(rows *database.Rows) (*Thread, error) {
+	thread := &Thread{}
+	err := rows.Scan(&thread.ID, &thread.BoardID, &thread.Subject, (*timeText)(&thread.CreatedAt), (*timeText)(&thread.BumpedAt),
+		(*timeText)(&thread.ClosedAt), (*timeText)(&thread.ArchivedAt), &thread.PostCount, (*timeText)(&thread.LastPostedAt))
+	if err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
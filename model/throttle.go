@@ -0,0 +1,199 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+const (
+	// maxLoginDelay caps the delay after consecutive failed sign ins.
+	maxLoginDelay = time.Minute
+
+	emailAttemptPrefix = "email:"
+	ipAttemptPrefix    = "ip:"
+)
+
+// ThrottleOptions ...
+type ThrottleOptions struct {
+	// MaxFailures is the number of failed sign ins which locks an account,
+	// 0 disables the lockout.
+	MaxFailures int
+	// MaxIPFailures is the number of failed sign ins which locks an address,
+	// 0 disables the lockout.
+	MaxIPFailures int
+	// Lockout is how long an account or an address stays locked.
+	Lockout time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+	// Delay is the wait after the first failure, doubled on every further
+	// one up to a minute.
+	Delay time.Duration
+}
+
+// LoginThrottle limits the failed sign ins per account and per address. The
+// counts are kept in the store, so the limits hold across instances.
+type LoginThrottle struct {
+	store Store
+	opt   ThrottleOptions
+	// scope prefixes the attempt IDs, empty for sign ins.
+	scope  string
+	logger log15.Logger
+}
+
+// NewLoginThrottle ...
+func NewLoginThrottle(store Store, opt ThrottleOptions) *LoginThrottle {
+	return &LoginThrottle{
+		store:  store,
+		opt:    opt,
+		logger: log15.New("module", "throttle"),
+	}
+}
+
+// WithScope returns a throttle with the limits of t which counts apart from
+// it, e.g. the requests for password reset links, so that they do not lock
+// the sign ins. Its attempts are deleted by the Run of t.
+func (t *LoginThrottle) WithScope(scope string) *LoginThrottle {
+	return &LoginThrottle{
+		store:  t.store,
+		opt:    t.opt,
+		scope:  scope + ":",
+		logger: t.logger.New("scope", scope),
+	}
+}
+
+// Wait returns how long sign ins to email from ip must wait, or 0 when they
+// are allowed now. Failures delay the next sign in to the account; addresses,
+// which users behind a proxy may share, are only locked.
+func (t *LoginThrottle) Wait(ctx context.Context, email, ip string, now time.Time) (time.Duration, error) {
+	ctx = database.WithPrimary(ctx)
+	var wait time.Duration
+	for _, id := range []string{t.scope + emailAttemptID(email), t.scope + ipAttemptID(ip)} {
+		attempt, err := t.store.LoginAttempts().Find(ctx, id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if w := t.wait(attempt, now); w > wait {
+			wait = w
+		}
+	}
+	return wait, nil
+}
+
+func (t *LoginThrottle) wait(attempt *LoginAttempt, now time.Time) time.Duration {
+	var wait time.Duration
+	if lockedUntil, err := time.ParseInLocation(TimeFormat, attempt.LockedUntil, time.Local); err == nil {
+		wait = lockedUntil.Sub(now)
+	}
+	if !strings.HasPrefix(attempt.ID, t.scope+emailAttemptPrefix) {
+		return wait
+	}
+	lastFailed, err := time.ParseInLocation(TimeFormat, attempt.LastFailedAt, time.Local)
+	if err != nil || now.Sub(lastFailed) >= t.opt.Window {
+		return wait
+	}
+	if w := lastFailed.Add(t.delay(attempt.Failures)).Sub(now); w > wait {
+		wait = w
+	}
+	return wait
+}
+
+// delay returns the wait after failures consecutive failures.
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	delay := t.opt.Delay
+	for i := 1; i < failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	return delay
+}
+
+// Fail counts a failed sign in to email from ip and locks the account or the
+// address once it reaches its limit.
+func (t *LoginThrottle) Fail(ctx context.Context, email, ip string, now time.Time) error {
+	ctx = database.WithQueryName(ctx, "login_attempts.count_failure")
+	return t.store.WithTx(ctx, func(store Store) error {
+		for _, limit := range []struct {
+			id  string
+			max int
+		}{
+			{t.scope + emailAttemptID(email), t.opt.MaxFailures},
+			{t.scope + ipAttemptID(ip), t.opt.MaxIPFailures},
+		} {
+			if err := store.LoginAttempts().Fail(ctx, limit.id, now.Format(TimeFormat), now.Add(-t.opt.Window).Format(TimeFormat)); err != nil {
+				return err
+			}
+			attempt, err := store.LoginAttempts().Find(ctx, limit.id)
+			if err != nil {
+				return err
+			}
+			if limit.max <= 0 || attempt.Failures < limit.max {
+				continue
+			}
+			if err := store.LoginAttempts().Lock(ctx, limit.id, now.Add(t.opt.Lockout).Format(TimeFormat)); err != nil {
+				return err
+			}
+			t.logger.Warn("Locked sign ins", "account", strings.HasPrefix(limit.id, t.scope+emailAttemptPrefix), "ip", ip, "failures", attempt.Failures)
+		}
+		return nil
+	})
+}
+
+// Succeed forgets the failed sign ins to email. Those from the address are
+// kept, so signing in to an own account does not lift its limit.
+func (t *LoginThrottle) Succeed(ctx context.Context, email string) error {
+	return t.store.LoginAttempts().Reset(ctx, t.scope+emailAttemptID(email))
+}
+
+// Run deletes the forgotten attempts every Window until ctx is done.
+func (t *LoginThrottle) Run(ctx context.Context) error {
+	if t.opt.Window <= 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(t.opt.Window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		t.collect(ctx, time.Now())
+	}
+}
+
+func (t *LoginThrottle) collect(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, t.opt.Window)
+	defer cancel()
+	n, err := t.store.LoginAttempts().DeleteStale(ctx, now.Add(-t.opt.Window).Format(TimeFormat), now.Format(TimeFormat))
+	if err != nil {
+		t.logger.Warn("Delete login attempts error", "err", err)
+		return
+	}
+	if n > 0 {
+		t.logger.Debug("Deleted login attempts", "attempts", n)
+	}
+}
+
+// emailAttemptID returns the attempt ID of an email, which is hashed so that
+// the table holds no emails, including mistyped ones.
+func emailAttemptID(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return emailAttemptPrefix + hex.EncodeToString(sum[:])
+}
+
+func ipAttemptID(ip string) string {
+	return ipAttemptPrefix + ip
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottleDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{"no failures", time.Second, 0, time.Second},
+		{"first failure", time.Second, 1, time.Second},
+		{"second failure", time.Second, 2, 2 * time.Second},
+		{"fifth failure", time.Second, 5, 16 * time.Second},
+		{"capped", time.Second, 7, maxLoginDelay},
+		{"capped long after", time.Second, 1000, maxLoginDelay},
+		{"first delay above the cap", 2 * time.Minute, 1, maxLoginDelay},
+		{"disabled", 0, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := &LoginThrottle{opt: ThrottleOptions{Delay: tt.delay}}
+			if got := throttle.delay(tt.failures); got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	opt := ThrottleOptions{
+		MaxFailures:   3,
+		MaxIPFailures: 10,
+		Lockout:       15 * time.Minute,
+		Window:        time.Hour,
+		Delay:         time.Second,
+	}
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			throttle := NewLoginThrottle(store, opt)
+			resets := throttle.WithScope("reset")
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+
+			// fail returns a step which fails a sign in to email from ip.
+			fail := func(email, ip string, at time.Time) func() error {
+				return func() error { return throttle.Fail(ctx, email, ip, at) }
+			}
+			steps := []struct {
+				name     string
+				run      func() error
+				throttle *LoginThrottle
+				email    string
+				want     time.Duration
+			}{
+				{"first failure", fail("a@example.com", "192.0.2.1", now), throttle, "a@example.com", time.Second},
+				{"other account", nil, throttle, "b@example.com", 0},
+				{"second failure", fail("a@example.com", "192.0.2.1", now), throttle, "a@example.com", 2 * time.Second},
+				{"locked", fail("a@example.com", "192.0.2.1", now), throttle, "a@example.com", opt.Lockout},
+				{"reset links counted apart", nil, resets, "a@example.com", 0},
+				{"failure long ago", fail("c@example.com", "192.0.2.2", now.Add(-2*opt.Window)), throttle, "c@example.com", 0},
+				{"counted anew after the window", fail("c@example.com", "192.0.2.2", now), throttle, "c@example.com", time.Second},
+				{"succeeded", func() error { return throttle.Succeed(ctx, "c@example.com") }, throttle, "c@example.com", 0},
+			}
+			for _, step := range steps {
+				if step.run != nil {
+					if err := step.run(); err != nil {
+						t.Fatalf("%s: %v", step.name, err)
+					}
+				}
+				wait, err := step.throttle.Wait(ctx, step.email, "198.51.100.1", now)
+				if err != nil {
+					t.Fatalf("%s: Wait() error = %v", step.name, err)
+				}
+				if wait != step.want {
+					t.Errorf("%s: Wait() = %v, want %v", step.name, wait, step.want)
+				}
+			}
+		})
+	}
+}
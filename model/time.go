@@ -0,0 +1,33 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeFormat is the layout of the time values of the models.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// timeText scans a time column into a string in TimeFormat, whether the
+// driver returns it as a time.Time or as text.
+type timeText string
+
+// Scan implements sql.Scanner.
+func (t *timeText) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*t = timeText(v.Format(TimeFormat))
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			v = parsed.Format(TimeFormat)
+		}
+		*t = timeText(v)
+	case nil:
+		*t = ""
+	default:
+		return fmt.Errorf("model: cannot scan %T into a time", src)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+)
+
+// RecoveryCodeCount is the number of recovery codes a user gets.
+const RecoveryCodeCount = 10
+
+// TwoFactorRoles lists the roles which must sign in with a second factor. It
+// is a flag.Value of comma separated roles.
+type TwoFactorRoles []string
+
+// String ...
+func (r *TwoFactorRoles) String() string {
+	return strings.Join(*r, ",")
+}
+
+// Set ...
+func (r *TwoFactorRoles) Set(s string) error {
+	*r = nil
+	for _, role := range strings.Split(s, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			*r = append(*r, role)
+		}
+	}
+	return nil
+}
+
+// Requires reports whether users with role must sign in with a second factor.
+func (r TwoFactorRoles) Requires(role string) bool {
+	for _, required := range r {
+		if required == role {
+			return true
+		}
+	}
+	return false
+}
+
+// EnrollTwoFactor enrolls the user userID in two-factor sign in with secret,
+// whose code of step the user just gave, and returns its new recovery codes.
+func EnrollTwoFactor(ctx context.Context, store Store, userID int, secret string, step int64) ([]string, error) {
+	codes, err := cryptoutil.NewRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	err = store.WithTx(database.WithQueryName(ctx, "users.enroll_two_factor"), func(store Store) error {
+		if err := store.Users().SetTOTPSecret(ctx, userID, secret); err != nil {
+			return err
+		}
+		// The code given to enroll cannot sign in again.
+		if _, err := store.Users().UseTOTPStep(ctx, userID, step); err != nil {
+			return err
+		}
+		return store.RecoveryCodes().Replace(ctx, userID, hashRecoveryCodes(codes))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RenewRecoveryCodes replaces the recovery codes of the user userID and
+// returns the new ones.
+func RenewRecoveryCodes(ctx context.Context, store Store, userID int) ([]string, error) {
+	codes, err := cryptoutil.NewRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	err = store.WithTx(database.WithQueryName(ctx, "recovery_codes.renew"), func(store Store) error {
+		return store.RecoveryCodes().Replace(ctx, userID, hashRecoveryCodes(codes))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor withdraws the user userID from two-factor sign in.
+func DisableTwoFactor(ctx context.Context, store Store, userID int) error {
+	return store.WithTx(database.WithQueryName(ctx, "users.disable_two_factor"), func(store Store) error {
+		if err := store.Users().SetTOTPSecret(ctx, userID, ""); err != nil {
+			return err
+		}
+		return store.RecoveryCodes().DeleteByUserID(ctx, userID)
+	})
+}
+
+// VerifySecondFactor reports whether code is a TOTP code of user at now which
+// was not used yet, or one of its recovery codes, which is spent.
+func VerifySecondFactor(ctx context.Context, store Store, user *User, code string, now time.Time) (bool, error) {
+	if !user.TwoFactorEnabled() {
+		return false, nil
+	}
+	if step, ok := cryptoutil.VerifyTOTP(user.TOTPSecret, code, now); ok {
+		return store.Users().UseTOTPStep(ctx, user.ID, step)
+	}
+	used, err := store.RecoveryCodes().Use(ctx, user.ID, cryptoutil.HashRecoveryCode(code))
+	if err != nil || !used {
+		return false, err
+	}
+	log15.New("module", "model").Info("Used a recovery code", "user_id", user.ID)
+	return true, nil
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = cryptoutil.HashRecoveryCode(code)
+	}
+	return hashes
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+)
+
+func TestTwoFactorRoles(t *testing.T) {
+	tests := []struct {
+		flag string
+		want TwoFactorRoles
+	}{
+		{"", nil},
+		{"admin", TwoFactorRoles{"admin"}},
+		{" admin , member ,", TwoFactorRoles{"admin", "member"}},
+	}
+	for _, tt := range tests {
+		var roles TwoFactorRoles
+		roles.Set(tt.flag)
+		if !reflect.DeepEqual(roles, tt.want) {
+			t.Errorf("Set(%q) = %q, want %q", tt.flag, roles, tt.want)
+		}
+	}
+	roles := TwoFactorRoles{"admin"}
+	if !roles.Requires(RoleAdmin) || roles.Requires(RoleMember) {
+		t.Errorf("%v requires admin %v, member %v", roles, roles.Requires(RoleAdmin), roles.Requires(RoleMember))
+	}
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+	step := cryptoutil.TOTPStep(now)
+	code := func(step int64) string {
+		c, err := cryptoutil.TOTPCode(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user := &User{Name: "alice", Email: "alice@example.com", Password: "hash"}
+			if err := store.Users().Save(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			// The user enrolls with the code of the previous step.
+			recovery, err := EnrollTwoFactor(ctx, store, user.ID, secret, step-1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recovery) != RecoveryCodeCount {
+				t.Fatalf("EnrollTwoFactor() returned %d recovery codes, want %d", len(recovery), RecoveryCodeCount)
+			}
+			user, err = store.Users().Find(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name string
+				code string
+				want bool
+			}{
+				{"enrollment code", code(step - 1), false},
+				{"current code", code(step), true},
+				{"current code again", code(step), false},
+				{"recovery code", recovery[0], true},
+				{"recovery code again", recovery[0], false},
+				{"recovery code in upper case", "  " + strings.ToUpper(recovery[1]), true},
+				{"wrong code", "000000", false},
+			}
+			for _, tt := range tests {
+				got, err := VerifySecondFactor(ctx, store, user, tt.code, now)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				if got != tt.want {
+					t.Errorf("%s: VerifySecondFactor() = %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,20 +1,51 @@
 package model
 
 import (
+	"context"
+
 	"github.com/seka/bbs-sample/database"
 )
 
 // User ...
 type User struct {
-	ID       int
-	Name     string
-	Email    string
+	ID    int
+	Name  string
+	Email string
+	// Password is the hash of the password.
 	Password string
+	Role     string
+	// EmailVerifiedAt is empty until the user proves to own Email.
+	EmailVerifiedAt string
+	// TOTPSecret is empty unless the user enrolled in two-factor sign in.
+	TOTPSecret string
+	// TOTPLastStep is the time step of the last TOTP code used.
+	TOTPLastStep int64
+}
+
+// EmailVerified ...
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != ""
 }
 
+// TwoFactorEnabled ...
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPSecret != ""
+}
+
+const (
+	// RoleMember ...
+	RoleMember = "member"
+
+	// RoleAdmin may post on every board.
+	RoleAdmin = "admin"
+
+	// RoleGuest is the role of visitors without an account.
+	RoleGuest = "guest"
+)
+
 // UserModel ...
 type UserModel struct {
-	db database.Database
+	db database.Querier
 }
 
 // NewUserModel ...
@@ -24,40 +55,60 @@ func NewUserModel(db database.Database) *UserModel {
 	}
 }
 
-// Find ...
-func (u *UserModel) Find(user *User) (*User, error) {
-	query := `
-	SELECT id, name, email FROM users
-	WHERE email=?
-	AND password_hash=?
-	LIMIT 1
-	`
-	rows, err := u.db.Query(query, user.Email, user.Password)
+// WithTx returns a UserModel which runs its queries in tx.
+func (u *UserModel) WithTx(tx database.Tx) *UserModel {
+	return &UserModel{
+		db: tx,
+	}
+}
+
+// FindByEmail returns the user with email, its password hash and its TOTP
+// secret, or ErrNotFound.
+func (u *UserModel) FindByEmail(ctx context.Context, email string) (*User, error) {
+	ctx = database.WithQueryName(ctx, "users.find_by_email")
+	return u.find(ctx, `WHERE email=?`, email)
+}
+
+// Find returns the user with id, its password hash and its TOTP secret, or
+// ErrNotFound.
+func (u *UserModel) Find(ctx context.Context, id int) (*User, error) {
+	ctx = database.WithQueryName(ctx, "users.find")
+	return u.find(ctx, `WHERE id=?`, id)
+}
+
+func (u *UserModel) find(ctx context.Context, where string, args ...interface{}) (*User, error) {
+	query := `SELECT id, name, email, password_hash, role, email_verified_at, totp_secret, totp_last_step FROM users ` + where + ` LIMIT 1`
+	rows, err := u.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	for rows.Next() {
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
 			return nil, err
 		}
+		return nil, ErrNotFound
 	}
-	if err := rows.Err(); err != nil {
+	user := &User{}
+	if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, (*timeText)(&user.EmailVerifiedAt), &user.TOTPSecret, &user.TOTPLastStep); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
 // FindAll ...
-func (u *UserModel) FindAll() ([]*User, error) {
-	query := `SELECT id, name, email FROM users`
-	rows, err := u.db.Query(query)
+func (u *UserModel) FindAll(ctx context.Context) ([]*User, error) {
+	ctx = database.WithQueryName(ctx, "users.find_all")
+	query := `SELECT id, name, email, role FROM users`
+	rows, err := u.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	users := []*User{}
 	for rows.Next() {
 		u := &User{}
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role); err != nil {
 			break
 		}
 		users = append(users, u)
@@ -68,22 +119,81 @@ func (u *UserModel) FindAll() ([]*User, error) {
 	return users, nil
 }
 
-// Save ...
-func (u *UserModel) Save(user *User) error {
-	query := `INSERT INTO users(id, name, email, password_hash) VALUES (?, ?, ?, ?)`
-	_, err := u.db.Execute(query, user.ID, user.Name, user.Email, user.Password)
+// Save saves user with RoleMember unless Role is set.
+func (u *UserModel) Save(ctx context.Context, user *User) error {
+	ctx = database.WithQueryName(ctx, "users.save")
+	if user.Role == "" {
+		user.Role = RoleMember
+	}
+	var verifiedAt interface{}
+	if user.EmailVerifiedAt != "" {
+		verifiedAt = user.EmailVerifiedAt
+	}
+	query := `INSERT INTO users(name, email, password_hash, role, email_verified_at) VALUES (?, ?, ?, ?, ?)`
+	id, err := u.db.InsertContext(ctx, query, user.Name, user.Email, user.Password, user.Role, verifiedAt)
 	if err != nil {
 		return err
 	}
+	user.ID = int(id)
 	return nil
 }
 
+// UpdatePassword replaces the password hash of the user id.
+func (u *UserModel) UpdatePassword(ctx context.Context, id int, hash string) error {
+	ctx = database.WithQueryName(ctx, "users.update_password")
+	query := `UPDATE users SET password_hash=? WHERE id=?`
+	if _, err := u.db.ExecuteContext(ctx, query, hash, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// VerifyEmail records that the user id verified its email at verifiedAt.
+func (u *UserModel) VerifyEmail(ctx context.Context, id int, verifiedAt string) error {
+	ctx = database.WithQueryName(ctx, "users.verify_email")
+	query := `UPDATE users SET email_verified_at=? WHERE id=?`
+	if _, err := u.db.ExecuteContext(ctx, query, verifiedAt, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetTOTPSecret enrolls the user id in two-factor sign in with secret, or
+// withdraws it when secret is empty.
+func (u *UserModel) SetTOTPSecret(ctx context.Context, id int, secret string) error {
+	ctx = database.WithQueryName(ctx, "users.set_totp_secret")
+	query := `UPDATE users SET totp_secret=?, totp_last_step=0 WHERE id=?`
+	if _, err := u.db.ExecuteContext(ctx, query, secret, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UseTOTPStep records that the user id used the TOTP code of step. It returns
+// false when a code of step or a later one was used already, so that a code
+// works once even across instances.
+func (u *UserModel) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	ctx = database.WithQueryName(ctx, "users.use_totp_step")
+	query := `UPDATE users SET totp_last_step=? WHERE id=? AND totp_last_step<?`
+	result, err := u.db.ExecuteContext(ctx, query, step, id, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
 // Exists ...
-func (u *UserModel) Exists(user *User) bool {
-	query := `SELECT TRUE  users(id) VALUES (?, ?)`
-	rows, err := u.db.Query(query, user.Email, user.Password)
+func (u *UserModel) Exists(ctx context.Context, user *User) bool {
+	ctx = database.WithQueryName(ctx, "users.exists")
+	query := `SELECT id FROM users WHERE email=? LIMIT 1`
+	rows, err := u.db.QueryContext(ctx, query, user.Email)
 	if err != nil {
 		return false
 	}
+	defer rows.Close()
 	return rows.Next()
 }
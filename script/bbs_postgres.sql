@@ -0,0 +1,12 @@
+--
+-- Database and user for bbs-sample on PostgreSQL.
+--
+-- $ psql --username=postgres < bbs_postgres.sql
+--
+-- The tables are created by the schema migrations:
+--
+--   $ bbs-sampled -database-driver=postgres -database-addr=localhost:5432 migrate up
+--
+
+CREATE USER "bbs-sample-user" WITH PASSWORD 'bbs-sample-password';
+CREATE DATABASE "bbs-sample" OWNER "bbs-sample-user";
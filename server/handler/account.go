@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gsess "github.com/gorilla/sessions"
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+	"github.com/seka/bbs-sample/internal/httputil"
+	"github.com/seka/bbs-sample/mailer"
+	"github.com/seka/bbs-sample/model"
+)
+
+const (
+	verifyEmailPurpose   = "verify-email"
+	resetPasswordPurpose = "reset-password"
+
+	// verifyEmailTTL and resetPasswordTTL are how long the links sent by
+	// email stay valid.
+	verifyEmailTTL   = 48 * time.Hour
+	resetPasswordTTL = time.Hour
+
+	// mailTimeout bounds sending an email after the response was written.
+	mailTimeout = time.Minute
+
+	// resetThrottledMessage is shown while reset links are throttled.
+	resetThrottledMessage = "Too many requests for reset links, try again later."
+)
+
+// accountMail sends the emails with the links to verify an email and to reset
+// a password.
+type accountMail struct {
+	mailer  mailer.Mailer
+	secret  []byte
+	baseURL string
+	logger  log15.Logger
+}
+
+func newAccountMail(opt Option, logger log15.Logger) *accountMail {
+	return &accountMail{
+		mailer:  opt.Mailer,
+		secret:  opt.TokenSecret,
+		baseURL: strings.TrimRight(opt.BaseURL, "/"),
+		logger:  logger,
+	}
+}
+
+// verifyEmailState and resetPasswordState are signed into the tokens, so a
+// token is spent once the email is verified or the password changed.
+func verifyEmailState(user *model.User) string {
+	return user.Email + "\x00" + user.EmailVerifiedAt
+}
+
+func resetPasswordState(user *model.User) string {
+	return user.Email + "\x00" + user.Password
+}
+
+// sendVerification sends user a link to verify its email.
+func (a *accountMail) sendVerification(user *model.User) {
+	token := cryptoutil.SignToken(a.secret, verifyEmailPurpose, user.ID, time.Now().Add(verifyEmailTTL), verifyEmailState(user))
+	a.send(user, "verify_email", "/account/verify?token="+url.QueryEscape(token), verifyEmailTTL)
+}
+
+// sendPasswordReset sends user a link to reset its password. user must carry
+// its password hash.
+func (a *accountMail) sendPasswordReset(user *model.User) {
+	token := cryptoutil.SignToken(a.secret, resetPasswordPurpose, user.ID, time.Now().Add(resetPasswordTTL), resetPasswordState(user))
+	a.send(user, "reset_password", "/account/reset?token="+url.QueryEscape(token), resetPasswordTTL)
+}
+
+// send renders the message name for user with the link to path and sends it
+// in the background, so the response does not wait for the mail server nor
+// tell by its timing whether a message was sent.
+func (a *accountMail) send(user *model.User, name, path string, ttl time.Duration) {
+	data := &struct {
+		Name    string
+		URL     string
+		Expires string
+	}{
+		Name:    user.Name,
+		URL:     a.baseURL + path,
+		Expires: ttl.String(),
+	}
+	msg, err := mailer.NewMessage(filepath.Join("server", "view", "mail"), name, user.Email, data)
+	if err != nil {
+		a.logger.Error("Render email error", "email", name, "err", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := a.mailer.Send(ctx, msg); err != nil {
+			a.logger.Error("Send email error", "email", name, "user_id", user.ID, "err", err)
+			return
+		}
+		a.logger.Info("Sent email", "email", name, "user_id", user.ID)
+	}()
+}
+
+// userOfToken returns the user whose token for purpose and state is valid, or
+// cryptoutil.ErrInvalidToken.
+func userOfToken(ctx context.Context, users model.UserRepository, secret []byte, token, purpose string, state func(*model.User) string) (*model.User, error) {
+	userID, err := cryptoutil.TokenUser(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := users.Find(database.WithPrimary(ctx), userID)
+	if err == model.ErrNotFound {
+		return nil, cryptoutil.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cryptoutil.VerifyToken(secret, token, purpose, state(user), time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Account verifies emails and resets forgotten passwords at /account/verify,
+// /account/forgot and /account/reset, and sets up two-factor sign in at
+// /account/2fa.
+type Account struct {
+	cookieStore gsess.Store
+	store       model.Store
+	passwords   cryptoutil.PasswordHasher
+	throttle    *model.LoginThrottle
+	resets      *model.LoginThrottle
+	signIn      *signIn
+	mail        *accountMail
+	logger      log15.Logger
+}
+
+// NewAccount ...
+func NewAccount(opt Option) *Account {
+	logger := log15.New("module", "handler", "handler", "account")
+	return &Account{
+		cookieStore: opt.CookieStore,
+		store:       opt.Store,
+		passwords:   opt.Passwords,
+		throttle:    opt.Throttle,
+		resets:      opt.Throttle.WithScope("reset"),
+		signIn:      newSignIn(opt, logger),
+		mail:        newAccountMail(opt, logger),
+		logger:      logger,
+	}
+}
+
+func (a *Account) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch path := strings.TrimPrefix(r.URL.Path, "/account/"); {
+	case path == "verify" && r.Method == "GET":
+		a.verify(w, r)
+	case path == "verify" && r.Method == "POST":
+		a.resendVerification(w, r)
+	case path == "forgot" && r.Method == "GET":
+		a.render(w, http.StatusOK, "forgot.html", nil)
+	case path == "forgot" && r.Method == "POST":
+		a.forgot(w, r)
+	case path == "reset" && r.Method == "GET":
+		a.showReset(w, r)
+	case path == "reset" && r.Method == "POST":
+		a.reset(w, r)
+	case path == "2fa" && (r.Method == "GET" || r.Method == "POST"):
+		a.twoFactor(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// verify marks the email of the user of ?token= as verified.
+func (a *Account) verify(w http.ResponseWriter, r *http.Request) {
+	user, err := userOfToken(r.Context(), a.store.Users(), a.mail.secret, r.FormValue("token"), verifyEmailPurpose, verifyEmailState)
+	if err == cryptoutil.ErrInvalidToken {
+		a.notice(w, http.StatusBadRequest, "This link is invalid or has expired. Sign in to get a new one.")
+		return
+	}
+	if err != nil {
+		a.logger.Error("Find user error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if err := a.store.Users().VerifyEmail(r.Context(), user.ID, time.Now().Format(model.TimeFormat)); err != nil {
+		a.logger.Error("Verify email error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	a.logger.Info("Verified email", "user_id", user.ID)
+	a.notice(w, http.StatusOK, "Your email is verified.")
+}
+
+// resendVerification sends the signed in user a new verification link.
+func (a *Account) resendVerification(w http.ResponseWriter, r *http.Request) {
+	user := a.signedInUser(w, r)
+	if user == nil {
+		return
+	}
+	if !user.EmailVerified() {
+		a.mail.sendVerification(user)
+	}
+	a.notice(w, http.StatusOK, "We sent you a new link to verify your email.")
+}
+
+// forgot sends a password reset link to ?email=. The answer is the same
+// whether the email has an account or not. The requests are limited per email
+// and address like the sign ins, but counted apart from them.
+func (a *Account) forgot(w http.ResponseWriter, r *http.Request) {
+	email := r.FormValue("email")
+	ip := httputil.ClientIP(r, a.signIn.trustForwardedFor)
+	now := time.Now()
+	wait, err := a.resets.Wait(r.Context(), email, ip, now)
+	if err != nil {
+		a.logger.Error("Throttle error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		a.logger.Info("Throttled reset link", "ip", ip, "wait", wait)
+		w.Header().Set("Retry-After", (&throttledError{wait: wait}).retryAfter())
+		a.notice(w, http.StatusTooManyRequests, resetThrottledMessage)
+		return
+	}
+	// Every request counts, whether the email has an account or not.
+	if err := a.resets.Fail(r.Context(), email, ip, now); err != nil {
+		a.logger.Warn("Count reset link error", "err", err)
+	}
+	user, err := a.store.Users().FindByEmail(r.Context(), email)
+	if err != nil && err != model.ErrNotFound {
+		a.logger.Error("Find user error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if err == nil {
+		a.mail.sendPasswordReset(user)
+	}
+	a.notice(w, http.StatusOK, "If the email has an account, we sent it a link to reset the password.")
+}
+
+func (a *Account) showReset(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	_, err := userOfToken(r.Context(), a.store.Users(), a.mail.secret, token, resetPasswordPurpose, resetPasswordState)
+	if err == cryptoutil.ErrInvalidToken {
+		a.notice(w, http.StatusBadRequest, "This link is invalid or has expired.")
+		return
+	}
+	if err != nil {
+		a.logger.Error("Find user error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	a.render(w, http.StatusOK, "reset.html", &struct{ Token string }{token})
+}
+
+// reset sets the password of the user of ?token= and signs out its sessions.
+func (a *Account) reset(w http.ResponseWriter, r *http.Request) {
+	user, err := userOfToken(r.Context(), a.store.Users(), a.mail.secret, r.FormValue("token"), resetPasswordPurpose, resetPasswordState)
+	if err == cryptoutil.ErrInvalidToken {
+		a.notice(w, http.StatusBadRequest, "This link is invalid or has expired.")
+		return
+	}
+	if err != nil {
+		a.logger.Error("Find user error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	passwd := r.FormValue("password")
+	if passwd == "" || passwd != r.FormValue("confirm") {
+		http.Error(w, "Difference password", http.StatusBadRequest)
+		return
+	}
+	hash, err := a.passwords.Hash(passwd)
+	if err == cryptoutil.ErrPasswordTooLong {
+		http.Error(w, "Password is too long", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		a.logger.Error("Hash password error", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := model.ResetPassword(r.Context(), a.store, user, hash, time.Now().Format(model.TimeFormat)); err != nil {
+		a.logger.Error("Reset password error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if err := a.throttle.Succeed(r.Context(), user.Email); err != nil {
+		a.logger.Warn("Reset failed sign ins error", "err", err)
+	}
+	a.logger.Info("Reset password", "user_id", user.ID)
+	a.notice(w, http.StatusOK, "Your password is changed. Sign in with the new one.")
+}
+
+// notice renders a message with a link to the sign in page.
+func (a *Account) notice(w http.ResponseWriter, code int, message string) {
+	a.render(w, code, "notice.html", &struct{ Message string }{message})
+}
+
+func (a *Account) render(w http.ResponseWriter, code int, name string, data interface{}) {
+	renderView(w, a.logger, code, name, data)
+}
+
+// renderView renders the view name with data and the status code.
+func renderView(w http.ResponseWriter, logger log15.Logger, code int, name string, data interface{}) {
+	tmpl, err := template.ParseFiles(filepath.Join("server", "view", name))
+	if err != nil {
+		logger.Error("Parse template error", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := tmpl.Execute(w, data); err != nil {
+		logger.Error("Template execute error", "err", err)
+	}
+}
+
+var _ http.Handler = (*Account)(nil)
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+const (
+	// basicAuthTTL is how long a verified email and password pair signs in
+	// without checking the password hash again. 2ch-style browsers send
+	// basic authentication with every request, and a password hash takes
+	// tens of milliseconds to check.
+	basicAuthTTL = time.Minute
+
+	// maxBasicAuthEntries bounds the number of cached sign ins.
+	maxBasicAuthEntries = 1024
+)
+
+// basicAuthCache remembers the users signed in by basic authentication for
+// basicAuthTTL. It is keyed by an HMAC of the credentials under a random key,
+// so that the passwords are not kept in memory.
+type basicAuthCache struct {
+	key     []byte
+	mu      sync.Mutex
+	entries map[string]basicAuthEntry
+}
+
+type basicAuthEntry struct {
+	user    *model.User
+	expires time.Time
+}
+
+func newBasicAuthCache() *basicAuthCache {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &basicAuthCache{
+		key:     key,
+		entries: make(map[string]basicAuthEntry),
+	}
+}
+
+func (c *basicAuthCache) credential(email, password string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(email))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return string(mac.Sum(nil))
+}
+
+// get returns the user signed in by email and password, or nil when the pair
+// was not verified within basicAuthTTL.
+func (c *basicAuthCache) get(email, password string, now time.Time) *model.User {
+	key := c.credential(email, password)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return entry.user
+}
+
+// put remembers that email and password sign in user.
+func (c *basicAuthCache) put(email, password string, user *model.User, now time.Time) {
+	key := c.credential(email, password)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxBasicAuthEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= maxBasicAuthEntries {
+		c.entries = make(map[string]basicAuthEntry)
+	}
+	c.entries[key] = basicAuthEntry{user: user, expires: now.Add(basicAuthTTL)}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+func TestBasicAuthCache(t *testing.T) {
+	c := newBasicAuthCache()
+	now := time.Now()
+	alice := &model.User{ID: 1, Name: "alice"}
+	if got := c.get("alice@example.com", "secret", now); got != nil {
+		t.Errorf("get() before put() = %v, want nil", got)
+	}
+	c.put("alice@example.com", "secret", alice, now)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		now      time.Time
+		want     *model.User
+	}{
+		{"cached", "alice@example.com", "secret", now, alice},
+		{"just before expiry", "alice@example.com", "secret", now.Add(basicAuthTTL - time.Second), alice},
+		{"wrong password", "alice@example.com", "Secret", now, nil},
+		{"other email", "bob@example.com", "secret", now, nil},
+		// The email and password are not simply concatenated.
+		{"shifted separator", "alice@example.coms", "ecret", now, nil},
+		{"expired", "alice@example.com", "secret", now.Add(basicAuthTTL), nil},
+		{"expired entry removed", "alice@example.com", "secret", now, nil},
+	}
+	for _, tt := range tests {
+		if got := c.get(tt.email, tt.password, tt.now); got != tt.want {
+			t.Errorf("%s: get() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBasicAuthCacheBounded(t *testing.T) {
+	c := newBasicAuthCache()
+	now := time.Now()
+	user := &model.User{ID: 1}
+	for i := 0; i < maxBasicAuthEntries; i++ {
+		c.put(fmt.Sprintf("user%d@example.com", i), "secret", user, now)
+	}
+	// A full cache first drops its expired entries.
+	later := now.Add(basicAuthTTL)
+	c.put("new@example.com", "secret", user, later)
+	if len(c.entries) != 1 || c.get("new@example.com", "secret", later) != user {
+		t.Errorf("%d entries after expired ones were dropped, want only the new one", len(c.entries))
+	}
+	// When none has expired it is emptied.
+	for i := 1; i < maxBasicAuthEntries; i++ {
+		c.put(fmt.Sprintf("user%d@example.com", i), "secret", user, later)
+	}
+	c.put("newer@example.com", "secret", user, later)
+	if len(c.entries) != 1 || c.get("newer@example.com", "secret", later) != user {
+		t.Errorf("%d entries after a full cache was emptied, want only the newest", len(c.entries))
+	}
+}
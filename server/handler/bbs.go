@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"fmt"
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	gsess "github.com/gorilla/sessions"
@@ -15,17 +18,27 @@ import (
 
 // BBS ...
 type BBS struct {
-	cookieStore  gsess.Store
-	messageModel *model.MessageModel
-	logger       log15.Logger
+	cookieStore    gsess.Store
+	store          model.Store
+	boards         model.BoardRepository
+	threads        model.ThreadRepository
+	messages       model.MessageRepository
+	posters        *posters
+	readYourWrites time.Duration
+	logger         log15.Logger
 }
 
 // NewBBS ...
 func NewBBS(opt Option) *BBS {
 	return &BBS{
-		cookieStore:  opt.CookieStore,
-		messageModel: model.NewMessageModel(opt.DB),
-		logger:       log15.New("module", "handler", "handler", "bbs"),
+		cookieStore:    opt.CookieStore,
+		store:          opt.Store,
+		boards:         opt.Store.Boards(),
+		threads:        opt.Store.Threads(),
+		messages:       opt.Store.Messages(),
+		posters:        newPosters(opt),
+		readYourWrites: opt.ReadYourWrites,
+		logger:         log15.New("module", "handler", "handler", "bbs"),
 	}
 }
 
@@ -35,6 +48,7 @@ func (b *BBS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/", http.StatusFound)
 		return
 	}
+	r = r.WithContext(readYourWrites(r.Context(), sess, b.readYourWrites))
 	switch r.Method {
 	case "GET":
 		b.show(sess, w, r)
@@ -45,11 +59,18 @@ func (b *BBS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// show serves a page of the messages, ?before={id} and ?after={id} page
+// back and forth and ?limit= sets the page size.
 func (b *BBS) show(sess *gsess.Session, w http.ResponseWriter, r *http.Request) {
-	msgs, err := b.messageModel.FindAll()
+	q, ok := pageQuery(r)
+	if !ok {
+		http.Error(w, "Invalid page", http.StatusBadRequest)
+		return
+	}
+	page, err := b.messages.FindPage(r.Context(), q)
 	if err != nil {
 		b.logger.Error("find all messages error", "err", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	tmpl, err := template.ParseFiles(filepath.Join("server", "view", "bbs.html"))
@@ -60,31 +81,129 @@ func (b *BBS) show(sess *gsess.Session, w http.ResponseWriter, r *http.Request)
 	}
 	data := &struct {
 		Name      string
-		Messages  []*model.Message
+		Verified  bool
+		Posts     []*post
+		Older     string
+		Newer     string
 		CsrfToken string
 	}{
 		Name:      sess.Values["name"].(string),
-		Messages:  msgs,
+		Verified:  sessionVerified(sess),
+		Posts:     newFeedPosts(page.Messages),
 		CsrfToken: nosurf.Token(r),
 	}
+	if n := len(page.Messages); n > 0 {
+		if page.HasOlder {
+			data.Older = pageURL("before", page.Messages[0].ID, q.Limit)
+		}
+		if page.HasNewer {
+			data.Newer = pageURL("after", page.Messages[n-1].ID, q.Limit)
+		}
+	}
 	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// post appends the message to the newest open thread of the default board,
+// so the single feed does not start a thread per message. A thread whose
+// subject is the beginning of the message is started when there is none.
 func (b *BBS) post(sess *gsess.Session, w http.ResponseWriter, r *http.Request) {
+	board, err := b.boards.FindByID(r.Context(), model.DefaultBoardID)
+	if err != nil {
+		b.logger.Error("find default board error", "err", err)
+		writeError(w, r, err)
+		return
+	}
 	msg := &model.Message{
 		UserID:    sess.Values["id"].(int),
 		Message:   r.FormValue("message"),
-		CreatedAt: time.Now().String(),
+		CreatedAt: time.Now().Format(model.TimeFormat),
+	}
+	if code, reason := checkPost(board, sessionRole(sess), msg.Message); code != http.StatusOK {
+		http.Error(w, reason, code)
+		return
+	}
+	b.posters.identify(r, board, msg, "")
+	thread, err := b.latestThread(r, board)
+	if err != nil {
+		b.logger.Error("find threads error", "err", err)
+		writeError(w, r, err)
+		return
 	}
-	if err := b.messageModel.Save(msg); err != nil {
+	if thread != nil {
+		msg.ThreadID = thread.ID
+		err = model.Reply(r.Context(), b.store, msg, false)
+	}
+	// The thread may have been closed since it was found.
+	if thread == nil || err == model.ErrThreadClosed {
+		thread = &model.Thread{
+			BoardID: board.ID,
+			Subject: subjectOf(msg.Message),
+		}
+		err = model.StartThread(r.Context(), b.store, thread, msg)
+	}
+	if err != nil {
 		b.logger.Error("save message error", "err", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	markWritten(sess)
+	if err := sess.Save(r, w); err != nil {
+		b.logger.Error("save cookie store error", "err", err)
+	}
 	http.Redirect(w, r, "/bbs", http.StatusFound)
 }
 
+// latestThread returns the newest open thread of board, or nil.
+func (b *BBS) latestThread(r *http.Request, board *model.Board) (*model.Thread, error) {
+	thread, err := b.threads.LatestOpenThread(r.Context(), board.ID)
+	if err == model.ErrNotFound {
+		return nil, nil
+	}
+	return thread, err
+}
+
+// pageQuery returns the page selected by the query of r.
+func pageQuery(r *http.Request) (model.PageQuery, bool) {
+	var q model.PageQuery
+	for name, v := range map[string]*int{"before": &q.Before, "after": &q.After, "limit": &q.Limit} {
+		s := r.FormValue(name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, false
+		}
+		*v = n
+	}
+	return q, q.Before == 0 || q.After == 0
+}
+
+// pageURL returns the URL of the page of messages before or after id.
+func pageURL(cursor string, id, limit int) string {
+	u := fmt.Sprintf("/bbs?%s=%d", cursor, id)
+	if limit > 0 {
+		u += fmt.Sprintf("&limit=%d", limit)
+	}
+	return u
+}
+
+// subjectLength is the length of the subjects made by subjectOf.
+const subjectLength = 30
+
+// subjectOf returns the first line of message, shortened to subjectLength.
+func subjectOf(message string) string {
+	subject := strings.TrimSpace(message)
+	if i := strings.IndexAny(subject, "\r\n"); i >= 0 {
+		subject = subject[:i]
+	}
+	if runes := []rune(subject); len(runes) > subjectLength {
+		subject = string(runes[:subjectLength-1]) + "…"
+	}
+	return subject
+}
+
 var _ http.Handler = (*BBS)(nil)
@@ -0,0 +1,413 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	gsess "github.com/gorilla/sessions"
+	"github.com/inconshreveable/log15"
+	"github.com/justinas/nosurf"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+// Board serves the board list at /boards, the thread index of a board at
+// /boards/{slug}, its threads at /boards/{slug}/{id} and its archived threads
+// at /boards/{slug}/archive. Boards which allow guests are served without a
+// session.
+type Board struct {
+	cookieStore    gsess.Store
+	store          model.Store
+	boards         model.BoardRepository
+	threads        model.ThreadRepository
+	messages       model.MessageRepository
+	posters        *posters
+	readYourWrites time.Duration
+	logger         log15.Logger
+}
+
+// NewBoard ...
+func NewBoard(opt Option) *Board {
+	return &Board{
+		cookieStore:    opt.CookieStore,
+		store:          opt.Store,
+		boards:         opt.Store.Boards(),
+		threads:        opt.Store.Threads(),
+		messages:       opt.Store.Messages(),
+		posters:        newPosters(opt),
+		readYourWrites: opt.ReadYourWrites,
+		logger:         log15.New("module", "handler", "handler", "board"),
+	}
+}
+
+func (b *Board) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// sess is nil for guests.
+	sess, err := b.cookieStore.Get(r, "user")
+	if err != nil || sess.IsNew {
+		sess = nil
+	} else {
+		r = r.WithContext(readYourWrites(r.Context(), sess, b.readYourWrites))
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/boards"), "/")
+	if path == "" {
+		if sess == nil {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		if r.Method != "GET" {
+			http.NotFound(w, r)
+			return
+		}
+		b.list(sess, w, r)
+		return
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	board, err := b.boards.FindBySlug(r.Context(), parts[0])
+	if err == model.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		b.logger.Error("Find board error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if sess == nil && !board.GuestsAllowed() {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "archive" {
+		if r.Method != "GET" {
+			http.NotFound(w, r)
+			return
+		}
+		b.archive(sess, board, w, r)
+		return
+	}
+	if len(parts) == 2 {
+		b.serveThread(sess, board, parts[1], w, r)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		b.index(sess, board, w, r)
+	case "POST":
+		b.startThread(sess, board, w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (b *Board) serveThread(sess *gsess.Session, board *model.Board, id string, w http.ResponseWriter, r *http.Request) {
+	threadID, err := strconv.Atoi(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	thread, err := b.threads.Find(r.Context(), threadID)
+	if err == nil && thread.BoardID != board.ID {
+		err = model.ErrNotFound
+	}
+	if err == model.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		b.logger.Error("Find thread error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		b.showThread(sess, board, thread, w, r)
+	case "POST":
+		b.reply(sess, board, thread, w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (b *Board) list(sess *gsess.Session, w http.ResponseWriter, r *http.Request) {
+	boards, err := b.boards.FindAll(r.Context())
+	if err != nil {
+		b.logger.Error("Find all boards error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	data := &struct {
+		Name   string
+		Boards []*model.Board
+	}{
+		Name:   sessionName(sess),
+		Boards: boards,
+	}
+	b.render(w, "boards.html", data)
+}
+
+func (b *Board) index(sess *gsess.Session, board *model.Board, w http.ResponseWriter, r *http.Request) {
+	threads, err := b.threads.FindByBoard(r.Context(), board.ID)
+	if err != nil {
+		b.logger.Error("Find threads error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	data := &struct {
+		Name      string
+		Board     *model.Board
+		CanPost   bool
+		Threads   []*model.Thread
+		CsrfToken string
+	}{
+		Name:      sessionName(sess),
+		Board:     board,
+		CanPost:   board.CanPost(sessionRole(sess)),
+		Threads:   threads,
+		CsrfToken: nosurf.Token(r),
+	}
+	b.render(w, "board.html", data)
+}
+
+// archiveDateFormat is the layout of the dates searched in the archive.
+const archiveDateFormat = "2006-01-02"
+
+func (b *Board) archive(sess *gsess.Session, board *model.Board, w http.ResponseWriter, r *http.Request) {
+	q := model.ArchiveQuery{Subject: strings.TrimSpace(r.FormValue("q"))}
+	since, until := r.FormValue("since"), r.FormValue("until")
+	if since != "" {
+		t, err := time.Parse(archiveDateFormat, since)
+		if err != nil {
+			http.Error(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+		q.Since = t.Format(model.TimeFormat)
+	}
+	if until != "" {
+		t, err := time.Parse(archiveDateFormat, until)
+		if err != nil {
+			http.Error(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+		// Until is inclusive, so the search ends at the next day.
+		q.Until = t.AddDate(0, 0, 1).Format(model.TimeFormat)
+	}
+	threads, err := b.threads.FindArchived(r.Context(), board.ID, q)
+	if err != nil {
+		b.logger.Error("Find archived threads error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	data := &struct {
+		Name    string
+		Board   *model.Board
+		Subject string
+		Since   string
+		Until   string
+		Threads []*model.Thread
+	}{
+		Name:    sessionName(sess),
+		Board:   board,
+		Subject: q.Subject,
+		Since:   since,
+		Until:   until,
+		Threads: threads,
+	}
+	b.render(w, "archive.html", data)
+}
+
+func (b *Board) showThread(sess *gsess.Session, board *model.Board, thread *model.Thread, w http.ResponseWriter, r *http.Request) {
+	msgs, err := b.messages.FindByThread(r.Context(), thread.ID)
+	if err != nil {
+		b.logger.Error("Find messages error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	data := &struct {
+		Name      string
+		Board     *model.Board
+		Thread    *model.Thread
+		CanPost   bool
+		Moderator bool
+		Posts     []*post
+		CsrfToken string
+	}{
+		Name:      sessionName(sess),
+		Board:     board,
+		Thread:    thread,
+		CanPost:   board.CanPost(sessionRole(sess)),
+		Moderator: sessionRole(sess) == model.RoleAdmin,
+		Posts:     newPosts(msgs, ""),
+		CsrfToken: nosurf.Token(r),
+	}
+	b.render(w, "thread.html", data)
+}
+
+func (b *Board) render(w http.ResponseWriter, name string, data interface{}) {
+	tmpl, err := template.ParseFiles(filepath.Join("server", "view", name))
+	if err != nil {
+		b.logger.Error("Parse template error", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		b.logger.Error("Template execute error", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (b *Board) startThread(sess *gsess.Session, board *model.Board, w http.ResponseWriter, r *http.Request) {
+	thread := &model.Thread{
+		BoardID: board.ID,
+		Subject: strings.TrimSpace(r.FormValue("subject")),
+	}
+	msg := &model.Message{
+		UserID:    sessionUserID(sess),
+		Message:   r.FormValue("message"),
+		CreatedAt: time.Now().Format(model.TimeFormat),
+	}
+	if code, reason := checkPost(board, sessionRole(sess), msg.Message); code != http.StatusOK {
+		http.Error(w, reason, code)
+		return
+	}
+	if code, reason := checkName(r.FormValue("name")); code != http.StatusOK {
+		http.Error(w, reason, code)
+		return
+	}
+	b.posters.identify(r, board, msg, r.FormValue("name"))
+	if code, reason := checkSubject(thread.Subject); code != http.StatusOK {
+		http.Error(w, reason, code)
+		return
+	}
+	if err := model.StartThread(r.Context(), b.store, thread, msg); err != nil {
+		b.logger.Error("Start thread error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	b.posted(sess, w, r, threadURL(board.Slug, thread.ID))
+}
+
+func (b *Board) reply(sess *gsess.Session, board *model.Board, thread *model.Thread, w http.ResponseWriter, r *http.Request) {
+	msg := &model.Message{
+		ThreadID:  thread.ID,
+		UserID:    sessionUserID(sess),
+		Message:   r.FormValue("message"),
+		CreatedAt: time.Now().Format(model.TimeFormat),
+	}
+	if code, reason := checkPost(board, sessionRole(sess), msg.Message); code != http.StatusOK {
+		http.Error(w, reason, code)
+		return
+	}
+	if code, reason := checkName(r.FormValue("name")); code != http.StatusOK {
+		http.Error(w, reason, code)
+		return
+	}
+	b.posters.identify(r, board, msg, r.FormValue("name"))
+	err := model.Reply(r.Context(), b.store, msg, isSage(r))
+	if err == model.ErrThreadClosed {
+		http.Error(w, "This thread is closed", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		b.logger.Error("Reply error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	b.posted(sess, w, r, threadURL(board.Slug, thread.ID))
+}
+
+// posted marks the session as written and redirects to location. Guests have
+// no session to mark.
+func (b *Board) posted(sess *gsess.Session, w http.ResponseWriter, r *http.Request, location string) {
+	if sess == nil {
+		http.Redirect(w, r, location, http.StatusFound)
+		return
+	}
+	markWritten(sess)
+	if err := sess.Save(r, w); err != nil {
+		b.logger.Error("Save cookie store error", "err", err)
+	}
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// isSage reports whether a reply should not bump its thread, either from the
+// sage checkbox or from "sage" in the classic mail field.
+func isSage(r *http.Request) bool {
+	return r.FormValue("sage") != "" || strings.EqualFold(strings.TrimSpace(r.FormValue("mail")), "sage")
+}
+
+// maxSubjectLength is the length of threads.subject.
+const maxSubjectLength = 128
+
+// checkSubject returns the status code and reason to reject a thread
+// subject, or http.StatusOK.
+func checkSubject(subject string) (int, string) {
+	switch {
+	case subject == "":
+		return http.StatusBadRequest, "Subject is empty"
+	case utf8.RuneCountInString(subject) > maxSubjectLength:
+		return http.StatusBadRequest, "Subject is too long"
+	}
+	return http.StatusOK, ""
+}
+
+// checkPost returns the status code and reason to reject a post of message
+// on board by a user with role, or http.StatusOK.
+func checkPost(board *model.Board, role, message string) (int, string) {
+	switch {
+	case board.ReadOnly:
+		return http.StatusForbidden, "This board is read-only"
+	case !board.CanPost(role):
+		return http.StatusForbidden, "You may not post on this board"
+	case strings.TrimSpace(message) == "":
+		return http.StatusBadRequest, "Message is empty"
+	case board.MaxMessageLength > 0 && utf8.RuneCountInString(message) > board.MaxMessageLength:
+		return http.StatusBadRequest, "Message is too long"
+	}
+	return http.StatusOK, ""
+}
+
+// sessionRole returns the role of the user of sess, which sessions created
+// before roles existed do not have, or RoleGuest when sess is nil.
+func sessionRole(sess *gsess.Session) string {
+	if sess == nil {
+		return model.RoleGuest
+	}
+	if role, ok := sess.Values["role"].(string); ok {
+		return role
+	}
+	return model.RoleMember
+}
+
+// sessionName returns the name of the user of sess, or "" when sess is nil.
+func sessionName(sess *gsess.Session) string {
+	if sess == nil {
+		return ""
+	}
+	return sess.Values["name"].(string)
+}
+
+// sessionVerified reports whether the user of sess verified its email. Sessions
+// of stores which do not tell are taken as verified.
+func sessionVerified(sess *gsess.Session) bool {
+	verified, ok := sess.Values["verified"].(bool)
+	return !ok || verified
+}
+
+// sessionUserID returns the ID of the user of sess, or 0 when sess is nil.
+func sessionUserID(sess *gsess.Session) int {
+	if sess == nil {
+		return 0
+	}
+	return sess.Values["id"].(int)
+}
+
+var _ http.Handler = (*Board)(nil)
@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	gsess "github.com/gorilla/sessions"
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+// maxCGIBodySize limits the size of the forms posted to bbs.cgi.
+const maxCGIBodySize = 64 << 10
+
+// Compat serves the boards to 2ch-style browsers: the thread list at
+// /{slug}/subject.txt, the threads at /{slug}/dat/{id}.dat and posting at
+// /test/bbs.cgi. Users sign in with their session cookie or HTTP basic
+// authentication, which boards allowing guests do not require. bbs.cgi takes
+// the session cookie only from pages of this site, since it has no CSRF
+// token.
+type Compat struct {
+	cookieStore gsess.Store
+	store       model.Store
+	boards      model.BoardRepository
+	threads     model.ThreadRepository
+	messages    model.MessageRepository
+	signIn      *signIn
+	basicAuth   *basicAuthCache
+	posters     *posters
+	// requireVerified treats users with unverified emails as guests.
+	requireVerified bool
+	logger          log15.Logger
+}
+
+// NewCompat ...
+func NewCompat(opt Option) *Compat {
+	logger := log15.New("module", "handler", "handler", "compat")
+	return &Compat{
+		cookieStore: opt.CookieStore,
+		store:       opt.Store,
+		boards:      opt.Store.Boards(),
+		threads:     opt.Store.Threads(),
+		messages:    opt.Store.Messages(),
+		signIn:      newSignIn(opt, logger),
+		basicAuth:   newBasicAuthCache(),
+		posters:     newPosters(opt),
+		logger:      logger,
+
+		requireVerified: opt.RequireVerifiedEmail,
+	}
+}
+
+// Or returns a handler which serves the 2ch-style paths with c and every
+// other path with next.
+func (c *Compat) Or(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := compatPath(r.URL.Path); ok {
+			c.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compatPath splits /{slug}/subject.txt and /{slug}/dat/{id}.dat into the
+// board slug and the file name.
+func compatPath(path string) (string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "subject.txt":
+		return parts[0], parts[1], true
+	case len(parts) == 3 && parts[0] != "" && parts[1] == "dat" && strings.HasSuffix(parts[2], ".dat"):
+		return parts[0], parts[2], true
+	}
+	return "", "", false
+}
+
+func (c *Compat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cgi := r.URL.Path == "/test/bbs.cgi"
+	// user is nil for guests.
+	user, err := c.authenticate(r, !cgi || sameOrigin(r))
+	if terr, ok := err.(*throttledError); ok {
+		w.Header().Set("Retry-After", terr.retryAfter())
+		http.Error(w, terr.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		c.logger.Error("Authenticate error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if cgi {
+		if r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+		c.post(user, w, r)
+		return
+	}
+	slug, file, ok := compatPath(r.URL.Path)
+	if !ok || (r.Method != "GET" && r.Method != "HEAD") {
+		http.NotFound(w, r)
+		return
+	}
+	board, err := c.boards.FindBySlug(r.Context(), slug)
+	if err == model.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		c.logger.Error("Find board error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if user == nil && !board.GuestsAllowed() {
+		unauthorized(w)
+		return
+	}
+	if file == "subject.txt" {
+		c.subject(board, w, r)
+		return
+	}
+	c.dat(board, strings.TrimSuffix(file, ".dat"), w, r)
+}
+
+// unauthorized asks the client to sign in with basic authentication.
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="bbs-sample"`)
+	http.Error(w, "Sign in with your email and password", http.StatusUnauthorized)
+}
+
+// authenticate returns the user signed in by the session cookie, when
+// cookies is true, or by basic authentication, or nil. It returns a
+// *throttledError while basic authentication is throttled.
+func (c *Compat) authenticate(r *http.Request, cookies bool) (*model.User, error) {
+	if sess, err := c.cookieStore.Get(r, "user"); cookies && err == nil && !sess.IsNew {
+		return &model.User{
+			ID:   sess.Values["id"].(int),
+			Name: sess.Values["name"].(string),
+			Role: sessionRole(sess),
+		}, nil
+	}
+	email, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	now := time.Now()
+	user := c.basicAuth.get(email, password, now)
+	if user == nil {
+		var err error
+		user, err = c.signIn.authenticate(r, email, password)
+		if err == model.ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.basicAuth.put(email, password, user, now)
+	}
+	// Basic authentication has no room for a second factor, so users who
+	// need one post with their session cookie.
+	if c.requireVerified && !user.EmailVerified() || c.signIn.twoFactor.required(user) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// sameOrigin reports whether r comes from a page of this site, by its Origin
+// header or, without one, its Referer.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" || origin == "null" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+func (c *Compat) subject(board *model.Board, w http.ResponseWriter, r *http.Request) {
+	threads, err := c.threads.FindByBoard(r.Context(), board.ID)
+	if err != nil {
+		c.logger.Error("Find threads error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	var modTime time.Time
+	for _, t := range threads {
+		if posted := parseTime(t.LastPostedAt); posted.After(modTime) {
+			modTime = posted
+		}
+	}
+	serveText(w, r, "subject.txt", subjectText(threads), requestCharset(r), modTime)
+}
+
+func (c *Compat) dat(board *model.Board, id string, w http.ResponseWriter, r *http.Request) {
+	threadID, err := strconv.Atoi(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	thread, err := c.threads.Find(r.Context(), threadID)
+	if err == nil && thread.BoardID != board.ID {
+		err = model.ErrNotFound
+	}
+	if err == model.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		c.logger.Error("Find thread error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	msgs, err := c.messages.FindByThread(r.Context(), thread.ID)
+	if err != nil {
+		c.logger.Error("Find messages error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	serveText(w, r, id+".dat", datText(thread, msgs), requestCharset(r), parseTime(thread.LastPostedAt))
+}
+
+// post serves bbs.cgi, which starts a thread on the board bbs when key is
+// empty and replies to the thread key otherwise. The form is decoded from
+// Shift_JIS unless it is sent as UTF-8. FROM is the name field of anonymous
+// boards. user is nil for guests.
+func (c *Compat) post(user *model.User, w http.ResponseWriter, r *http.Request) {
+	charset := requestCharset(r)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCGIBodySize))
+	if err != nil {
+		c.cgiResult(w, charset, "The form is too large")
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		c.cgiResult(w, charset, "Invalid form")
+		return
+	}
+	field := func(name string) string {
+		return decodeText(form.Get(name), charset)
+	}
+	board, err := c.boards.FindBySlug(r.Context(), field("bbs"))
+	if err == model.ErrNotFound {
+		c.cgiResult(w, charset, "No such board")
+		return
+	}
+	if err != nil {
+		c.logger.Error("Find board error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if user == nil && !board.GuestsAllowed() {
+		unauthorized(w)
+		return
+	}
+	msg := &model.Message{
+		Message:   field("MESSAGE"),
+		CreatedAt: time.Now().Format(model.TimeFormat),
+	}
+	role := model.RoleGuest
+	if user != nil {
+		msg.UserID, role = user.ID, user.Role
+	}
+	if code, reason := checkPost(board, role, msg.Message); code != http.StatusOK {
+		c.cgiResult(w, charset, reason)
+		return
+	}
+	if code, reason := checkName(field("FROM")); code != http.StatusOK {
+		c.cgiResult(w, charset, reason)
+		return
+	}
+	c.posters.identify(r, board, msg, field("FROM"))
+	var reason string
+	if key := field("key"); key == "" {
+		reason, err = c.startThread(r, board, field("subject"), msg)
+	} else {
+		sage := strings.EqualFold(strings.TrimSpace(field("mail")), "sage")
+		reason, err = c.reply(r, board, key, msg, sage)
+	}
+	if err != nil {
+		c.logger.Error("Post error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	c.cgiResult(w, charset, reason)
+}
+
+// startThread returns the reason to reject the thread, or an error.
+func (c *Compat) startThread(r *http.Request, board *model.Board, subject string, msg *model.Message) (string, error) {
+	thread := &model.Thread{BoardID: board.ID, Subject: strings.TrimSpace(subject)}
+	if code, reason := checkSubject(thread.Subject); code != http.StatusOK {
+		return reason, nil
+	}
+	return "", model.StartThread(r.Context(), c.store, thread, msg)
+}
+
+// reply returns the reason to reject the reply, or an error.
+func (c *Compat) reply(r *http.Request, board *model.Board, key string, msg *model.Message, sage bool) (string, error) {
+	threadID, err := strconv.Atoi(key)
+	if err != nil {
+		return "No such thread", nil
+	}
+	thread, err := c.threads.Find(r.Context(), threadID)
+	if err == model.ErrNotFound || (err == nil && thread.BoardID != board.ID) {
+		return "No such thread", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	msg.ThreadID = thread.ID
+	err = model.Reply(r.Context(), c.store, msg, sage)
+	if err == model.ErrThreadClosed {
+		return "This thread is closed", nil
+	}
+	return "", err
+}
+
+// cgiResult responds like bbs.cgi, whose clients read the outcome from the
+// title: success when reason is empty and an error with reason otherwise.
+func (c *Compat) cgiResult(w http.ResponseWriter, charset, reason string) {
+	title, body := "書きこみました。", "書きこみが終わりました。"
+	if reason != "" {
+		title, body = "ＥＲＲＯＲ！", "ＥＲＲＯＲ："+reason
+	}
+	page := "<html><head><title>" + title + "</title>" +
+		`<meta http-equiv="Content-Type" content="text/html; charset=` + charset + `"></head>` +
+		"<body>" + template.HTMLEscapeString(body) + "</body></html>\n"
+	b, err := encodeText(page, charset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset="+charset)
+	w.Write(b)
+}
+
+var _ http.Handler = (*Compat)(nil)
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		referer string
+		want    bool
+	}{
+		{"same origin", "http://bbs.example.com", "", true},
+		{"same referer", "", "http://bbs.example.com/test/read.cgi/1", true},
+		{"origin wins over referer", "http://evil.example.com", "http://bbs.example.com/", false},
+		{"other host", "http://evil.example.com", "", false},
+		{"other port", "http://bbs.example.com:8080", "", false},
+		{"subdomain", "http://www.bbs.example.com", "", false},
+		{"host as path", "http://evil.example.com/bbs.example.com", "", false},
+		{"null origin", "null", "", false},
+		{"no header", "", "", false},
+		{"relative referer", "", "/test/bbs.cgi", false},
+		{"not a url", "http://bbs.example.com:port", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "http://bbs.example.com/test/bbs.cgi", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			if got := sameOrigin(r); got != tt.want {
+				t.Errorf("sameOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
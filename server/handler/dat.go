@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+const (
+	// charsetShiftJIS is the charset 2ch-style browsers expect by default.
+	charsetShiftJIS = "Shift_JIS"
+	// charsetUTF8 is offered with ?charset=utf-8.
+	charsetUTF8 = "UTF-8"
+)
+
+// datWeekdays are the days of the week as written in dat dates.
+var datWeekdays = [...]string{"日", "月", "火", "水", "木", "金", "土"}
+
+// requestCharset returns the charset selected by the charset parameter of
+// r's Content-Type or query, Shift_JIS by default.
+func requestCharset(r *http.Request) string {
+	charset := r.URL.Query().Get("charset")
+	if i := strings.Index(r.Header.Get("Content-Type"), "charset="); i >= 0 {
+		charset = strings.Trim(r.Header.Get("Content-Type")[i+len("charset="):], `" `)
+	}
+	switch strings.ToLower(strings.Replace(charset, "_", "-", -1)) {
+	case "utf-8", "utf8":
+		return charsetUTF8
+	default:
+		return charsetShiftJIS
+	}
+}
+
+// encodeText encodes s in charset. Characters Shift_JIS lacks are written as
+// HTML character references, as 2ch does.
+func encodeText(s, charset string) ([]byte, error) {
+	if charset == charsetUTF8 {
+		return []byte(s), nil
+	}
+	encoder := encoding.HTMLEscapeUnsupported(japanese.ShiftJIS.NewEncoder())
+	b, _, err := transform.Bytes(encoder, []byte(s))
+	return b, err
+}
+
+// decodeText decodes s from charset, keeping s when it is not valid.
+func decodeText(s, charset string) string {
+	if charset == charsetUTF8 {
+		return s
+	}
+	b, err := io.ReadAll(transform.NewReader(strings.NewReader(s), japanese.ShiftJIS.NewDecoder()))
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+// subjectText returns the threads in the subject.txt format, one
+// "{id}.dat<>{subject} ({posts})" line per thread.
+func subjectText(threads []*model.Thread) string {
+	var b strings.Builder
+	for _, t := range threads {
+		fmt.Fprintf(&b, "%d.dat<>%s (%d)\n", t.ID, datField(t.Subject), t.PostCount)
+	}
+	return b.String()
+}
+
+// datText returns the messages of thread in the dat format, one
+// "name<>mail<>date<>body<>subject" line per post. Only the first line has
+// the subject, and the mail field is "sage" for replies which did not bump
+// the thread.
+func datText(thread *model.Thread, msgs []*model.Message) string {
+	var b strings.Builder
+	for i, msg := range msgs {
+		subject := ""
+		if i == 0 {
+			subject = datField(thread.Subject)
+		}
+		date := datDate(msg.CreatedAt)
+		if msg.PosterID != "" {
+			date += " ID:" + msg.PosterID
+		}
+		mail := ""
+		if msg.Sage {
+			mail = "sage"
+		}
+		fmt.Fprintf(&b, "%s<>%s<>%s<>%s<>%s\n", datName(msg), mail, datField(date), datBody(msg.Message), subject)
+	}
+	return b.String()
+}
+
+// datName returns the name field of msg. Tripcodes are set off from the bold
+// name as 2ch does.
+func datName(msg *model.Message) string {
+	if msg.System() {
+		return "System"
+	}
+	name := datField(msg.DisplayName())
+	if msg.Tripcode != "" {
+		name += " </b>◆" + datField(msg.Tripcode) + " <b>"
+	}
+	return name
+}
+
+// datEscaper escapes the characters 2ch escapes in dat fields.
+var datEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// datField escapes s for a single line dat field.
+func datField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(datEscaper.Replace(s))
+}
+
+// datBody escapes a message for a dat line, where line breaks are <br>.
+func datBody(s string) string {
+	s = strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\r", "\n", -1)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = datEscaper.Replace(line)
+	}
+	return " " + strings.Join(lines, " <br> ") + " "
+}
+
+// datDate formats a TimeFormat time like "2006/01/02(月) 15:04:05".
+func datDate(s string) string {
+	t := parseTime(s)
+	if t.IsZero() {
+		return s
+	}
+	return fmt.Sprintf("%s(%s) %s", t.Format("2006/01/02"), datWeekdays[t.Weekday()], t.Format("15:04:05"))
+}
+
+// serveText serves s in charset. Range and If-Modified-Since requests are
+// answered against modTime, so clients can fetch only what was appended.
+func serveText(w http.ResponseWriter, r *http.Request, name, s, charset string, modTime time.Time) {
+	b, err := encodeText(s, charset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset="+charset)
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(b))
+}
+
+// parseTime parses a TimeFormat time, or returns the zero time.
+func parseTime(s string) time.Time {
+	t, err := time.ParseInLocation(model.TimeFormat, s, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
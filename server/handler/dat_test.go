@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// checkGolden compares got with the golden file name in testdata, or
+// rewrites the file with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s =\n%s\nwant\n%s", name, got, want)
+	}
+}
+
+var (
+	testThread = &model.Thread{ID: 1700000000, Subject: `質問スレ <初心者> & "歓迎" 😀`, PostCount: 4}
+	testPosts  = []*model.Message{
+		{PostNo: 1, UserID: 1, UserName: "alice", PosterID: "Ab3dEf9h", Message: "はじめまして。\r\n<b>太字</b> & 改行\n\nです", CreatedAt: "2024-01-01 09:00:00"},
+		{PostNo: 2, UserID: 2, UserName: "bob", Name: "名無しさん", Tripcode: "Ig9vYCA1Gw", PosterID: "Zx8yWv7u", Message: ">>1 ようこそ", Sage: true, CreatedAt: "2024-01-02 23:59:59"},
+		{PostNo: 3, IPHash: "0123456789abcdef", Name: "ゲスト", PosterID: "Qr5sTu6v", Message: "絵文字 😀 は参照になる", CreatedAt: "2024-01-06 12:00:00"},
+		{PostNo: 4, Message: "This thread has reached 4 posts and is closed. Please start a new thread.", CreatedAt: "2024-01-07 00:00:00"},
+	}
+)
+
+func TestSubjectText(t *testing.T) {
+	threads := []*model.Thread{
+		testThread,
+		{ID: 1600000000, Subject: "雑談\nスレ", PostCount: 1},
+	}
+	for _, tt := range []struct {
+		charset string
+		golden  string
+	}{
+		{charsetShiftJIS, "subject.sjis.txt"},
+		{charsetUTF8, "subject.utf8.txt"},
+	} {
+		t.Run(tt.charset, func(t *testing.T) {
+			b, err := encodeText(subjectText(threads), tt.charset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, b)
+		})
+	}
+}
+
+func TestDatText(t *testing.T) {
+	for _, tt := range []struct {
+		charset string
+		golden  string
+	}{
+		{charsetShiftJIS, "thread.sjis.dat"},
+		{charsetUTF8, "thread.utf8.dat"},
+	} {
+		t.Run(tt.charset, func(t *testing.T) {
+			b, err := encodeText(datText(testThread, testPosts), tt.charset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, b)
+		})
+	}
+}
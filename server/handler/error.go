@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"path/filepath"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// retryAfter is the Retry-After header sent with 503 responses, in seconds.
+const retryAfter = "5"
+
+// statusCode returns the HTTP status code to respond with for err.
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, database.ErrConnNotExist), errors.Is(err, database.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError responds with the status code for err. A maintenance page is
+// rendered instead of the error message while the database is unavailable.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code := statusCode(err)
+	if code != http.StatusServiceUnavailable {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	tmpl, terr := template.ParseFiles(filepath.Join("server", "view", "unavailable.html"))
+	if terr != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Retry-After", retryAfter)
+	w.WriteHeader(code)
+	tmpl.Execute(w, r.URL.Path)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{database.ErrTimeout, http.StatusGatewayTimeout},
+		{fmt.Errorf("find messages: %w", database.ErrTimeout), http.StatusGatewayTimeout},
+		{database.ErrUnavailable, http.StatusServiceUnavailable},
+		{fmt.Errorf("find messages: %w", database.ErrUnavailable), http.StatusServiceUnavailable},
+		{database.ErrConnNotExist, http.StatusServiceUnavailable},
+		{errors.New("fail"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := statusCode(tt.err); got != tt.want {
+			t.Errorf("statusCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWriteErrorTimeout(t *testing.T) {
+	db := database.NewSQLite(database.Options{Path: filepath.Join(t.TempDir(), "bbs.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// The query never ends, so its rows fail at the deadline.
+	rows, err := db.QueryContext(ctx, `WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM n) SELECT x FROM n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for err == nil && rows.Next() {
+		err = rows.Scan(&n)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != database.ErrTimeout {
+		t.Fatalf("error at the deadline = %v, want ErrTimeout", err)
+	}
+
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/bbs", nil), err)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("writeError() status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+}
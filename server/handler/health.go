@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// Health reports whether the server can serve requests.
+type Health struct {
+	dbStatus func() database.Status
+}
+
+// NewHealth ...
+func NewHealth(opt Option) *Health {
+	return &Health{
+		dbStatus: opt.DBStatus,
+	}
+}
+
+type healthResponse struct {
+	Status   string          `json:"status"`
+	Database *databaseHealth `json:"database,omitempty"`
+}
+
+type databaseHealth struct {
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+}
+
+// ServeHTTP responds with 200 while the database is up and with 503 otherwise.
+// The database error is left out, since anyone may ask; the Supervisor logs it.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res := healthResponse{Status: "ok"}
+	code := http.StatusOK
+	if h.dbStatus != nil {
+		status := h.dbStatus()
+		res.Database = &databaseHealth{
+			State: status.State.String(),
+			Since: status.Since,
+		}
+		if status.State != database.StateUp {
+			res.Status = "degraded"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+var _ http.Handler = (*Health)(nil)
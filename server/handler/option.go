@@ -1,12 +1,42 @@
 package handler
 
 import (
+	"time"
+
 	"github.com/gorilla/sessions"
 	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+	"github.com/seka/bbs-sample/mailer"
+	"github.com/seka/bbs-sample/model"
 )
 
 // Option ...
 type Option struct {
-	CookieStore sessions.Store
-	DB          database.Database
+	CookieStore    sessions.Store
+	Store          model.Store
+	ReadYourWrites time.Duration
+	// Passwords hashes new passwords and the ones rehashed on sign in.
+	Passwords cryptoutil.PasswordHasher
+	// Throttle limits the failed sign ins.
+	Throttle *model.LoginThrottle
+
+	// DBStatus reports the state of the database, nil when there is none.
+	DBStatus func() database.Status
+
+	// Mailer sends the email verification and password reset links, which
+	// are signed with TokenSecret and point at BaseURL.
+	Mailer      mailer.Mailer
+	TokenSecret []byte
+	BaseURL     string
+	// RequireVerifiedEmail refuses to sign in users until they verify their
+	// email.
+	RequireVerifiedEmail bool
+	// TwoFactorRoles must sign in with a TOTP or recovery code; they enroll
+	// on their next sign in.
+	TwoFactorRoles model.TwoFactorRoles
+
+	// PosterSecret keys the poster IDs and address hashes of messages.
+	PosterSecret []byte
+	// TrustForwardedFor takes the client address from X-Forwarded-For.
+	TrustForwardedFor bool
 }
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+// post is a message as shown on a page, with its anchors turned into links.
+type post struct {
+	*model.Message
+	// URL links to the post, or is empty for messages without a thread.
+	URL       string
+	Body      template.HTML
+	Backlinks []int
+}
+
+// threadURL returns the URL of the thread threadID on the board slug.
+func threadURL(slug string, threadID int) string {
+	return fmt.Sprintf("/boards/%s/%d", slug, threadID)
+}
+
+// newPosts returns the messages of the thread at base as posts with links to
+// the posts quoting them. base is empty when they are shown on their thread.
+func newPosts(msgs []*model.Message, base string) []*post {
+	backlinks := model.Backlinks(msgs)
+	posts := make([]*post, 0, len(msgs))
+	for _, msg := range msgs {
+		posts = append(posts, newPost(msg, base, backlinks[msg.PostNo]))
+	}
+	return posts
+}
+
+// newFeedPosts returns messages of any thread as posts linking to their
+// threads.
+func newFeedPosts(msgs []*model.Message) []*post {
+	posts := make([]*post, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.ThreadID == 0 {
+			posts = append(posts, &post{Message: msg, Body: template.HTML(template.HTMLEscapeString(msg.Message))})
+			continue
+		}
+		posts = append(posts, newPost(msg, threadURL(msg.BoardSlug, msg.ThreadID), nil))
+	}
+	return posts
+}
+
+func newPost(msg *model.Message, base string, backlinks []int) *post {
+	return &post{
+		Message:   msg,
+		URL:       fmt.Sprintf("%s#p%d", base, msg.PostNo),
+		Body:      formatMessage(msg.Message, base),
+		Backlinks: backlinks,
+	}
+}
+
+// formatMessage escapes message and links its anchors to the posts of the
+// thread at base.
+func formatMessage(message, base string) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, anchor := range model.ParseAnchors(message) {
+		b.WriteString(template.HTMLEscapeString(message[last:anchor.Start]))
+		fmt.Fprintf(&b, `<a href="%s#p%d" class="anchor">%s</a>`,
+			template.HTMLEscapeString(base), anchor.From, template.HTMLEscapeString(message[anchor.Start:anchor.End]))
+		last = anchor.End
+	}
+	b.WriteString(template.HTMLEscapeString(message[last:]))
+	return template.HTML(b.String())
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/seka/bbs-sample/internal/httputil"
+	"github.com/seka/bbs-sample/internal/tripcode"
+	"github.com/seka/bbs-sample/model"
+)
+
+// maxPosterNameLength is the length of messages.name.
+const maxPosterNameLength = 64
+
+// posters identifies the posters of messages by their address without
+// storing it.
+type posters struct {
+	secret            []byte
+	trustForwardedFor bool
+}
+
+func newPosters(opt Option) *posters {
+	return &posters{secret: opt.PosterSecret, trustForwardedFor: opt.TrustForwardedFor}
+}
+
+// identify sets the poster fields of msg, posted on board from r with the
+// name field name.
+func (p *posters) identify(r *http.Request, board *model.Board, msg *model.Message, name string) {
+	ip := httputil.ClientIP(r, p.trustForwardedFor)
+	msg.IPHash = hex.EncodeToString(p.mac(p.secret, "ip", ip))[:16]
+	// The salt rotates daily, so IDs cannot be followed across days.
+	day := msg.CreatedAt
+	if len(day) > len("2006-01-02") {
+		day = day[:len("2006-01-02")]
+	}
+	msg.PosterKey = p.mac(p.mac(p.secret, "salt", day), "id", ip)
+	if !board.Anonymous {
+		return
+	}
+	msg.Name, msg.Tripcode = tripcode.Parse(name)
+	if msg.Name == "" {
+		msg.Name = board.DefaultName
+	}
+}
+
+func (p *posters) mac(key []byte, parts ...string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(strings.Join(parts, "\x00")))
+	return h.Sum(nil)
+}
+
+// checkName returns the status code and reason to reject a name field, or
+// http.StatusOK.
+func checkName(name string) (int, string) {
+	if utf8.RuneCountInString(name) > maxPosterNameLength {
+		return http.StatusBadRequest, "Name is too long"
+	}
+	return http.StatusOK, ""
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	gsess "github.com/gorilla/sessions"
+
+	"github.com/seka/bbs-sample/database"
+)
+
+// markWritten records in sess that its user has just written to the database.
+func markWritten(sess *gsess.Session) {
+	sess.Values["written_at"] = time.Now().UnixNano()
+}
+
+// readYourWrites returns ctx bound to the primary database when the user of
+// sess wrote within window, so that they see their own writes.
+func readYourWrites(ctx context.Context, sess *gsess.Session, window time.Duration) context.Context {
+	writtenAt, ok := sess.Values["written_at"].(int64)
+	if !ok || time.Since(time.Unix(0, writtenAt)) > window {
+		return ctx
+	}
+	return database.WithPrimary(ctx)
+}
@@ -7,23 +7,31 @@ import (
 
 	"github.com/gorilla/sessions"
 	"github.com/inconshreveable/log15"
-	"github.com/seka/bbs-sample/internal/cryptoutil"
 	"github.com/seka/bbs-sample/model"
 )
 
 // Session ...
 type Session struct {
 	cookieStore sessions.Store
-	userModel   *model.UserModel
-	logger      log15.Logger
+	store       model.Store
+	signIn      *signIn
+	mail        *accountMail
+	// requireVerified refuses to sign in users with unverified emails.
+	requireVerified bool
+	logger          log15.Logger
 }
 
 // NewSession ...
 func NewSession(opt Option) *Session {
+	logger := log15.New("module", "handler", "handler", "session")
 	return &Session{
 		cookieStore: opt.CookieStore,
-		userModel:   model.NewUserModel(opt.DB),
-		logger:      log15.New("module", "handler", "handler", "session"),
+		store:       opt.Store,
+		signIn:      newSignIn(opt, logger),
+		mail:        newAccountMail(opt, logger),
+		logger:      logger,
+
+		requireVerified: opt.RequireVerifiedEmail,
 	}
 }
 
@@ -31,8 +39,12 @@ func NewSession(opt Option) *Session {
 func (s *Session) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		s.show(w, r)
+		s.show(w, http.StatusOK, "")
 	case "POST":
+		if r.URL.Path == "/login/2fa" {
+			s.doSecondFactor(w, r)
+			return
+		}
 		if r.FormValue("_method") == "DELETE" {
 			s.doSignout(w, r)
 			return
@@ -45,14 +57,18 @@ func (s *Session) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Session) show(w http.ResponseWriter, r *http.Request) {
+// show renders the sign in form with code and the message of a failed sign
+// in, if any.
+func (s *Session) show(w http.ResponseWriter, code int, message string) {
 	tmpl, err := template.ParseFiles(filepath.Join("server", "view", "index.html"))
 	if err != nil {
 		s.logger.Error("Parse template error", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if err := tmpl.Execute(w, nil); err != nil {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := tmpl.Execute(w, &struct{ Error string }{message}); err != nil {
 		s.logger.Error("Template execute error", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -60,18 +76,28 @@ func (s *Session) show(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Session) doSingin(w http.ResponseWriter, r *http.Request) {
-	user, err := s.userModel.Find(&model.User{
-		Email:    r.FormValue("email"),
-		Password: cryptoutil.GenerateHash(r.FormValue("password")),
-	})
+	user, err := s.signIn.authenticate(r, r.FormValue("email"), r.FormValue("password"))
+	if err == model.ErrNotFound {
+		s.show(w, http.StatusUnauthorized, signinFailedMessage)
+		return
+	}
+	if terr, ok := err.(*throttledError); ok {
+		w.Header().Set("Retry-After", terr.retryAfter())
+		s.show(w, http.StatusTooManyRequests, signinThrottledMessage)
+		return
+	}
 	if err != nil {
-		s.logger.Error("Find user error", "err", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Error("Authenticate error", "err", err)
+		writeError(w, r, err)
 		return
 	}
-	if user.ID == 0 {
-		s.logger.Error("NotFound user", "email", user.Email, "password", user.Password)
-		http.NotFound(w, r)
+	if s.requireVerified && !user.EmailVerified() {
+		s.mail.sendVerification(user)
+		s.show(w, http.StatusForbidden, "Verify your email first. We sent you a new link.")
+		return
+	}
+	if s.signIn.twoFactor.required(user) {
+		s.challenge(w, r, http.StatusOK, user, "", "", "")
 		return
 	}
 	if err := s.saveCookie(w, r, user); err != nil {
@@ -95,8 +121,18 @@ func (s *Session) saveCookie(w http.ResponseWriter, r *http.Request, users *mode
 		s.logger.Error("NewCookieStore error", "err", err)
 		return err
 	}
+	// Every sign in starts a new session, so one planted before it is not
+	// signed in, and the previous session of the browser ends.
+	if !sess.IsNew {
+		if err := s.store.Sessions().Delete(r.Context(), sessionUserID(sess), sess.ID); err != nil {
+			s.logger.Warn("Delete previous session error", "err", err)
+		}
+	}
+	sess.ID = ""
+	sess.Values = map[interface{}]interface{}{}
 	sess.Values["id"] = users.ID
 	sess.Values["name"] = users.Name
+	sess.Values["role"] = users.Role
 	if err := sess.Save(r, w); err != nil {
 		s.logger.Error("Save cookie store error", "err", err)
 		return err
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	gsess "github.com/gorilla/sessions"
+	"github.com/inconshreveable/log15"
+	"github.com/justinas/nosurf"
+
+	"github.com/seka/bbs-sample/model"
+)
+
+// Sessions lists the signed in sessions of the user and revokes them. Admins
+// may manage the sessions of another user with ?user={id}.
+type Sessions struct {
+	cookieStore    gsess.Store
+	sessions       model.SessionRepository
+	readYourWrites time.Duration
+	logger         log15.Logger
+}
+
+// NewSessions ...
+func NewSessions(opt Option) *Sessions {
+	return &Sessions{
+		cookieStore:    opt.CookieStore,
+		sessions:       opt.Store.Sessions(),
+		readYourWrites: opt.ReadYourWrites,
+		logger:         log15.New("module", "handler", "handler", "sessions"),
+	}
+}
+
+func (s *Sessions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.cookieStore.Get(r, "user")
+	if err != nil || sess.IsNew {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	r = r.WithContext(readYourWrites(r.Context(), sess, s.readYourWrites))
+	userID := sessionUserID(sess)
+	if v := r.FormValue("user"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid user", http.StatusBadRequest)
+			return
+		}
+		if id != userID && sessionRole(sess) != model.RoleAdmin {
+			http.Error(w, "Only admins can manage the sessions of other users", http.StatusForbidden)
+			return
+		}
+		userID = id
+	}
+	switch r.Method {
+	case "GET":
+		s.show(sess, userID, w, r)
+	case "POST":
+		s.revoke(sess, userID, w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Sessions) show(sess *gsess.Session, userID int, w http.ResponseWriter, r *http.Request) {
+	rows, err := s.sessions.FindByUser(r.Context(), userID, time.Now().Format(model.TimeFormat))
+	if err != nil {
+		s.logger.Error("Find sessions error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	tmpl, err := template.ParseFiles(filepath.Join("server", "view", "sessions.html"))
+	if err != nil {
+		s.logger.Error("Parse template error", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	type session struct {
+		*model.Session
+		Current bool
+	}
+	data := &struct {
+		Name      string
+		UserID    int
+		Other     bool
+		Sessions  []session
+		CsrfToken string
+	}{
+		Name:      sessionName(sess),
+		UserID:    userID,
+		Other:     userID != sessionUserID(sess),
+		CsrfToken: nosurf.Token(r),
+	}
+	for _, row := range rows {
+		data.Sessions = append(data.Sessions, session{Session: row, Current: row.ID == sess.ID})
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		s.logger.Error("Template execute error", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// revoke deletes the session ?id= of the user, or all of them with ?all=1.
+func (s *Sessions) revoke(sess *gsess.Session, userID int, w http.ResponseWriter, r *http.Request) {
+	id, all := r.FormValue("id"), r.FormValue("all") != ""
+	if id == "" && !all {
+		http.Error(w, "Missing session", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if all {
+		err = s.sessions.DeleteByUserID(r.Context(), userID)
+	} else {
+		err = s.sessions.Delete(r.Context(), userID, id)
+	}
+	if err != nil {
+		s.logger.Error("Revoke sessions error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	s.logger.Info("Revoked sessions", "user_id", userID, "all", all, "by", sessionUserID(sess))
+	if userID == sessionUserID(sess) && (all || id == sess.ID) {
+		sess.Options.MaxAge = -1
+		if err := sess.Save(r, w); err != nil {
+			s.logger.Error("Remove cookie store error", "err", err)
+		}
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	if userID != sessionUserID(sess) {
+		http.Redirect(w, r, "/sessions?user="+strconv.Itoa(userID), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/sessions", http.StatusFound)
+}
+
+var _ http.Handler = (*Sessions)(nil)
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+	"github.com/seka/bbs-sample/internal/httputil"
+	"github.com/seka/bbs-sample/model"
+)
+
+const (
+	// signinFailedMessage is shown for every failed sign in, so that it does
+	// not tell whether the email exists.
+	signinFailedMessage = "Incorrect email or password"
+	// signinThrottledMessage is shown while sign ins are throttled.
+	signinThrottledMessage = "Too many failed sign ins, try again later"
+	// codeFailedMessage is shown for a wrong TOTP or recovery code.
+	codeFailedMessage = "Incorrect code"
+)
+
+// throttledError is returned while the sign ins to an account or from an
+// address are throttled.
+type throttledError struct {
+	wait time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return signinThrottledMessage
+}
+
+// retryAfter returns the Retry-After header of e, in seconds.
+func (e *throttledError) retryAfter() string {
+	return strconv.Itoa(int((e.wait + time.Second - 1) / time.Second))
+}
+
+// signIn checks the passwords and second factors of sign ins under the limits
+// of the throttle.
+type signIn struct {
+	store             model.Store
+	passwords         cryptoutil.PasswordHasher
+	throttle          *model.LoginThrottle
+	twoFactor         *twoFactor
+	trustForwardedFor bool
+	logger            log15.Logger
+}
+
+func newSignIn(opt Option, logger log15.Logger) *signIn {
+	return &signIn{
+		store:             opt.Store,
+		passwords:         opt.Passwords,
+		throttle:          opt.Throttle,
+		twoFactor:         newTwoFactor(opt),
+		trustForwardedFor: opt.TrustForwardedFor,
+		logger:            logger,
+	}
+}
+
+// authenticate returns the user with email and password, model.ErrNotFound
+// when there is none, or a *throttledError without checking the password.
+// Failures are logged without the email.
+func (s *signIn) authenticate(r *http.Request, email, password string) (*model.User, error) {
+	ip := httputil.ClientIP(r, s.trustForwardedFor)
+	now := time.Now()
+	wait, err := s.throttle.Wait(r.Context(), email, ip, now)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		s.logger.Info("Throttled sign in", "ip", ip, "wait", wait)
+		return nil, &throttledError{wait: wait}
+	}
+	user, err := model.Authenticate(r.Context(), s.store, s.passwords, email, password)
+	if err == model.ErrNotFound {
+		s.logger.Info("Sign in failed", "ip", ip)
+		if err := s.throttle.Fail(r.Context(), email, ip, now); err != nil {
+			s.logger.Warn("Count failed sign in error", "err", err)
+		}
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The failures are kept until the second factor is given, or signing in
+	// again would reset the count of wrong codes.
+	if !s.twoFactor.required(user) {
+		if err := s.throttle.Succeed(r.Context(), email); err != nil {
+			s.logger.Warn("Reset failed sign ins error", "err", err)
+		}
+	}
+	return user, nil
+}
+
+// secondFactor runs check, which verifies a code of user, under the limits of
+// the throttle. It returns model.ErrNotFound when check fails, or a
+// *throttledError without running it.
+func (s *signIn) secondFactor(r *http.Request, user *model.User, check func(now time.Time) (bool, error)) error {
+	ip := httputil.ClientIP(r, s.trustForwardedFor)
+	now := time.Now()
+	wait, err := s.throttle.Wait(r.Context(), user.Email, ip, now)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		s.logger.Info("Throttled second factor", "ip", ip, "wait", wait)
+		return &throttledError{wait: wait}
+	}
+	ok, err := check(now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.logger.Info("Second factor failed", "user_id", user.ID, "ip", ip)
+		if err := s.throttle.Fail(r.Context(), user.Email, ip, now); err != nil {
+			s.logger.Warn("Count failed sign in error", "err", err)
+		}
+		return model.ErrNotFound
+	}
+	if err := s.throttle.Succeed(r.Context(), user.Email); err != nil {
+		s.logger.Warn("Reset failed sign ins error", "err", err)
+	}
+	return nil
+}
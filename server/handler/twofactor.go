@@ -0,0 +1,361 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"html/template"
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/justinas/nosurf"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+	"github.com/seka/bbs-sample/internal/qrcode"
+	"github.com/seka/bbs-sample/model"
+)
+
+const (
+	signInTwoFactorPurpose = "sign-in-2fa"
+	enrollTwoFactorPurpose = "enroll-2fa"
+
+	// twoFactorTTL is how long a sign in or an enrolment waits for its code.
+	twoFactorTTL = 10 * time.Minute
+
+	// totpIssuer names the accounts in authenticator apps.
+	totpIssuer = "bbs-sample"
+)
+
+// twoFactor carries sign ins from the password to the code, and enrolments
+// from the QR code to the first code, in signed tokens, so that nothing is
+// stored before the code is given.
+type twoFactor struct {
+	store  model.Store
+	secret []byte
+	roles  model.TwoFactorRoles
+}
+
+func newTwoFactor(opt Option) *twoFactor {
+	return &twoFactor{
+		store:  opt.Store,
+		secret: opt.TokenSecret,
+		roles:  opt.TwoFactorRoles,
+	}
+}
+
+// required reports whether user must sign in with a second factor.
+func (t *twoFactor) required(user *model.User) bool {
+	return user.TwoFactorEnabled() || t.roles.Requires(user.Role)
+}
+
+// twoFactorState is signed into the tokens, so that they stop working once the
+// password or the TOTP secret of the user changes. pending is the secret being
+// enrolled, if any.
+func twoFactorState(user *model.User, pending string) string {
+	return user.Password + "\x00" + user.TOTPSecret + "\x00" + pending
+}
+
+// token returns a token for purpose and the user userID, who is enrolling
+// pending if it is not empty.
+func (t *twoFactor) token(ctx context.Context, purpose string, userID int, pending string) (string, error) {
+	// Sign ins get the user without its password hash, so it is read again.
+	user, err := t.store.Users().Find(database.WithPrimary(ctx), userID)
+	if err != nil {
+		return "", err
+	}
+	return cryptoutil.SignToken(t.secret, purpose, user.ID, time.Now().Add(twoFactorTTL), twoFactorState(user, pending)), nil
+}
+
+// userOfToken returns the user of a token for purpose and pending, or
+// cryptoutil.ErrInvalidToken.
+func (t *twoFactor) userOfToken(ctx context.Context, token, purpose, pending string) (*model.User, error) {
+	return userOfToken(ctx, t.store.Users(), t.secret, token, purpose, func(user *model.User) string {
+		return twoFactorState(user, pending)
+	})
+}
+
+// enroll checks that code is the current code of pending and enrolls user
+// with it, returning the recovery codes.
+func (t *twoFactor) enroll(ctx context.Context, user *model.User, pending, code string, now time.Time) ([]string, bool, error) {
+	step, ok := cryptoutil.VerifyTOTP(pending, code, now)
+	if !ok {
+		return nil, false, nil
+	}
+	codes, err := model.EnrollTwoFactor(ctx, t.store, user.ID, pending, step)
+	if err != nil {
+		return nil, false, err
+	}
+	return codes, true, nil
+}
+
+// enrolment is what the pages which enroll a user show to set up an
+// authenticator app: the secret and the QR code of its otpauth URI.
+type enrolment struct {
+	Secret string
+	QRCode template.URL
+}
+
+func newEnrolment(user *model.User, secret string) (*enrolment, error) {
+	code, err := qrcode.Encode([]byte(cryptoutil.TOTPURI(totpIssuer, user.Email, secret)))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(4, 4)); err != nil {
+		return nil, err
+	}
+	return &enrolment{
+		Secret: secret,
+		QRCode: template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())),
+	}, nil
+}
+
+// challenge renders the second step of a sign in with token. Users who must
+// enroll first are shown pending, or a new secret when it is empty.
+func (s *Session) challenge(w http.ResponseWriter, r *http.Request, code int, user *model.User, token, pending, message string) {
+	data := &struct {
+		Token  string
+		Error  string
+		Enroll *enrolment
+	}{
+		Token: token,
+		Error: message,
+	}
+	if !user.TwoFactorEnabled() {
+		var err error
+		if pending == "" {
+			if pending, err = cryptoutil.NewTOTPSecret(); err != nil {
+				s.logger.Error("New TOTP secret error", "err", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if data.Enroll, err = newEnrolment(user, pending); err != nil {
+			s.logger.Error("Render QR code error", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data.Token == "" {
+		var err error
+		if data.Token, err = s.signIn.twoFactor.token(r.Context(), signInTwoFactorPurpose, user.ID, pending); err != nil {
+			s.logger.Error("Sign token error", "err", err)
+			writeError(w, r, err)
+			return
+		}
+	}
+	renderView(w, s.logger, code, "twofactor.html", data)
+}
+
+// doSecondFactor finishes a sign in with the TOTP or recovery code of the user
+// of ?token=, or enrolls the user with ?secret= and its first code.
+func (s *Session) doSecondFactor(w http.ResponseWriter, r *http.Request) {
+	token, pending, code := r.FormValue("token"), r.FormValue("secret"), r.FormValue("code")
+	user, err := s.signIn.twoFactor.userOfToken(r.Context(), token, signInTwoFactorPurpose, pending)
+	if err == cryptoutil.ErrInvalidToken {
+		s.show(w, http.StatusUnauthorized, "Your sign in expired, sign in again")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Find user error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	var codes []string
+	err = s.signIn.secondFactor(r, user, func(now time.Time) (bool, error) {
+		if user.TwoFactorEnabled() {
+			return model.VerifySecondFactor(r.Context(), s.store, user, code, now)
+		}
+		var ok bool
+		var err error
+		codes, ok, err = s.signIn.twoFactor.enroll(r.Context(), user, pending, code, now)
+		return ok, err
+	})
+	if err == model.ErrNotFound {
+		s.challenge(w, r, http.StatusUnauthorized, user, token, pending, codeFailedMessage)
+		return
+	}
+	if terr, ok := err.(*throttledError); ok {
+		w.Header().Set("Retry-After", terr.retryAfter())
+		s.challenge(w, r, http.StatusTooManyRequests, user, token, pending, signinThrottledMessage)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Second factor error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	if err := s.saveCookie(w, r, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if codes != nil {
+		s.logger.Info("Enrolled in two-factor sign in", "user_id", user.ID)
+		renderView(w, s.logger, http.StatusOK, "recovery_codes.html", &struct {
+			Codes []string
+			Next  string
+		}{codes, "/bbs"})
+		return
+	}
+	http.Redirect(w, r, "/bbs", http.StatusFound)
+}
+
+// twoFactorPage is the data of the page at /account/2fa.
+type twoFactorPage struct {
+	Name      string
+	Enabled   bool
+	Required  bool
+	Codes     int
+	Token     string
+	Enroll    *enrolment
+	Error     string
+	CsrfToken string
+}
+
+// showTwoFactor renders the two-factor settings of user: the recovery codes
+// left or, before enrolment, the secret pending with token.
+func (a *Account) showTwoFactor(w http.ResponseWriter, r *http.Request, code int, user *model.User, token, pending, message string) {
+	page := &twoFactorPage{
+		Name:      user.Name,
+		Enabled:   user.TwoFactorEnabled(),
+		Required:  a.signIn.twoFactor.roles.Requires(user.Role),
+		Token:     token,
+		Error:     message,
+		CsrfToken: nosurf.Token(r),
+	}
+	var err error
+	if page.Enabled {
+		if page.Codes, err = a.store.RecoveryCodes().Count(r.Context(), user.ID); err != nil {
+			a.logger.Error("Count recovery codes error", "err", err)
+			writeError(w, r, err)
+			return
+		}
+		a.render(w, code, "account_2fa.html", page)
+		return
+	}
+	if pending == "" {
+		if pending, err = cryptoutil.NewTOTPSecret(); err != nil {
+			a.logger.Error("New TOTP secret error", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if page.Enroll, err = newEnrolment(user, pending); err != nil {
+		a.logger.Error("Render QR code error", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if page.Token == "" {
+		if page.Token, err = a.signIn.twoFactor.token(r.Context(), enrollTwoFactorPurpose, user.ID, pending); err != nil {
+			a.logger.Error("Sign token error", "err", err)
+			writeError(w, r, err)
+			return
+		}
+	}
+	a.render(w, code, "account_2fa.html", page)
+}
+
+// signedInUser returns the signed in user, or nil after redirecting to the
+// sign in page or writing an error.
+func (a *Account) signedInUser(w http.ResponseWriter, r *http.Request) *model.User {
+	sess, err := a.cookieStore.Get(r, "user")
+	if err != nil || sess.IsNew {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return nil
+	}
+	user, err := a.store.Users().Find(database.WithPrimary(r.Context()), sessionUserID(sess))
+	if err != nil {
+		a.logger.Error("Find user error", "err", err)
+		writeError(w, r, err)
+		return nil
+	}
+	return user
+}
+
+// twoFactor serves /account/2fa, where users enroll, renew their recovery
+// codes and withdraw. Every change needs a current code.
+func (a *Account) twoFactor(w http.ResponseWriter, r *http.Request) {
+	user := a.signedInUser(w, r)
+	if user == nil {
+		return
+	}
+	if r.Method == "GET" {
+		a.showTwoFactor(w, r, http.StatusOK, user, "", "", "")
+		return
+	}
+	token, pending, code := r.FormValue("token"), r.FormValue("secret"), r.FormValue("code")
+	action := r.FormValue("action")
+	switch action {
+	case "enable", "recovery-codes", "disable":
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+	if action == "enable" {
+		tokenUser, err := a.signIn.twoFactor.userOfToken(r.Context(), token, enrollTwoFactorPurpose, pending)
+		if err == cryptoutil.ErrInvalidToken || err == nil && tokenUser.ID != user.ID {
+			a.showTwoFactor(w, r, http.StatusBadRequest, user, "", "", "The page expired, scan the new QR code")
+			return
+		}
+		if err != nil {
+			a.logger.Error("Find user error", "err", err)
+			writeError(w, r, err)
+			return
+		}
+	}
+	if action == "disable" && a.signIn.twoFactor.roles.Requires(user.Role) {
+		a.showTwoFactor(w, r, http.StatusForbidden, user, "", "", "Your role must sign in with two factors")
+		return
+	}
+	var codes []string
+	err := a.signIn.secondFactor(r, user, func(now time.Time) (bool, error) {
+		switch action {
+		case "enable":
+			var ok bool
+			var err error
+			codes, ok, err = a.signIn.twoFactor.enroll(r.Context(), user, pending, code, now)
+			return ok, err
+		}
+		return model.VerifySecondFactor(r.Context(), a.store, user, code, now)
+	})
+	if err == model.ErrNotFound {
+		a.showTwoFactor(w, r, http.StatusUnauthorized, user, token, pending, codeFailedMessage)
+		return
+	}
+	if terr, ok := err.(*throttledError); ok {
+		w.Header().Set("Retry-After", terr.retryAfter())
+		a.showTwoFactor(w, r, http.StatusTooManyRequests, user, token, pending, signinThrottledMessage)
+		return
+	}
+	if err != nil {
+		a.logger.Error("Second factor error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	switch action {
+	case "enable":
+		a.logger.Info("Enrolled in two-factor sign in", "user_id", user.ID)
+	case "recovery-codes":
+		if codes, err = model.RenewRecoveryCodes(r.Context(), a.store, user.ID); err != nil {
+			a.logger.Error("Renew recovery codes error", "err", err)
+			writeError(w, r, err)
+			return
+		}
+		a.logger.Info("Renewed recovery codes", "user_id", user.ID)
+	case "disable":
+		if err := model.DisableTwoFactor(r.Context(), a.store, user.ID); err != nil {
+			a.logger.Error("Disable two-factor error", "err", err)
+			writeError(w, r, err)
+			return
+		}
+		a.logger.Info("Withdrew from two-factor sign in", "user_id", user.ID)
+		http.Redirect(w, r, "/account/2fa", http.StatusFound)
+		return
+	}
+	a.render(w, http.StatusOK, "recovery_codes.html", &struct {
+		Codes []string
+		Next  string
+	}{codes, "/account/2fa"})
+}
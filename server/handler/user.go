@@ -8,6 +8,7 @@ import (
 	"github.com/gorilla/sessions"
 	"github.com/inconshreveable/log15"
 
+	"github.com/seka/bbs-sample/database"
 	"github.com/seka/bbs-sample/internal/cryptoutil"
 	"github.com/seka/bbs-sample/model"
 )
@@ -15,16 +16,21 @@ import (
 // User ...
 type User struct {
 	cookieStore sessions.Store
-	userModel   *model.UserModel
+	store       model.Store
+	passwords   cryptoutil.PasswordHasher
+	mail        *accountMail
 	logger      log15.Logger
 }
 
 // NewUser ...
 func NewUser(opt Option) *User {
+	logger := log15.New("module", "handler", "handler", "user")
 	return &User{
 		cookieStore: opt.CookieStore,
-		userModel:   model.NewUserModel(opt.DB),
-		logger:      log15.New("module", "handler", "handler", "user"),
+		store:       opt.Store,
+		passwords:   opt.Passwords,
+		mail:        newAccountMail(opt, logger),
+		logger:      logger,
 	}
 }
 
@@ -63,15 +69,31 @@ func (u *User) save(w http.ResponseWriter, r *http.Request) {
 		Name:  r.FormValue("name"),
 		Email: r.FormValue("email"),
 	}
-	if exists := u.userModel.Exists(modelUser); exists {
-		http.Error(w, "User already exists", http.StatusInternalServerError)
+	if exists := u.store.Users().Exists(r.Context(), modelUser); exists {
+		http.Error(w, "User already exists", http.StatusConflict)
 		return
 	}
-	modelUser.Password = cryptoutil.GenerateHash(passwd)
-	if err := u.userModel.Save(modelUser); err != nil {
-		u.logger.Error("Save user error", "err", err)
+	hash, err := u.passwords.Hash(passwd)
+	if err == cryptoutil.ErrPasswordTooLong {
+		http.Error(w, "Password is too long", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		u.logger.Error("Hash password error", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	modelUser.Password = hash
+	err = u.store.Users().Save(r.Context(), modelUser)
+	if database.IsDuplicate(err) {
+		http.Error(w, "User already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		u.logger.Error("Save user error", "err", err)
+		writeError(w, r, err)
+		return
+	}
+	u.mail.sendVerification(modelUser)
 	http.Redirect(w, r, "/", http.StatusFound)
 }
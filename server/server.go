@@ -2,52 +2,131 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"expvar"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/inconshreveable/log15"
 
 	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/internal/cryptoutil"
+	"github.com/seka/bbs-sample/mailer"
+	"github.com/seka/bbs-sample/model"
 	"github.com/seka/bbs-sample/server/handler"
 )
 
 // Options ...
 type Options struct {
 	Addr        string
+	DebugAddr   string
 	CookieStore sessions.Store
-	DB          database.Database
+	Store       model.Store
 	CSRF        func(http.Handler) http.Handler
+
+	ReadYourWrites time.Duration
+	DBStatus       func() database.Status
+	Passwords      cryptoutil.PasswordHasher
+	Throttle       *model.LoginThrottle
+
+	Mailer  mailer.Mailer
+	BaseURL string
+	// TokenSecret signs the links sent by email. A random one is made when it
+	// is empty, so the links stop working on restart.
+	TokenSecret          []byte
+	RequireVerifiedEmail bool
+	// TwoFactorRoles must sign in with a second factor.
+	TwoFactorRoles model.TwoFactorRoles
+
+	// PosterSecret keys the poster IDs and address hashes of messages. A
+	// random one is made when it is empty, so IDs change on restart.
+	PosterSecret      []byte
+	TrustForwardedFor bool
 }
 
 // Server ...
 type Server struct {
-	addr        string
-	cookieStore sessions.Store
-	csrf        func(http.Handler) http.Handler
-	db          database.Database
-	server      http.Server
-	logger      log15.Logger
-	started     chan struct{}
-	stopped     chan struct{}
+	addr           string
+	cookieStore    sessions.Store
+	csrf           func(http.Handler) http.Handler
+	store          model.Store
+	readYourWrites time.Duration
+	dbStatus       func() database.Status
+	passwords      cryptoutil.PasswordHasher
+	throttle       *model.LoginThrottle
+	mailer         mailer.Mailer
+	baseURL        string
+	tokenSecret    []byte
+	requireVerify  bool
+	twoFactorRoles model.TwoFactorRoles
+	posterSecret   []byte
+	trustForwarded bool
+	server         http.Server
+	debugServer    *http.Server
+	logger         log15.Logger
+	started        chan struct{}
+	stopped        chan struct{}
 }
 
 // New ...
 func New(opt Options) *Server {
+	var debugServer *http.Server
+	if opt.DebugAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		debugServer = &http.Server{
+			Addr:    opt.DebugAddr,
+			Handler: mux,
+		}
+	}
+	logger := log15.New("module", "server")
+	posterSecret := opt.PosterSecret
+	if len(posterSecret) == 0 {
+		posterSecret = randomSecret()
+		logger.Warn("No poster secret, poster IDs change on restart")
+	}
+	tokenSecret := opt.TokenSecret
+	if len(tokenSecret) == 0 {
+		tokenSecret = randomSecret()
+		logger.Warn("No token secret, the links sent by email stop working on restart")
+	}
 	return &Server{
-		addr:        opt.Addr,
-		cookieStore: opt.CookieStore,
-		csrf:        opt.CSRF,
-		db:          opt.DB,
+		debugServer:    debugServer,
+		addr:           opt.Addr,
+		cookieStore:    opt.CookieStore,
+		csrf:           opt.CSRF,
+		store:          opt.Store,
+		readYourWrites: opt.ReadYourWrites,
+		dbStatus:       opt.DBStatus,
+		passwords:      opt.Passwords,
+		throttle:       opt.Throttle,
+		mailer:         opt.Mailer,
+		baseURL:        opt.BaseURL,
+		tokenSecret:    tokenSecret,
+		requireVerify:  opt.RequireVerifiedEmail,
+		twoFactorRoles: opt.TwoFactorRoles,
+		posterSecret:   posterSecret,
+		trustForwarded: opt.TrustForwardedFor,
 		server: http.Server{
-			Addr: opt.Addr,
+			Addr:    opt.Addr,
+			Handler: http.NewServeMux(),
 		},
-		logger:  log15.New("module", "server"),
+		logger:  logger,
 		started: make(chan struct{}),
 		stopped: make(chan struct{}),
 	}
 }
 
+func randomSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
 // Run ...
 func (s *Server) Run(ctx context.Context) error {
 	errCh := make(chan error, 1)
@@ -61,6 +140,12 @@ func (s *Server) Run(ctx context.Context) error {
 		s.setupHandler()
 		errCh <- s.server.Serve(l)
 	}()
+	if s.debugServer != nil {
+		go func() {
+			s.logger.Info("Listening for debug connections on", "addr", s.debugServer.Addr)
+			errCh <- s.debugServer.ListenAndServe()
+		}()
+	}
 	select {
 	case err := <-errCh:
 		s.logger.Info("server err", "err", err)
@@ -83,20 +168,47 @@ func (s *Server) HasStopped() <-chan struct{} {
 }
 
 func (s *Server) stop(ctx context.Context) {
+	if s.debugServer != nil {
+		s.debugServer.Shutdown(ctx)
+	}
 	s.server.Shutdown(ctx)
 	close(s.stopped)
 }
 
 func (s *Server) setupHandler() {
+	mux := s.server.Handler.(*http.ServeMux)
 	static := http.FileServer(http.Dir("server/static/"))
-	http.Handle("/stylesheets/", static)
-	http.Handle("/javascripts/", static)
+	mux.Handle("/stylesheets/", static)
+	mux.Handle("/javascripts/", static)
 
 	opt := handler.Option{
-		CookieStore: s.cookieStore,
-		DB:          s.db,
+		CookieStore:    s.cookieStore,
+		Store:          s.store,
+		ReadYourWrites: s.readYourWrites,
+		DBStatus:       s.dbStatus,
+		Passwords:      s.passwords,
+		Throttle:       s.throttle,
+
+		Mailer:               s.mailer,
+		TokenSecret:          s.tokenSecret,
+		BaseURL:              s.baseURL,
+		RequireVerifiedEmail: s.requireVerify,
+		TwoFactorRoles:       s.twoFactorRoles,
+
+		PosterSecret:      s.posterSecret,
+		TrustForwardedFor: s.trustForwarded,
 	}
-	http.Handle("/", handler.NewSession(opt))
-	http.Handle("/user", handler.NewUser(opt))
-	http.Handle("/bbs", handler.NewBBS(opt))
+	compat := handler.NewCompat(opt)
+	session := handler.NewSession(opt)
+	mux.Handle("/", compat.Or(session))
+	mux.Handle("/login/2fa", session)
+	mux.Handle("/test/bbs.cgi", compat)
+	mux.Handle("/user", handler.NewUser(opt))
+	mux.Handle("/sessions", handler.NewSessions(opt))
+	mux.Handle("/account/", handler.NewAccount(opt))
+	mux.Handle("/bbs", handler.NewBBS(opt))
+	board := handler.NewBoard(opt)
+	mux.Handle("/boards", board)
+	mux.Handle("/boards/", board)
+	mux.Handle("/healthz", handler.NewHealth(opt))
 }
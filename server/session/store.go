@@ -0,0 +1,251 @@
+// Package session keeps the sessions of signed in users in the database, so
+// that they expire, can be listed and can be revoked.
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/sessions"
+	"github.com/inconshreveable/log15"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/internal/httputil"
+	"github.com/seka/bbs-sample/model"
+)
+
+const (
+	// tokenLength is the number of random bytes of a session token.
+	tokenLength = 32
+	// touchInterval is how often the last seen time of a session is updated.
+	touchInterval = time.Minute
+	// maxUserAgentLength is the length of sessions.user_agent.
+	maxUserAgentLength = 255
+)
+
+// errNoUser is returned when saving a new session without a user ID.
+var errNoUser = errors.New("session: no user in session")
+
+// userValues are the values read from the user rather than stored with the
+// session, so renames and role changes apply at once.
+var userValues = []string{"id", "name", "role", "verified"}
+
+// Options ...
+type Options struct {
+	// MaxAge is how long a session lasts after sign in.
+	MaxAge time.Duration
+	// GCInterval is the interval between the deletions of expired sessions,
+	// 0 disables them.
+	GCInterval time.Duration
+	// TrustForwardedFor takes the client address from X-Forwarded-For.
+	TrustForwardedFor bool
+}
+
+// Store is a sessions.Store whose cookies only hold a random token. The
+// sessions are kept in the database under the SHA-256 of their token with the
+// user ID; the "id", "name", "role" and "verified" values are read from the
+// user.
+type Store struct {
+	sessions model.SessionRepository
+	opt      Options
+	logger   log15.Logger
+}
+
+// NewStore ...
+func NewStore(store model.Store, opt Options) *Store {
+	return &Store{
+		sessions: store.Sessions(),
+		opt:      opt,
+		logger:   log15.New("module", "session"),
+	}
+}
+
+// Get returns the session name of r, which is loaded once per request.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session whose token is in the cookie name of r, or a new
+// session when there is none or it expired or was revoked.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	sess := sessions.NewSession(s, name)
+	sess.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   int(s.opt.MaxAge / time.Second),
+		HttpOnly: true,
+	}
+	sess.IsNew = true
+	c, err := r.Cookie(name)
+	if err != nil || c.Value == "" {
+		return sess, nil
+	}
+	// A session is read right after sign in, before a replica may have it.
+	ctx := database.WithPrimary(r.Context())
+	row, err := s.sessions.Find(ctx, tokenID(c.Value))
+	if err == model.ErrNotFound {
+		return sess, nil
+	}
+	if err != nil {
+		return sess, err
+	}
+	now := time.Now()
+	if row.ExpiresAt <= now.Format(model.TimeFormat) {
+		return sess, nil
+	}
+	if err := decodeValues(row.Data, sess.Values); err != nil {
+		s.logger.Warn("Decode session error", "err", err)
+	}
+	sess.ID = row.ID
+	sess.Values["id"] = row.UserID
+	sess.Values["name"] = row.UserName
+	sess.Values["role"] = row.UserRole
+	sess.Values["verified"] = row.UserEmailVerified
+	sess.IsNew = false
+	s.touch(ctx, r, row, now)
+	return sess, nil
+}
+
+// touch records that the session row was used by r at now. The last seen time
+// is only updated every touchInterval unless the client changed.
+func (s *Store) touch(ctx context.Context, r *http.Request, row *model.Session, now time.Time) {
+	ip, userAgent := httputil.ClientIP(r, s.opt.TrustForwardedFor), truncate(r.UserAgent(), maxUserAgentLength)
+	lastSeen, err := time.ParseInLocation(model.TimeFormat, row.LastSeenAt, time.Local)
+	if err == nil && now.Sub(lastSeen) < touchInterval && row.IP == ip && row.UserAgent == userAgent {
+		return
+	}
+	if err := s.sessions.Touch(ctx, row.ID, now.Format(model.TimeFormat), ip, userAgent); err != nil {
+		s.logger.Warn("Touch session error", "err", err)
+	}
+}
+
+// Save stores sess and sets its cookie. A session with a negative MaxAge is
+// deleted. A session without an ID is created with a new token, so handlers
+// clear the ID on sign in to prevent session fixation.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, sess *sessions.Session) error {
+	ctx := r.Context()
+	userID, _ := sess.Values["id"].(int)
+	if sess.Options.MaxAge < 0 {
+		if sess.ID != "" {
+			if err := s.sessions.Delete(ctx, userID, sess.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(sess.Name(), "", sess.Options))
+		return nil
+	}
+	data, err := encodeValues(sess.Values)
+	if err != nil {
+		return err
+	}
+	if sess.ID != "" {
+		return s.sessions.UpdateData(ctx, sess.ID, data)
+	}
+	if userID == 0 {
+		return errNoUser
+	}
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+	row := &model.Session{
+		ID:         tokenID(token),
+		UserID:     userID,
+		Data:       data,
+		UserAgent:  truncate(r.UserAgent(), maxUserAgentLength),
+		IP:         httputil.ClientIP(r, s.opt.TrustForwardedFor),
+		CreatedAt:  now.Format(model.TimeFormat),
+		LastSeenAt: now.Format(model.TimeFormat),
+		ExpiresAt:  now.Add(s.opt.MaxAge).Format(model.TimeFormat),
+	}
+	if err := s.sessions.Save(ctx, row); err != nil {
+		return err
+	}
+	sess.ID = row.ID
+	http.SetCookie(w, sessions.NewCookie(sess.Name(), token, sess.Options))
+	return nil
+}
+
+// Run deletes the expired sessions every GCInterval until ctx is done.
+func (s *Store) Run(ctx context.Context) error {
+	if s.opt.GCInterval <= 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(s.opt.GCInterval)
+	defer ticker.Stop()
+	for {
+		s.collect(ctx, time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Store) collect(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, s.opt.GCInterval)
+	defer cancel()
+	n, err := s.sessions.DeleteExpired(ctx, now.Format(model.TimeFormat))
+	if err != nil {
+		s.logger.Warn("Delete expired sessions error", "err", err)
+		return
+	}
+	if n > 0 {
+		s.logger.Info("Deleted expired sessions", "sessions", n)
+	}
+}
+
+// tokenID returns the ID of the session of token.
+func tokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeValues encodes the values of a session but the userValues.
+func encodeValues(values map[interface{}]interface{}) (string, error) {
+	stored := make(map[interface{}]interface{}, len(values))
+	for k, v := range values {
+		stored[k] = v
+	}
+	for _, k := range userValues {
+		delete(stored, k)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(stored); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decodeValues(data string, values map[interface{}]interface{}) error {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(&values)
+}
+
+// truncate returns s shortened to n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+var _ sessions.Store = (*Store)(nil)
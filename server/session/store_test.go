@@ -0,0 +1,195 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seka/bbs-sample/database"
+	"github.com/seka/bbs-sample/database/migration"
+	"github.com/seka/bbs-sample/model"
+)
+
+const cookieName = "bbs-session"
+
+// testStores returns a memory store and a migrated SQLite store.
+func testStores(t *testing.T) map[string]model.Store {
+	db := database.NewSQLite(database.Options{Path: filepath.Join(t.TempDir(), "bbs.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	m, err := migration.New(db, database.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]model.Store{
+		"memory": model.NewMemoryStore(),
+		"sqlite": model.NewSQLStore(db),
+	}
+}
+
+// signIn saves a new session of user and returns its cookie.
+func signIn(t *testing.T, s *Store, user *model.User) *http.Cookie {
+	r := httptest.NewRequest("POST", "/login", nil)
+	sess, err := s.New(r, cookieName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Values["id"] = user.ID
+	sess.Values["flash"] = "welcome"
+	w := httptest.NewRecorder()
+	if err := s.Save(r, w, sess); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "" {
+		t.Fatalf("Save() cookies = %v, want the session token", cookies)
+	}
+	return cookies[0]
+}
+
+// load returns the session of the request with c.
+func load(t *testing.T, s *Store, c *http.Cookie) map[interface{}]interface{} {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(c)
+	sess, err := s.New(r, cookieName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.IsNew {
+		return nil
+	}
+	return sess.Values
+}
+
+func TestStore(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user := &model.User{Name: "alice", Email: "alice@example.com", Password: "hash", Role: model.RoleMember}
+			if err := store.Users().Save(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			s := NewStore(store, Options{MaxAge: time.Hour})
+
+			cookie := signIn(t, s, user)
+			// Only the hash of the token is stored.
+			if _, err := store.Sessions().Find(ctx, cookie.Value); err != model.ErrNotFound {
+				t.Errorf("Find(token) error = %v, want ErrNotFound", err)
+			}
+			row, err := store.Sessions().Find(ctx, tokenID(cookie.Value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row.UserID != user.ID {
+				t.Errorf("session user = %d, want %d", row.UserID, user.ID)
+			}
+			values := load(t, s, cookie)
+			if values == nil || values["id"] != user.ID || values["name"] != "alice" || values["flash"] != "welcome" {
+				t.Errorf("loaded session values = %v", values)
+			}
+			if values := load(t, s, &http.Cookie{Name: cookieName, Value: tokenID(cookie.Value)}); values != nil {
+				t.Errorf("session loaded by its stored ID = %v, want a new session", values)
+			}
+
+			// Revoking one session keeps the other.
+			other := signIn(t, s, user)
+			if err := store.Sessions().Delete(ctx, user.ID, tokenID(cookie.Value)); err != nil {
+				t.Fatal(err)
+			}
+			if load(t, s, cookie) != nil {
+				t.Error("revoked session loaded")
+			}
+			if load(t, s, other) == nil {
+				t.Error("other session not loaded after a revocation")
+			}
+
+			// Signing out everywhere deletes every session of the user.
+			third := signIn(t, s, user)
+			if err := store.Sessions().DeleteByUserID(ctx, user.ID); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []*http.Cookie{other, third} {
+				if load(t, s, c) != nil {
+					t.Error("session loaded after signing out everywhere")
+				}
+			}
+		})
+	}
+}
+
+func TestStoreSignOut(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user := &model.User{Name: "alice", Email: "alice@example.com", Password: "hash", Role: model.RoleMember}
+			if err := store.Users().Save(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			s := NewStore(store, Options{MaxAge: time.Hour})
+			cookie := signIn(t, s, user)
+
+			r := httptest.NewRequest("POST", "/logout", nil)
+			r.AddCookie(cookie)
+			sess, err := s.New(r, cookieName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sess.Options.MaxAge = -1
+			w := httptest.NewRecorder()
+			if err := s.Save(r, w, sess); err != nil {
+				t.Fatal(err)
+			}
+			if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != "" {
+				t.Errorf("sign out cookies = %v, want the cookie cleared", cookies)
+			}
+			if _, err := store.Sessions().Find(ctx, tokenID(cookie.Value)); err != model.ErrNotFound {
+				t.Errorf("Find() after sign out error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStoreGC(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user := &model.User{Name: "alice", Email: "alice@example.com", Password: "hash", Role: model.RoleMember}
+			if err := store.Users().Save(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now()
+			for _, row := range []*model.Session{
+				{ID: tokenID("expired"), ExpiresAt: now.Add(-time.Minute).Format(model.TimeFormat)},
+				{ID: tokenID("valid"), ExpiresAt: now.Add(time.Hour).Format(model.TimeFormat)},
+			} {
+				row.UserID = user.ID
+				row.CreatedAt = now.Add(-2 * time.Hour).Format(model.TimeFormat)
+				row.LastSeenAt = row.CreatedAt
+				if err := store.Sessions().Save(ctx, row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := NewStore(store, Options{MaxAge: time.Hour, GCInterval: time.Minute})
+
+			// An expired session is not loaded before it is collected.
+			if load(t, s, &http.Cookie{Name: cookieName, Value: "expired"}) != nil {
+				t.Error("expired session loaded")
+			}
+			s.collect(ctx, now)
+			if _, err := store.Sessions().Find(ctx, tokenID("expired")); err != model.ErrNotFound {
+				t.Errorf("Find() of a collected session error = %v, want ErrNotFound", err)
+			}
+			if load(t, s, &http.Cookie{Name: cookieName, Value: "valid"}) == nil {
+				t.Error("valid session not loaded after a collection")
+			}
+		})
+	}
+}